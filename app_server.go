@@ -2,33 +2,55 @@ package main
 
 import (
 	"context"
+	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 	"github.com/sirupsen/logrus"
+	"go-whisper-mcp/configs"
+	"go-whisper-mcp/whisper"
 )
 
+// shutdownGrace 排空结束（或超时中断）后，等待连接关闭的时长，超过则强制关闭
+const shutdownGrace = 10 * time.Second
+
+// Drainer 关闭时需要排空的后台工作（异步任务、目录监听）
+type Drainer interface {
+	// Drain 停止接收新工作并等待进行中的工作结束；ctx 到期时中断剩余工作，返回时已停止
+	Drain(ctx context.Context)
+}
+
 // AppServer 应用服务器结构体，封装所有服务和处理器
 type AppServer struct {
 	whisperService *WhisperService
+	jobs           *JobManager
 	mcpServer      *mcp.Server
 	router         *gin.Engine
 	httpServer     *http.Server
-	modelsDir      string
-	defaultModel   string
+	reloader       *configReloader // SIGHUP 与 POST /admin/reload 重新加载配置，可为 nil
+	drainers       []Drainer       // 关闭时与异步任务一起排空
+	draining       atomic.Bool     // 收到退出信号后置位，/health/ready 返回未就绪
 }
 
-// NewAppServer 创建新的应用服务器实例
-func NewAppServer(modelsDir string, defaultModel string, whisperService *WhisperService) *AppServer {
+// NewAppServer 创建新的应用服务器实例；模型目录与默认模型取自当前配置（configs.Get）
+func NewAppServer(whisperService *WhisperService, reloader *configReloader) *AppServer {
+	webhooks := NewWebhookSender(WebhookOptions{
+		AllowedHosts: configs.GetWebhookAllowedHosts(),
+		MaxAttempts:  configs.GetWebhookMaxAttempts(),
+		Timeout:      configs.GetWebhookTimeout(),
+	})
 	appServer := &AppServer{
 		whisperService: whisperService,
-		modelsDir:      modelsDir,
-		defaultModel:   defaultModel,
+		jobs:           NewJobManager(whisperService, webhooks, configs.GetMaxConcurrentJobs(), configs.GetJobsRequeuePath()),
+		reloader:       reloader,
 	}
 
 	// 初始化 MCP Server（需要在创建 appServer 之后，因为工具注册需要访问 appServer）
@@ -37,40 +59,185 @@ func NewAppServer(modelsDir string, defaultModel string, whisperService *Whisper
 	return appServer
 }
 
-// Start 启动服务器
+// AddDrainer 登记关闭时需要排空的后台工作，须在 Run 之前调用
+func (a *AppServer) AddDrainer(d Drainer) {
+	a.drainers = append(a.drainers, d)
+}
+
+// modelsDir 当前配置的模型目录
+func (a *AppServer) modelsDir() string {
+	return configs.Get().Models.Dir
+}
+
+// defaultModel 当前配置的默认模型
+func (a *AppServer) defaultModel() string {
+	return configs.Get().Models.Default
+}
+
+// MCP 传输方式
+const (
+	TransportHTTP  = "http"  // HTTP：REST API + /mcp（Streamable HTTP）
+	TransportStdio = "stdio" // 标准输入输出上的 MCP JSON-RPC，供桌面客户端/IDE 以子进程方式启动
+	TransportBoth  = "both"  // 同时提供两者
+)
+
+// Start 启动 HTTP 服务器
 func (a *AppServer) Start(port string) error {
-	a.router = setupRoutes(a)
+	return a.Run(port, TransportHTTP)
+}
+
+// Run 按传输方式启动服务，直到收到退出信号；stdio 模式下客户端关闭输入流时退出
+func (a *AppServer) Run(port, transport string) error {
+	switch transport {
+	case TransportHTTP, TransportStdio, TransportBoth:
+	default:
+		return fmt.Errorf("unknown transport %q (http, stdio, both)", transport)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-	a.httpServer = &http.Server{
-		Addr:    port,
-		Handler: a.router,
+	// SIGHUP：重新加载配置文件与模型注册表，进行中的请求不受影响
+	if a.reloader != nil {
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		defer signal.Stop(hup)
+		go func() {
+			for {
+				select {
+				case <-hup:
+					_, _ = a.reloader.Reload("signal")
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
 	}
 
-	// 启动服务器的 goroutine
-	go func() {
-		logrus.Infof("启动 HTTP 服务器: %s", port)
-		if err := a.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			logrus.Errorf("服务器启动失败: %v", err)
-			os.Exit(1)
+	errCh := make(chan error, 1)
+	stdioDone := make(chan struct{})
+	// stdio 会话在排空结束（或超时）时才关闭，进行中的工具调用可以完成；关闭会话同时取消其中的工具调用
+	stdioCtx, stopStdio := context.WithCancel(context.Background())
+	defer stopStdio()
+	if transport != TransportHTTP {
+		// stdout 只留给 JSON-RPC：日志全部走 stderr
+		rpcOut, err := reserveStdout()
+		if err != nil {
+			return err
 		}
-	}()
+		os.Stdout = rpcOut
+		gin.DefaultWriter = os.Stderr
+		logrus.SetOutput(os.Stderr)
 
-	// 等待中断信号
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+		go func() {
+			defer close(stdioDone)
+			logrus.Infof("MCP stdio 传输已启动")
+			if err := a.mcpServer.Run(stdioCtx, &mcp.StdioTransport{}); err != nil && stdioCtx.Err() == nil {
+				logrus.Warnf("MCP stdio 会话结束: %v", err)
+			} else {
+				logrus.Infof("MCP stdio 会话结束")
+			}
+		}()
+	}
+
+	// 所有 HTTP 请求的 ctx 派生自 baseCtx：排空结束后取消，结束 MCP SSE 长连接并中断超时未完成的转录
+	baseCtx, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
+	if transport != TransportStdio {
+		a.router = setupRoutes(a)
+		a.httpServer = &http.Server{
+			Addr:        port,
+			Handler:     a.router,
+			BaseContext: func(net.Listener) context.Context { return baseCtx },
+		}
 
-	logrus.Infof("正在关闭服务器...")
+		// 启动服务器的 goroutine
+		go func() {
+			logrus.Infof("启动 HTTP 服务器: %s", port)
+			if err := a.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				errCh <- fmt.Errorf("服务器启动失败: %w", err)
+			}
+		}()
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	// 上次关闭时被中断的异步任务
+	if err := a.jobs.Restore(); err != nil {
+		logrus.Warnf("恢复未完成的任务失败: %v", err)
+	}
+
+	// 等待中断信号；仅 stdio 时客户端断开即退出
+	var runErr error
+	select {
+	case <-ctx.Done():
+	case runErr = <-errCh:
+	case <-waitStdio(stdioDone, transport == TransportStdio):
+	}
+	// 排空期间再次收到 SIGINT / SIGTERM 时按默认行为立即退出
+	stop()
+
+	a.shutdown(func() {
+		cancelRequests()
+		stopStdio()
+	})
+	logrus.Infof("服务器已关闭")
+	return runErr
+}
+
+// shutdown 优雅关闭：/health/ready 先转为未就绪，等待 shutdown_delay 后停止接收新请求与新任务，
+// 排空进行中的转录（最长 drain_timeout），超时则中断剩余工作；被中断的异步任务在重启后重新执行。
+// cancelRequests 在排空结束或超时时调用，取消 HTTP 请求与 stdio 会话中仍在进行的工具调用
+func (a *AppServer) shutdown(cancelRequests context.CancelFunc) {
+	a.draining.Store(true)
+	if d := configs.GetShutdownDelay(); d > 0 {
+		logrus.Infof("已转为未就绪，%s 后停止接收新请求", d)
+		time.Sleep(d)
+	}
+	drain := configs.GetDrainTimeout()
+	logrus.Infof("正在关闭服务器：停止接收新请求，等待进行中的转录（最长 %s）...", drain)
+
+	// 立即关闭监听；Shutdown 等待进行中的请求，MCP SSE 长连接在排空结束后随 cancelRequests 断开
+	httpDone := make(chan error, 1)
+	if a.httpServer != nil {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), drain+shutdownGrace)
+		defer cancel()
+		go func() { httpDone <- a.httpServer.Shutdown(shutdownCtx) }()
+	}
+
+	drainCtx, cancel := context.WithTimeout(context.Background(), drain)
 	defer cancel()
+	var wg sync.WaitGroup
+	for _, d := range append([]Drainer{a.jobs}, a.drainers...) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.Drain(drainCtx)
+		}()
+	}
+	if err := a.whisperService.Drain(drainCtx); err != nil {
+		logrus.Warnf("排空超时，中断剩余的转录")
+	}
+	cancelRequests()
+	wg.Wait()
 
-	if err := a.httpServer.Shutdown(ctx); err != nil {
-		logrus.Warnf("等待连接关闭超时，强制退出: %v", err)
+	if a.httpServer != nil {
+		if err := <-httpDone; err != nil {
+			logrus.Warnf("等待连接关闭超时，强制关闭: %v", err)
+			_ = a.httpServer.Close()
+		} else {
+			logrus.Infof("HTTP 服务器已优雅关闭")
+		}
+	}
+	if models := whisper.LoadedModels(); len(models) > 0 {
+		logrus.Warnf("仍有模型未释放: %v", models)
 	} else {
-		logrus.Infof("服务器已优雅关闭")
+		logrus.Infof("进行中的转录已结束，模型已全部释放")
 	}
+}
 
-	logrus.Infof("服务器已关闭")
+// waitStdio stdio 会话结束是否触发退出；both 模式下 HTTP 继续服务
+func waitStdio(done chan struct{}, exit bool) <-chan struct{} {
+	if exit {
+		return done
+	}
 	return nil
 }
@@ -0,0 +1,370 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+
+	"go-whisper-mcp/configs"
+	"go-whisper-mcp/pkg"
+	"gopkg.in/yaml.v3"
+)
+
+// cliCommon 子命令共用的参数
+type cliCommon struct {
+	configPath string
+	modelsDir  string
+	offline    bool
+}
+
+func (c *cliCommon) register(fs *flag.FlagSet) {
+	fs.StringVar(&c.configPath, "config", os.Getenv("CONFIG_FILE"), "配置文件（.yaml/.toml/.json，CONFIG_FILE）")
+	fs.StringVar(&c.modelsDir, "models-dir", "", "模型目录（缺省读取配置 models.dir / MODELS_DIR）")
+	fs.BoolVar(&c.offline, "offline", false, "离线模式：缺失的模型不访问网络（WHISPER_OFFLINE）")
+}
+
+// overrides 显式传入的参数覆盖配置文件与环境变量；extra 为子命令自己的参数，重新加载配置时会再次应用
+func (c *cliCommon) overrides(fs *flag.FlagSet, extra func(*configs.Config)) func(*configs.Config) {
+	return func(cfg *configs.Config) {
+		fs.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "models-dir":
+				cfg.Models.Dir = c.modelsDir
+			case "offline":
+				cfg.Models.Offline = c.offline
+			}
+		})
+		if extra != nil {
+			extra(cfg)
+		}
+	}
+}
+
+// setup 加载配置并加载模型注册表
+func (c *cliCommon) setup(override func(*configs.Config)) (*configs.Config, error) {
+	cfg, err := setupConfig(c.configPath, override)
+	if err != nil {
+		return nil, err
+	}
+	c.modelsDir, c.offline = cfg.Models.Dir, cfg.Models.Offline
+	if err := setupModels(cfg.Models); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// parseInterspersed 允许参数与位置参数交错（如 transcribe a.mp4 -l zh）
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		if args[0] == "--" {
+			return append(positional, args[1:]...), nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// signalContext Ctrl+C / SIGTERM 时取消
+func signalContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
+
+// runTranscribe 不经 HTTP 直接调用 WhisperService 转录；任一文件失败时返回 1
+func runTranscribe(args []string) int {
+	var (
+		common  cliCommon
+		req     TranscribeRequest
+		formats string
+		dest    string
+		include string
+		exclude string
+		jsonOut bool
+	)
+	fs := flag.NewFlagSet("transcribe", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "用法: go-whisper-mcp transcribe [flags] <文件|目录|glob|URL>...")
+		fs.PrintDefaults()
+	}
+	common.register(fs)
+	fs.StringVar(&req.Model, "m", "", "模型别名或文件名（缺省读取配置 models.default / WHISPER_MODEL）")
+	fs.StringVar(&req.Lang, "l", "", "语言代码（zh/en/auto），缺省自动")
+	fs.IntVar(&req.Threads, "t", 0, "线程数")
+	fs.StringVar(&formats, "o", FormatTXT, "输出格式（json,srt,vtt,txt，逗号分隔）")
+	fs.StringVar(&dest, "out", "", "输出目录或 s3://bucket/prefix；为空时打印到标准输出")
+	fs.StringVar(&include, "include", "", "目录/glob 展开时的包含模式（逗号分隔）")
+	fs.StringVar(&exclude, "exclude", "", "目录/glob 展开时的排除模式（逗号分隔）")
+	fs.IntVar(&req.MaxFiles, "max-files", 0, "展开后的文件数上限")
+	fs.IntVar(&req.TimeoutS, "timeout", 0, "最长耗时（秒），0 表示不限制")
+	fs.StringVar(&req.AudioStream, "audio-stream", "", "多音轨媒体：音频流序号、语言代码或 all（逐条转录）")
+	fs.BoolVar(&jsonOut, "json", false, "以 JSON 打印完整结果")
+	inputs, err := parseInterspersed(fs, args)
+	if err != nil {
+		return exitCodeForParse(err)
+	}
+	if len(inputs) == 0 {
+		fs.Usage()
+		return 2
+	}
+	fmtList, err := ParseFormats([]string{formats})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	// 标准输出只打印一种格式：多个格式需要 -out 写成文件
+	if len(fmtList) > 1 && dest == "" && !jsonOut {
+		fmt.Fprintf(os.Stderr, "transcribe: -o %s 指定了多个格式，需同时指定 -out（不指定 -out 时只能打印一种格式）\n", formats)
+		return 2
+	}
+	cfg, err := common.setup(common.overrides(fs, nil))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if req.Model == "" {
+		req.Model = cfg.Models.Default
+	}
+
+	req.InPaths = inputs
+	req.ModelsDir = common.modelsDir
+	req.Include = splitList(include)
+	req.Exclude = splitList(exclude)
+	if dest != "" {
+		req.Output = &OutputOptions{Dest: dest, Formats: fmtList}
+		req.LocalOutput = true
+	}
+
+	shutdownTracing, err := setupTracing()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "transcribe: %v\n", err)
+		return 1
+	}
+	defer shutdownTracing()
+
+	ctx, cancel := signalContext()
+	defer cancel()
+	out, err := NewWhisperService().Transcribe(ctx, &req)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "transcribe: %v\n", err)
+		if errors.Is(err, ErrCancelled) {
+			return 130 // 与 shell 中 Ctrl+C 的退出码一致
+		}
+		return 1
+	}
+
+	if jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(out)
+	}
+
+	failed := 0
+	for _, r := range out.Results {
+		switch {
+		case !r.IsSuccess:
+			failed++
+			fmt.Fprintf(os.Stderr, "✘ %s: %s\n", r.Path, r.Error)
+			continue
+		case r.OutputError != "":
+			failed++
+			fmt.Fprintf(os.Stderr, "✘ %s: %s\n", r.Path, r.OutputError)
+			continue
+		case dest != "":
+			fmt.Fprintf(os.Stderr, "✔ %s -> %s\n", r.Path, strings.Join(r.Outputs, ", "))
+			continue
+		}
+		if jsonOut {
+			continue
+		}
+		// 未指定输出目录：按指定的格式打印到标准输出
+		data, _, err := FormatTranscript(r, fmtList[0])
+		if err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "✘ %s: %v\n", r.Path, err)
+			continue
+		}
+		if st := r.AudioStream; st != nil && len(out.Results) > 1 {
+			fmt.Fprintf(os.Stdout, "==> %s [a:%d %s] <==\n", r.Path, st.Index, cmp.Or(st.Language, "und"))
+		} else if len(out.Results) > 1 {
+			fmt.Fprintf(os.Stdout, "==> %s <==\n", r.Path)
+		}
+		os.Stdout.Write(data)
+	}
+	if failed > 0 {
+		fmt.Fprintf(os.Stderr, "%d/%d failed\n", failed, len(out.Results))
+		return 1
+	}
+	return 0
+}
+
+// runModels 模型管理：list / pull / rm / verify / import
+func runModels(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "用法: go-whisper-mcp models list|pull|rm|verify|import [flags] ...")
+		return 2
+	}
+	sub, args := args[0], args[1:]
+
+	var (
+		common    cliCommon
+		jsonOut   bool
+		imported  pkg.ModelEntry
+		langs     string
+		overwrite bool
+	)
+	fs := flag.NewFlagSet("models "+sub, flag.ContinueOnError)
+	common.register(fs)
+	switch sub {
+	case "list":
+		fs.BoolVar(&jsonOut, "json", false, "以 JSON 输出")
+	case "import":
+		fs.StringVar(&imported.Name, "name", "", "别名，缺省为文件名")
+		fs.StringVar(&imported.Path, "filename", "", "保存到模型目录的文件名，缺省同源文件")
+		fs.StringVar(&imported.URL, "url", "", "可选下载地址")
+		fs.StringVar(&imported.SHA256, "sha256", "", "可选，提供时校验")
+		fs.StringVar(&langs, "languages", "", "支持的语言（逗号分隔）")
+		fs.StringVar(&imported.Defaults.Language, "default-lang", "", "默认语言")
+		fs.BoolVar(&overwrite, "overwrite", false, "替换已存在的同名模型文件与注册表条目")
+	case "pull", "rm", "verify":
+	default:
+		fmt.Fprintf(os.Stderr, "未知子命令 models %s\n", sub)
+		return 2
+	}
+	specs, err := parseInterspersed(fs, args)
+	if err != nil {
+		return exitCodeForParse(err)
+	}
+	if sub != "list" && len(specs) == 0 {
+		fmt.Fprintf(os.Stderr, "用法: go-whisper-mcp models %s [flags] <模型>...\n", sub)
+		return 2
+	}
+	if _, err := common.setup(common.overrides(fs, nil)); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	failed := 0
+	switch sub {
+	case "list":
+		models, err := pkg.ListInstalledModels(common.modelsDir)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if jsonOut {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			_ = enc.Encode(models)
+			return 0
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "NAME\tSIZE\tREGISTERED\tPATH")
+		for _, m := range models {
+			fmt.Fprintf(tw, "%s\t%s\t%v\t%s\n", m.Name, pkg.HumanBytes(float64(m.Size)), m.Registered, m.Path)
+		}
+		_ = tw.Flush()
+	case "pull":
+		for _, spec := range specs {
+			p, downloaded, err := pkg.EnsureModelInDirWithProgress(ctx, common.modelsDir, spec, pkg.DefaultProgress())
+			switch {
+			case err != nil:
+				failed++
+				fmt.Fprintf(os.Stderr, "✘ %s: %v\n", spec, err)
+			case downloaded:
+				fmt.Printf("✔ %s -> %s\n", spec, p)
+			default:
+				fmt.Printf("✔ %s already present: %s\n", spec, p)
+			}
+		}
+	case "rm":
+		for _, spec := range specs {
+			p, err := pkg.RemoveModel(common.modelsDir, spec)
+			if err != nil {
+				failed++
+				fmt.Fprintf(os.Stderr, "✘ %s: %v\n", spec, err)
+				continue
+			}
+			fmt.Printf("✔ removed %s (%s)\n", spec, p)
+		}
+	case "verify":
+		for _, spec := range specs {
+			p, err := pkg.VerifyModel(common.modelsDir, spec)
+			if err != nil {
+				failed++
+				fmt.Fprintf(os.Stderr, "✘ %s (%s): %v\n", spec, p, err)
+				continue
+			}
+			fmt.Printf("✔ %s ok (%s)\n", spec, p)
+		}
+	case "import":
+		if len(specs) != 1 {
+			fmt.Fprintln(os.Stderr, "用法: go-whisper-mcp models import [flags] <源文件>")
+			return 2
+		}
+		imported.Languages = splitList(langs)
+		entry, err := pkg.ImportModel(ctx, common.modelsDir, specs[0], imported, overwrite)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "✘ %s: %v\n", specs[0], err)
+			return 1
+		}
+		fmt.Printf("✔ imported %s -> %s\n", entry.Name, entry.LocalPath(common.modelsDir))
+	}
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// runConfig 校验配置并打印生效值（密钥已隐去）；校验失败时返回 1
+func runConfig(args []string) int {
+	var (
+		configPath string
+		format     string
+	)
+	fs := flag.NewFlagSet("config", flag.ContinueOnError)
+	fs.StringVar(&configPath, "config", os.Getenv("CONFIG_FILE"), "配置文件（.yaml/.toml/.json，CONFIG_FILE）")
+	fs.StringVar(&format, "format", "yaml", "输出格式（yaml, json）")
+	if err := fs.Parse(args); err != nil {
+		return exitCodeForParse(err)
+	}
+	if format != "yaml" && format != "json" {
+		fmt.Fprintf(os.Stderr, "unknown format %q (yaml, json)\n", format)
+		return 2
+	}
+	cfg, err := configs.Load(configPath)
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid config:\n%v\n", err)
+		return 1
+	}
+	if format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(cfg.Redacted())
+		return 0
+	}
+	enc := yaml.NewEncoder(os.Stdout)
+	enc.SetIndent(2)
+	_ = enc.Encode(cfg.Redacted())
+	_ = enc.Close()
+	return 0
+}
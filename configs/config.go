@@ -0,0 +1,622 @@
+package configs
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"go-whisper-mcp/pkg/logging"
+	"gopkg.in/yaml.v3"
+)
+
+// Config 运行时配置，优先级：默认值 < 配置文件（YAML / TOML / JSON）< 环境变量 < 命令行参数
+type Config struct {
+	Server  Server   `yaml:"server" toml:"server" json:"server"`
+	Models  Models   `yaml:"models" toml:"models" json:"models"`
+	Media   Media    `yaml:"media" toml:"media" json:"media"`
+	Limits  Limits   `yaml:"limits" toml:"limits" json:"limits"`
+	Auth    Auth     `yaml:"auth" toml:"auth" json:"auth"`
+	Cache   Cache    `yaml:"cache" toml:"cache" json:"cache"`
+	Decode  Decode   `yaml:"decode" toml:"decode" json:"decode"`
+	Webhook Webhook  `yaml:"webhook" toml:"webhook" json:"webhook"`
+	S3      S3Config `yaml:"s3" toml:"s3" json:"s3"`
+	Tracing Tracing  `yaml:"tracing" toml:"tracing" json:"tracing"`
+	Log     Log      `yaml:"log" toml:"log" json:"log"`
+}
+
+// Server HTTP / MCP 服务
+type Server struct {
+	Port      string `yaml:"port" toml:"port" json:"port"`                // PORT，如 :28796
+	Transport string `yaml:"transport" toml:"transport" json:"transport"` // MCP_TRANSPORT：http | stdio | both
+
+	// 优雅关闭：收到 SIGTERM 后 /health/ready 立即返回 503，等待 shutdown_delay 让负载均衡摘除流量，
+	// 再停止接收新请求并等待进行中的转录，最长 drain_timeout，超时后中断剩余工作
+	ShutdownDelay Duration `yaml:"shutdown_delay" toml:"shutdown_delay" json:"shutdown_delay"` // SHUTDOWN_DELAY，缺省 0
+	DrainTimeout  Duration `yaml:"drain_timeout" toml:"drain_timeout" json:"drain_timeout"`    // DRAIN_TIMEOUT，缺省 10m
+
+	// 反向代理：只有来自这些地址（IP 或 CIDR）的请求才采用 X-Forwarded-For / X-Real-IP 作为客户端 IP，缺省不信任任何代理
+	TrustedProxies []string `yaml:"trusted_proxies" toml:"trusted_proxies" json:"trusted_proxies"` // TRUSTED_PROXIES，逗号分隔
+}
+
+// Models 模型目录与默认模型
+type Models struct {
+	Dir      string `yaml:"dir" toml:"dir" json:"dir"`                // MODELS_DIR
+	Registry string `yaml:"registry" toml:"registry" json:"registry"` // MODELS_REGISTRY，缺省 <dir>/registry.json
+	Default  string `yaml:"default" toml:"default" json:"default"`    // WHISPER_MODEL
+	Offline  bool   `yaml:"offline" toml:"offline" json:"offline"`    // WHISPER_OFFLINE
+
+	ImportDir string `yaml:"import_dir" toml:"import_dir" json:"import_dir"` // MODELS_IMPORT_DIR：POST /api/models/import 的 src_path 只能位于此目录，缺省不允许经 HTTP 导入
+}
+
+// Media 网络媒体下载
+type Media struct {
+	Dir            string   `yaml:"dir" toml:"dir" json:"dir"`                                     // MEDIA_DIR
+	MaxBytes       int64    `yaml:"max_bytes" toml:"max_bytes" json:"max_bytes"`                   // MEDIA_MAX_BYTES，0 不限制
+	ConnectTimeout Duration `yaml:"connect_timeout" toml:"connect_timeout" json:"connect_timeout"` // MEDIA_CONNECT_TIMEOUT
+	IdleTimeout    Duration `yaml:"idle_timeout" toml:"idle_timeout" json:"idle_timeout"`          // MEDIA_IDLE_TIMEOUT
+	TotalTimeout   Duration `yaml:"total_timeout" toml:"total_timeout" json:"total_timeout"`       // MEDIA_TOTAL_TIMEOUT，0 不限制
+	YtDlpBin       string   `yaml:"ytdlp_bin" toml:"ytdlp_bin" json:"ytdlp_bin"`                   // YTDLP_BIN
+	YtDlpHosts     []string `yaml:"ytdlp_hosts" toml:"ytdlp_hosts" json:"ytdlp_hosts"`             // YTDLP_HOSTS，为空使用内置列表
+	YtDlpTimeout   Duration `yaml:"ytdlp_timeout" toml:"ytdlp_timeout" json:"ytdlp_timeout"`       // YTDLP_TIMEOUT
+
+	OutputDir string `yaml:"output_dir" toml:"output_dir" json:"output_dir"` // OUTPUT_DIR：HTTP 请求 output.dest 可写的本地根目录，缺省只允许 s3://
+}
+
+// Limits 请求与队列限制
+type Limits struct {
+	MaxInputFiles      int      `yaml:"max_input_files" toml:"max_input_files" json:"max_input_files"`                // MAX_INPUT_FILES
+	MaxRequestDuration Duration `yaml:"max_request_duration" toml:"max_request_duration" json:"max_request_duration"` // MAX_REQUEST_DURATION，0 不限制
+	MaxQueueDepth      int      `yaml:"max_queue_depth" toml:"max_queue_depth" json:"max_queue_depth"`                // MAX_QUEUE_DEPTH，0 不限制
+	RateLimit          float64  `yaml:"rate_limit" toml:"rate_limit" json:"rate_limit"`                               // RATE_LIMIT，每个客户端每秒请求数，0 不限制
+	RateBurst          int      `yaml:"rate_burst" toml:"rate_burst" json:"rate_burst"`                               // RATE_BURST，允许的突发请求数
+
+	// 异步任务（callback_url）：每个任务各自加载模型，限制同时执行的数量
+	MaxConcurrentJobs int `yaml:"max_concurrent_jobs" toml:"max_concurrent_jobs" json:"max_concurrent_jobs"` // MAX_CONCURRENT_JOBS，同时执行的任务数
+	MaxQueuedJobs     int `yaml:"max_queued_jobs" toml:"max_queued_jobs" json:"max_queued_jobs"`             // MAX_QUEUED_JOBS，等待执行的任务上限，超出时拒绝
+}
+
+// Auth HTTP 接口鉴权；未配置 API Key 时不鉴权
+type Auth struct {
+	APIKeys []APIKey `yaml:"api_keys" toml:"api_keys" json:"api_keys"` // API_KEYS，逗号分隔的 name:key 或 key
+}
+
+// APIKey 客户端密钥，name 用于日志与限流
+type APIKey struct {
+	Name string `yaml:"name" toml:"name" json:"name"`
+	Key  string `yaml:"key" toml:"key" json:"key"`
+}
+
+// Cache 转录缓存
+type Cache struct {
+	Enabled        bool   `yaml:"enabled" toml:"enabled" json:"enabled"`                         // CACHE_ENABLED，关闭后不复用同名 .json 结果
+	TranscriptsDir string `yaml:"transcripts_dir" toml:"transcripts_dir" json:"transcripts_dir"` // TRANSCRIPTS_DIR，缺省 <media.dir>/transcripts
+
+	TranscriptsMax int `yaml:"transcripts_max" toml:"transcripts_max" json:"transcripts_max"` // TRANSCRIPTS_MAX，保存的转录结果上限，超出时淘汰最久未使用的；0 不限制
+}
+
+// Decode 默认解码参数；请求与模型注册表中的设置优先
+type Decode struct {
+	Language string `yaml:"language" toml:"language" json:"language"`    // WHISPER_LANG，缺省自动识别
+	Threads  int    `yaml:"threads" toml:"threads" json:"threads"`       // WHISPER_THREADS
+	BeamSize int    `yaml:"beam_size" toml:"beam_size" json:"beam_size"` // WHISPER_BEAM_SIZE
+
+	DenoiseModel string `yaml:"denoise_model" toml:"denoise_model" json:"denoise_model"` // ARNNDN_MODEL：预处理 denoise=arnndn 使用的 RNNoise 模型（.rnnn）
+}
+
+// Webhook 异步任务回调
+type Webhook struct {
+	AllowedHosts []string `yaml:"allowed_hosts" toml:"allowed_hosts" json:"allowed_hosts"` // WEBHOOK_ALLOWED_HOSTS
+	MaxAttempts  int      `yaml:"max_attempts" toml:"max_attempts" json:"max_attempts"`    // WEBHOOK_MAX_ATTEMPTS
+	Timeout      Duration `yaml:"timeout" toml:"timeout" json:"timeout"`                   // WEBHOOK_TIMEOUT
+}
+
+// S3Config S3 兼容对象存储；use_ssl 缺省按 endpoint 的协议判断（默认开启）
+type S3Config struct {
+	Endpoint     string `yaml:"endpoint" toml:"endpoint" json:"endpoint"`                // S3_ENDPOINT
+	AccessKey    string `yaml:"access_key" toml:"access_key" json:"access_key"`          // S3_ACCESS_KEY / AWS_ACCESS_KEY_ID
+	SecretKey    string `yaml:"secret_key" toml:"secret_key" json:"secret_key"`          // S3_SECRET_KEY / AWS_SECRET_ACCESS_KEY
+	SessionToken string `yaml:"session_token" toml:"session_token" json:"session_token"` // S3_SESSION_TOKEN / AWS_SESSION_TOKEN
+	Region       string `yaml:"region" toml:"region" json:"region"`                      // S3_REGION / AWS_REGION
+	UseSSL       *bool  `yaml:"use_ssl" toml:"use_ssl" json:"use_ssl"`                   // S3_USE_SSL
+}
+
+// Tracing 链路追踪
+type Tracing struct {
+	Exporter string `yaml:"exporter" toml:"exporter" json:"exporter"` // OTEL_TRACES_EXPORTER：otlp | none
+}
+
+// Log 日志
+type Log struct {
+	Level  string `yaml:"level" toml:"level" json:"level"`    // LOG_LEVEL：trace | debug | info | warn | error
+	Format string `yaml:"format" toml:"format" json:"format"` // LOG_FORMAT：text | json
+	Native bool   `yaml:"native" toml:"native" json:"native"` // 输出 whisper.cpp / ggml 的 info 与 debug 日志（NATIVE_LOG_SILENT=0），警告与错误始终输出
+}
+
+// Duration 配置文件中写作 "30s"、"10m" 的时长
+type Duration time.Duration
+
+// MarshalText 编码为 "1m30s" 形式
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// UnmarshalText 解析 time.ParseDuration 格式
+func (d *Duration) UnmarshalText(b []byte) error {
+	v, err := time.ParseDuration(string(b))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Default 内置默认值
+func Default() *Config {
+	return &Config{
+		Server: Server{Port: ":28796", Transport: "http", DrainTimeout: Duration(10 * time.Minute)},
+		Models: Models{Dir: "./models", Default: "medium"},
+		Media: Media{
+			Dir:            "./whisper_media",
+			MaxBytes:       4 << 30,
+			ConnectTimeout: Duration(30 * time.Second),
+			IdleTimeout:    Duration(60 * time.Second),
+			YtDlpBin:       "yt-dlp",
+			YtDlpTimeout:   Duration(30 * time.Minute),
+		},
+		Limits:  Limits{MaxInputFiles: 1000, RateBurst: 10, MaxConcurrentJobs: 1, MaxQueuedJobs: 100},
+		Cache:   Cache{Enabled: true, TranscriptsMax: 1000},
+		Webhook: Webhook{MaxAttempts: 5, Timeout: Duration(10 * time.Second)},
+		Tracing: Tracing{Exporter: "none"},
+		Log:     Log{Level: "info", Format: "text"},
+	}
+}
+
+// Load 依次叠加默认值、配置文件（path 为空时跳过）与环境变量；命令行参数由调用方覆盖后再 Validate
+func Load(path string) (*Config, error) {
+	cfg := Default()
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+	// 允许只写端口号（PORT=28796）
+	if _, err := strconv.Atoi(cfg.Server.Port); err == nil {
+		cfg.Server.Port = ":" + cfg.Server.Port
+	}
+	return cfg, nil
+}
+
+// loadFile 按扩展名解析配置文件，未知字段视为错误
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config: %w", err)
+	}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err = dec.Decode(c)
+		if errors.Is(err, io.EOF) { // 空文件
+			err = nil
+		}
+	case ".toml":
+		dec := toml.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(c)
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(c)
+	default:
+		return fmt.Errorf("config %s: unsupported format %q (.yaml, .yml, .toml, .json)", path, ext)
+	}
+	if err != nil {
+		return fmt.Errorf("parse config %s: %w", path, err)
+	}
+	return nil
+}
+
+// applyEnv 环境变量覆盖；无法解析的值汇总为错误返回，其余项照常生效
+func (c *Config) applyEnv() error {
+	var e envReader
+	e.str(&c.Server.Port, "PORT")
+	e.str(&c.Server.Transport, "MCP_TRANSPORT")
+	e.duration(&c.Server.ShutdownDelay, "SHUTDOWN_DELAY")
+	e.duration(&c.Server.DrainTimeout, "DRAIN_TIMEOUT")
+	e.list(&c.Server.TrustedProxies, "TRUSTED_PROXIES")
+
+	e.str(&c.Models.Dir, "MODELS_DIR")
+	e.str(&c.Models.Registry, "MODELS_REGISTRY")
+	e.str(&c.Models.ImportDir, "MODELS_IMPORT_DIR")
+	e.str(&c.Models.Default, "WHISPER_MODEL")
+	e.bool(&c.Models.Offline, "WHISPER_OFFLINE")
+
+	e.str(&c.Media.Dir, "MEDIA_DIR")
+	e.str(&c.Media.OutputDir, "OUTPUT_DIR")
+	e.int64(&c.Media.MaxBytes, "MEDIA_MAX_BYTES")
+	e.duration(&c.Media.ConnectTimeout, "MEDIA_CONNECT_TIMEOUT")
+	e.duration(&c.Media.IdleTimeout, "MEDIA_IDLE_TIMEOUT")
+	e.duration(&c.Media.TotalTimeout, "MEDIA_TOTAL_TIMEOUT")
+	e.str(&c.Media.YtDlpBin, "YTDLP_BIN")
+	e.list(&c.Media.YtDlpHosts, "YTDLP_HOSTS")
+	e.duration(&c.Media.YtDlpTimeout, "YTDLP_TIMEOUT")
+
+	e.int(&c.Limits.MaxInputFiles, "MAX_INPUT_FILES")
+	e.duration(&c.Limits.MaxRequestDuration, "MAX_REQUEST_DURATION")
+	e.int(&c.Limits.MaxQueueDepth, "MAX_QUEUE_DEPTH")
+	e.float(&c.Limits.RateLimit, "RATE_LIMIT")
+	e.int(&c.Limits.RateBurst, "RATE_BURST")
+	e.int(&c.Limits.MaxConcurrentJobs, "MAX_CONCURRENT_JOBS")
+	e.int(&c.Limits.MaxQueuedJobs, "MAX_QUEUED_JOBS")
+
+	if s := os.Getenv("API_KEYS"); len(s) > 0 {
+		c.Auth.APIKeys = parseAPIKeys(s)
+	}
+
+	e.bool(&c.Cache.Enabled, "CACHE_ENABLED")
+	e.str(&c.Cache.TranscriptsDir, "TRANSCRIPTS_DIR")
+	e.int(&c.Cache.TranscriptsMax, "TRANSCRIPTS_MAX")
+
+	e.str(&c.Decode.Language, "WHISPER_LANG")
+	e.int(&c.Decode.Threads, "WHISPER_THREADS")
+	e.int(&c.Decode.BeamSize, "WHISPER_BEAM_SIZE")
+	e.str(&c.Decode.DenoiseModel, "ARNNDN_MODEL")
+
+	e.list(&c.Webhook.AllowedHosts, "WEBHOOK_ALLOWED_HOSTS")
+	e.int(&c.Webhook.MaxAttempts, "WEBHOOK_MAX_ATTEMPTS")
+	e.duration(&c.Webhook.Timeout, "WEBHOOK_TIMEOUT")
+
+	e.str(&c.S3.Endpoint, "S3_ENDPOINT")
+	e.str(&c.S3.AccessKey, "S3_ACCESS_KEY", "AWS_ACCESS_KEY_ID")
+	e.str(&c.S3.SecretKey, "S3_SECRET_KEY", "AWS_SECRET_ACCESS_KEY")
+	e.str(&c.S3.SessionToken, "S3_SESSION_TOKEN", "AWS_SESSION_TOKEN")
+	e.str(&c.S3.Region, "S3_REGION", "AWS_REGION")
+	if s := os.Getenv("S3_USE_SSL"); len(s) > 0 {
+		var v bool
+		e.bool(&v, "S3_USE_SSL")
+		c.S3.UseSSL = &v
+	}
+
+	e.str(&c.Tracing.Exporter, "OTEL_TRACES_EXPORTER")
+
+	e.str(&c.Log.Level, "LOG_LEVEL")
+	e.str(&c.Log.Format, "LOG_FORMAT")
+	// 历史语义：NATIVE_LOG_SILENT=0 时打开 native 日志，其余值静音
+	if s := os.Getenv("NATIVE_LOG_SILENT"); len(s) > 0 {
+		c.Log.Native = s == "0"
+	}
+	return errors.Join(e.errs...)
+}
+
+// parseAPIKeys 解析 name:key 或 key（逗号分隔），未命名的按顺序命名为 key1、key2…
+func parseAPIKeys(s string) []APIKey {
+	var keys []APIKey
+	for i, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		name, key, ok := strings.Cut(item, ":")
+		if !ok {
+			name, key = "key"+strconv.Itoa(i+1), item
+		}
+		keys = append(keys, APIKey{Name: strings.TrimSpace(name), Key: strings.TrimSpace(key)})
+	}
+	return keys
+}
+
+// Validate 检查配置，所有问题一次性返回
+func (c *Config) Validate() error {
+	var errs []error
+	fail := func(field, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: %s", field, fmt.Sprintf(format, args...)))
+	}
+
+	if _, port, err := net.SplitHostPort(c.Server.Port); err != nil {
+		fail("server.port", "%v", err)
+	} else if n, err := strconv.Atoi(port); err != nil || n < 0 || n > 65535 {
+		fail("server.port", "invalid port %q", port)
+	}
+	switch c.Server.Transport {
+	case "http", "stdio", "both":
+	default:
+		fail("server.transport", "must be http, stdio or both, got %q", c.Server.Transport)
+	}
+	for i, p := range c.Server.TrustedProxies {
+		if net.ParseIP(p) == nil {
+			if _, _, err := net.ParseCIDR(p); err != nil {
+				fail(fmt.Sprintf("server.trusted_proxies[%d]", i), "must be an IP or CIDR, got %q", p)
+			}
+		}
+	}
+
+	if c.Models.Dir == "" {
+		fail("models.dir", "must not be empty")
+	}
+	if c.Models.Default == "" {
+		fail("models.default", "must not be empty")
+	}
+
+	if c.Media.Dir == "" {
+		fail("media.dir", "must not be empty")
+	}
+	if c.Media.MaxBytes < 0 {
+		fail("media.max_bytes", "must be >= 0")
+	}
+	for name, d := range map[string]Duration{
+		"server.shutdown_delay":       c.Server.ShutdownDelay,
+		"server.drain_timeout":        c.Server.DrainTimeout,
+		"media.connect_timeout":       c.Media.ConnectTimeout,
+		"media.idle_timeout":          c.Media.IdleTimeout,
+		"media.total_timeout":         c.Media.TotalTimeout,
+		"media.ytdlp_timeout":         c.Media.YtDlpTimeout,
+		"limits.max_request_duration": c.Limits.MaxRequestDuration,
+	} {
+		if d < 0 {
+			fail(name, "must be >= 0")
+		}
+	}
+
+	if c.Limits.MaxInputFiles <= 0 {
+		fail("limits.max_input_files", "must be > 0")
+	}
+	if c.Limits.MaxQueueDepth < 0 {
+		fail("limits.max_queue_depth", "must be >= 0")
+	}
+	if c.Limits.RateLimit < 0 {
+		fail("limits.rate_limit", "must be >= 0")
+	}
+	if c.Limits.RateLimit > 0 && c.Limits.RateBurst < 1 {
+		fail("limits.rate_burst", "must be >= 1 when rate_limit is set")
+	}
+	if c.Limits.MaxConcurrentJobs < 1 {
+		fail("limits.max_concurrent_jobs", "must be >= 1")
+	}
+	if c.Limits.MaxQueuedJobs < 1 {
+		fail("limits.max_queued_jobs", "must be >= 1")
+	}
+	if c.Cache.TranscriptsMax < 0 {
+		fail("cache.transcripts_max", "must be >= 0")
+	}
+
+	names, keys := map[string]bool{}, map[string]bool{}
+	for i, k := range c.Auth.APIKeys {
+		field := fmt.Sprintf("auth.api_keys[%d]", i)
+		switch {
+		case k.Name == "":
+			fail(field+".name", "must not be empty")
+		case names[k.Name]:
+			fail(field+".name", "duplicate name %q", k.Name)
+		}
+		switch {
+		case k.Key == "":
+			fail(field+".key", "must not be empty")
+		case keys[k.Key]:
+			fail(field+".key", "duplicate key")
+		}
+		names[k.Name], keys[k.Key] = true, true
+	}
+
+	if c.Decode.Threads < 0 {
+		fail("decode.threads", "must be >= 0")
+	}
+	if c.Decode.BeamSize < 0 {
+		fail("decode.beam_size", "must be >= 0")
+	}
+	if c.Decode.DenoiseModel != "" {
+		if fi, err := os.Stat(c.Decode.DenoiseModel); err != nil {
+			fail("decode.denoise_model", "%v", err)
+		} else if !fi.Mode().IsRegular() {
+			fail("decode.denoise_model", "%s is not a regular file", c.Decode.DenoiseModel)
+		}
+	}
+
+	if c.Webhook.MaxAttempts < 1 {
+		fail("webhook.max_attempts", "must be >= 1")
+	}
+	if c.Webhook.Timeout <= 0 {
+		fail("webhook.timeout", "must be > 0")
+	}
+
+	switch c.Tracing.Exporter {
+	case "", "none", "otlp":
+	default:
+		fail("tracing.exporter", "must be otlp or none, got %q", c.Tracing.Exporter)
+	}
+
+	if !logging.ValidLevel(c.Log.Level) {
+		fail("log.level", "must be trace, debug, info, warn or error, got %q", c.Log.Level)
+	}
+	switch c.Log.Format {
+	case "", logging.FormatText, logging.FormatJSON:
+	default:
+		fail("log.format", "must be text or json, got %q", c.Log.Format)
+	}
+	return errors.Join(errs...)
+}
+
+// restartRequired 变更后需重启才生效的配置项（前缀匹配）：监听地址、传输方式、受信代理、链路追踪、回调发送器、转录存储目录与任务并发数在启动时确定
+var restartRequired = []string{"server.port", "server.transport", "server.trusted_proxies", "tracing.", "webhook.", "cache.transcripts_dir", "limits.max_concurrent_jobs"}
+
+// RequiresRestart key（如 server.port）变更后是否需要重启才生效
+func RequiresRestart(key string) bool {
+	for _, p := range restartRequired {
+		if strings.HasPrefix(key, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Diff 列出 a、b 之间不同的配置项，以配置文件中的键名表示（如 limits.rate_limit）
+func Diff(a, b *Config) []string {
+	var keys []string
+	diffFields(reflect.ValueOf(*a), reflect.ValueOf(*b), "", &keys)
+	return keys
+}
+
+func diffFields(a, b reflect.Value, prefix string, keys *[]string) {
+	t := a.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		key := strings.Split(f.Tag.Get("yaml"), ",")[0]
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		if f.Type.Kind() == reflect.Struct {
+			diffFields(a.Field(i), b.Field(i), key, keys)
+			continue
+		}
+		if !reflect.DeepEqual(a.Field(i).Interface(), b.Field(i).Interface()) {
+			*keys = append(*keys, key)
+		}
+	}
+}
+
+const redacted = "******"
+
+// Redacted 返回隐去密钥的副本，用于展示生效配置
+func (c *Config) Redacted() *Config {
+	r := *c
+	r.Auth.APIKeys = make([]APIKey, len(c.Auth.APIKeys))
+	for i, k := range c.Auth.APIKeys {
+		r.Auth.APIKeys[i] = APIKey{Name: k.Name, Key: redact(k.Key)}
+	}
+	r.S3.SecretKey = redact(c.S3.SecretKey)
+	r.S3.SessionToken = redact(c.S3.SessionToken)
+	return &r
+}
+
+func redact(s string) string {
+	if s == "" {
+		return ""
+	}
+	return redacted
+}
+
+var current atomic.Pointer[Config]
+
+// Set 替换当前生效的配置
+func Set(cfg *Config) {
+	current.Store(cfg)
+}
+
+// Get 当前生效的配置；未调用 Set 时使用默认值与环境变量（忽略无法解析的项）
+func Get() *Config {
+	if cfg := current.Load(); cfg != nil {
+		return cfg
+	}
+	cfg := Default()
+	_ = cfg.applyEnv()
+	current.CompareAndSwap(nil, cfg)
+	return current.Load()
+}
+
+// envReader 读取并解析环境变量，记录解析失败的项
+type envReader struct {
+	errs []error
+}
+
+func (e *envReader) lookup(keys ...string) (string, string, bool) {
+	for _, k := range keys {
+		if s := os.Getenv(k); len(s) > 0 {
+			return k, s, true
+		}
+	}
+	return "", "", false
+}
+
+func (e *envReader) fail(key, s string, err error) {
+	e.errs = append(e.errs, fmt.Errorf("%s=%q: %w", key, s, err))
+}
+
+func (e *envReader) str(dst *string, keys ...string) {
+	if _, s, ok := e.lookup(keys...); ok {
+		*dst = s
+	}
+}
+
+func (e *envReader) int(dst *int, key string) {
+	if _, s, ok := e.lookup(key); ok {
+		v, err := strconv.Atoi(s)
+		if err != nil {
+			e.fail(key, s, err)
+			return
+		}
+		*dst = v
+	}
+}
+
+func (e *envReader) int64(dst *int64, key string) {
+	if _, s, ok := e.lookup(key); ok {
+		v, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			e.fail(key, s, err)
+			return
+		}
+		*dst = v
+	}
+}
+
+func (e *envReader) float(dst *float64, key string) {
+	if _, s, ok := e.lookup(key); ok {
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			e.fail(key, s, err)
+			return
+		}
+		*dst = v
+	}
+}
+
+// bool 接受 1/0、true/false、yes/no
+func (e *envReader) bool(dst *bool, key string) {
+	if _, s, ok := e.lookup(key); ok {
+		switch strings.ToLower(s) {
+		case "1", "true", "yes", "on":
+			*dst = true
+		case "0", "false", "no", "off":
+			*dst = false
+		default:
+			e.fail(key, s, errors.New("expected true or false"))
+		}
+	}
+}
+
+func (e *envReader) duration(dst *Duration, key string) {
+	if _, s, ok := e.lookup(key); ok {
+		var d Duration
+		if err := d.UnmarshalText([]byte(s)); err != nil {
+			e.fail(key, s, err)
+			return
+		}
+		*dst = d
+	}
+}
+
+// list 逗号分隔，忽略空项
+func (e *envReader) list(dst *[]string, key string) {
+	if _, s, ok := e.lookup(key); ok {
+		var out []string
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				out = append(out, item)
+			}
+		}
+		*dst = out
+	}
+}
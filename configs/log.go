@@ -0,0 +1,6 @@
+package configs
+
+// GetLogNative 是否输出 whisper.cpp / ggml 的 info 与 debug 日志（log.native / NATIVE_LOG_SILENT=0，默认否）
+func GetLogNative() bool {
+	return Get().Log.Native
+}
@@ -1,13 +1,85 @@
 package configs
 
 import (
-	"os"
+	"path/filepath"
+	"time"
 )
 
+// GetMediaPath 网络媒体下载目录（media.dir / MEDIA_DIR，默认 ./whisper_media）
 func GetMediaPath() string {
-	mediaDir := "./whisper_media"
-	if s := os.Getenv("MEDIA_DIR"); len(s) > 0 {
-		mediaDir = s
+	return Get().Media.Dir
+}
+
+// GetMediaMaxBytes 单个网络媒体的大小上限（media.max_bytes / MEDIA_MAX_BYTES，默认 4GiB，0 表示不限制）
+func GetMediaMaxBytes() int64 {
+	return Get().Media.MaxBytes
+}
+
+// GetMediaConnectTimeout 建连 + 等待响应头超时（media.connect_timeout / MEDIA_CONNECT_TIMEOUT，默认 30s）
+func GetMediaConnectTimeout() time.Duration {
+	return time.Duration(Get().Media.ConnectTimeout)
+}
+
+// GetMediaIdleTimeout 传输中连续无数据的超时（media.idle_timeout / MEDIA_IDLE_TIMEOUT，默认 60s）
+func GetMediaIdleTimeout() time.Duration {
+	return time.Duration(Get().Media.IdleTimeout)
+}
+
+// GetMediaTotalTimeout 单个下载的总超时（media.total_timeout / MEDIA_TOTAL_TIMEOUT，默认 0 不限制）
+func GetMediaTotalTimeout() time.Duration {
+	return time.Duration(Get().Media.TotalTimeout)
+}
+
+// GetModelsImportDir HTTP 模型导入的来源目录（models.import_dir / MODELS_IMPORT_DIR，默认空：只能用命令行导入）
+func GetModelsImportDir() string {
+	return Get().Models.ImportDir
+}
+
+// GetOutputDir HTTP / 任务请求写本地输出的根目录（media.output_dir / OUTPUT_DIR，默认空：只允许 s3:// 目的地）
+func GetOutputDir() string {
+	return Get().Media.OutputDir
+}
+
+// GetYtDlpBinary yt-dlp 可执行文件（media.ytdlp_bin / YTDLP_BIN，默认从 PATH 查找 yt-dlp）
+func GetYtDlpBinary() string {
+	return Get().Media.YtDlpBin
+}
+
+// GetYtDlpHosts 交给 yt-dlp 的站点域名（media.ytdlp_hosts / YTDLP_HOSTS；为空使用内置列表）
+func GetYtDlpHosts() []string {
+	return Get().Media.YtDlpHosts
+}
+
+// GetYtDlpTimeout 单次 yt-dlp 抽取超时（media.ytdlp_timeout / YTDLP_TIMEOUT，默认 30m）
+func GetYtDlpTimeout() time.Duration {
+	return time.Duration(Get().Media.YtDlpTimeout)
+}
+
+// GetMaxInputFiles 目录 / glob 展开后单个请求的文件数上限（limits.max_input_files / MAX_INPUT_FILES，默认 1000）
+func GetMaxInputFiles() int {
+	return Get().Limits.MaxInputFiles
+}
+
+// GetTranscriptsDir 转录结果存储目录（cache.transcripts_dir / TRANSCRIPTS_DIR，默认 <MEDIA_DIR>/transcripts）
+func GetTranscriptsDir() string {
+	if dir := Get().Cache.TranscriptsDir; dir != "" {
+		return dir
 	}
-	return mediaDir
+	return filepath.Join(GetMediaPath(), "transcripts")
+}
+
+// GetTranscriptsMax 保存的转录结果上限（cache.transcripts_max / TRANSCRIPTS_MAX，默认 1000，0 不限制）
+func GetTranscriptsMax() int {
+	return Get().Cache.TranscriptsMax
+}
+
+// GetMaxQueueDepth 就绪检查的排队上限（limits.max_queue_depth / MAX_QUEUE_DEPTH，默认 0 不限制）：未完成的文件数达到该值时 /health/ready 返回未就绪
+func GetMaxQueueDepth() int {
+	return Get().Limits.MaxQueueDepth
+}
+
+// GetMaxRequestDuration 单个转录请求（含下载、解码与推理）的最长耗时（limits.max_request_duration / MAX_REQUEST_DURATION，默认 0 不限制）；
+// 请求中的 timeout_s 只能在此上限内缩短
+func GetMaxRequestDuration() time.Duration {
+	return time.Duration(Get().Limits.MaxRequestDuration)
 }
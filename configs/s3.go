@@ -0,0 +1,41 @@
+package configs
+
+import "strings"
+
+// S3 S3 兼容对象存储（MinIO 等）连接配置
+type S3 struct {
+	Endpoint     string // host:port，不含协议
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+	Region       string
+	UseSSL       bool
+}
+
+// GetS3 S3 连接配置（s3 配置段；环境变量 S3_ENDPOINT、S3_ACCESS_KEY/AWS_ACCESS_KEY_ID、S3_SECRET_KEY/AWS_SECRET_ACCESS_KEY、
+// S3_SESSION_TOKEN/AWS_SESSION_TOKEN、S3_REGION/AWS_REGION、S3_USE_SSL 覆盖），use_ssl 缺省时默认开启
+func GetS3() S3 {
+	c := Get().S3
+	cfg := S3{
+		Endpoint:     c.Endpoint,
+		AccessKey:    c.AccessKey,
+		SecretKey:    c.SecretKey,
+		SessionToken: c.SessionToken,
+		Region:       c.Region,
+		UseSSL:       true,
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = "s3.amazonaws.com"
+	}
+	// 允许直接写 http(s)://host:port
+	if strings.HasPrefix(cfg.Endpoint, "http://") {
+		cfg.Endpoint = strings.TrimPrefix(cfg.Endpoint, "http://")
+		cfg.UseSSL = false
+	} else {
+		cfg.Endpoint = strings.TrimPrefix(cfg.Endpoint, "https://")
+	}
+	if c.UseSSL != nil {
+		cfg.UseSSL = *c.UseSSL
+	}
+	return cfg
+}
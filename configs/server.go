@@ -0,0 +1,36 @@
+package configs
+
+import (
+	"path/filepath"
+	"time"
+)
+
+// GetShutdownDelay 收到退出信号后、停止接收请求前的等待时长（server.shutdown_delay / SHUTDOWN_DELAY），供负载均衡摘除流量
+func GetShutdownDelay() time.Duration {
+	return time.Duration(Get().Server.ShutdownDelay)
+}
+
+// GetDrainTimeout 等待进行中转录结束的最长时长（server.drain_timeout / DRAIN_TIMEOUT，默认 10m），0 表示立即中断
+func GetDrainTimeout() time.Duration {
+	return time.Duration(Get().Server.DrainTimeout)
+}
+
+// GetTrustedProxies 受信任的反向代理（server.trusted_proxies / TRUSTED_PROXIES），缺省为空：客户端 IP 只取连接的对端地址
+func GetTrustedProxies() []string {
+	return Get().Server.TrustedProxies
+}
+
+// GetJobsRequeuePath 关闭时未完成的异步任务保存位置（<media.dir>/.jobs-requeue.json），下次启动重新执行
+func GetJobsRequeuePath() string {
+	return filepath.Join(Get().Media.Dir, ".jobs-requeue.json")
+}
+
+// GetMaxConcurrentJobs 同时执行的异步任务数（limits.max_concurrent_jobs / MAX_CONCURRENT_JOBS，默认 1），启动时确定
+func GetMaxConcurrentJobs() int {
+	return Get().Limits.MaxConcurrentJobs
+}
+
+// GetMaxQueuedJobs 等待执行的异步任务上限（limits.max_queued_jobs / MAX_QUEUED_JOBS，默认 100），超出时拒绝新任务
+func GetMaxQueuedJobs() int {
+	return Get().Limits.MaxQueuedJobs
+}
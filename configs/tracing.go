@@ -0,0 +1,6 @@
+package configs
+
+// GetTracesExporter 链路追踪导出方式（tracing.exporter / OTEL_TRACES_EXPORTER：otlp | none，默认 none 不导出）
+func GetTracesExporter() string {
+	return Get().Tracing.Exporter
+}
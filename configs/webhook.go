@@ -0,0 +1,18 @@
+package configs
+
+import "time"
+
+// GetWebhookAllowedHosts 允许回调的主机（webhook.allowed_hosts / WEBHOOK_ALLOWED_HOSTS，域名后缀匹配，可带端口；为空时拒绝所有回调）
+func GetWebhookAllowedHosts() []string {
+	return Get().Webhook.AllowedHosts
+}
+
+// GetWebhookMaxAttempts 单次回调的最大投递次数（webhook.max_attempts / WEBHOOK_MAX_ATTEMPTS，默认 5）
+func GetWebhookMaxAttempts() int {
+	return Get().Webhook.MaxAttempts
+}
+
+// GetWebhookTimeout 单次投递的超时（webhook.timeout / WEBHOOK_TIMEOUT，默认 10s）
+func GetWebhookTimeout() time.Duration {
+	return time.Duration(Get().Webhook.Timeout)
+}
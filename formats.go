@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"go-whisper-mcp/whisper"
+)
+
+// 输出格式
+const (
+	FormatJSON = "json"
+	FormatSRT  = "srt"
+	FormatVTT  = "vtt"
+	FormatTXT  = "txt"
+)
+
+// formatContentTypes 各输出格式的 Content-Type
+var formatContentTypes = map[string]string{
+	FormatJSON: "application/json",
+	FormatSRT:  "application/x-subrip; charset=utf-8",
+	FormatVTT:  "text/vtt; charset=utf-8",
+	FormatTXT:  "text/plain; charset=utf-8",
+}
+
+// ParseFormats 解析格式列表（支持逗号分隔），去重并校验
+func ParseFormats(list []string) ([]string, error) {
+	var out []string
+	seen := map[string]bool{}
+	for _, item := range list {
+		for _, f := range strings.Split(item, ",") {
+			f = strings.ToLower(strings.TrimSpace(f))
+			if f == "" || seen[f] {
+				continue
+			}
+			if _, ok := formatContentTypes[f]; !ok {
+				return nil, fmt.Errorf("unsupported output format %q (json, srt, vtt, txt)", f)
+			}
+			seen[f] = true
+			out = append(out, f)
+		}
+	}
+	return out, nil
+}
+
+// FormatTranscript 将单个文件的转录结果渲染为指定格式，返回内容与 Content-Type
+func FormatTranscript(r *TranscribeResponse, format string) ([]byte, string, error) {
+	ct, ok := formatContentTypes[format]
+	if !ok {
+		return nil, "", fmt.Errorf("unsupported output format %q", format)
+	}
+	var buf bytes.Buffer
+	switch format {
+	case FormatJSON:
+		data, err := json.MarshalIndent(r, "", "  ")
+		if err != nil {
+			return nil, "", err
+		}
+		buf.Write(data)
+	case FormatSRT:
+		for i, seg := range r.Segments {
+			start, end := segmentBounds(seg)
+			fmt.Fprintf(&buf, "%d\n%s --> %s\n%s\n\n", i+1,
+				subtitleTime(start, ","), subtitleTime(end, ","), strings.TrimSpace(seg.Text))
+		}
+	case FormatVTT:
+		buf.WriteString("WEBVTT\n\n")
+		for _, seg := range r.Segments {
+			start, end := segmentBounds(seg)
+			fmt.Fprintf(&buf, "%s --> %s\n%s\n\n",
+				subtitleTime(start, "."), subtitleTime(end, "."), strings.TrimSpace(seg.Text))
+		}
+	case FormatTXT:
+		buf.WriteString(TranscriptText(r))
+		buf.WriteString("\n")
+	}
+	return buf.Bytes(), ct, nil
+}
+
+// TranscriptText 拼接全部分段文本
+func TranscriptText(r *TranscribeResponse) string {
+	parts := make([]string, 0, len(r.Segments))
+	for _, seg := range r.Segments {
+		if t := strings.TrimSpace(seg.Text); t != "" {
+			parts = append(parts, t)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// segmentBounds 分段起止时间；兼容没有 *_ms 字段的旧 sidecar
+func segmentBounds(seg whisper.TranscribeAudioResult) (time.Duration, time.Duration) {
+	start := time.Duration(seg.StartMs) * time.Millisecond
+	end := time.Duration(seg.EndMs) * time.Millisecond
+	if start == 0 && seg.Start != "" {
+		start, _ = time.ParseDuration(seg.Start)
+	}
+	if end == 0 && seg.End != "" {
+		end, _ = time.ParseDuration(seg.End)
+	}
+	return start, end
+}
+
+// subtitleTime 格式化为 HH:MM:SS<sep>mmm
+func subtitleTime(d time.Duration, sep string) string {
+	if d < 0 {
+		d = 0
+	}
+	ms := d.Milliseconds()
+	h := ms / 3600000
+	m := ms % 3600000 / 60000
+	s := ms % 60000 / 1000
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", h, m, s, sep, ms%1000)
+}
@@ -1,11 +1,19 @@
 package main
 
 import (
+	"errors"
 	"github.com/gin-gonic/gin"
-	"github.com/sirupsen/logrus"
+	"go-whisper-mcp/configs"
+	"go-whisper-mcp/pkg"
+	"go-whisper-mcp/pkg/downloader"
+	"go-whisper-mcp/pkg/logging"
+	"go-whisper-mcp/pkg/sink"
 	"net/http"
 )
 
+// statusClientClosedRequest 调用方已断开（沿用 nginx 的 499）
+const statusClientClosedRequest = 499
+
 // respondError 返回错误响应
 func respondError(c *gin.Context, statusCode int, code, message string, details any) {
 	response := ErrorResponse{
@@ -14,7 +22,7 @@ func respondError(c *gin.Context, statusCode int, code, message string, details
 		Details: details,
 	}
 
-	logrus.Errorf("%s %s %s %d", c.Request.Method, c.Request.URL.Path,
+	logging.FromContext(c.Request.Context()).Errorf("%s %s %s %d", c.Request.Method, c.Request.URL.Path,
 		c.GetString("account"), statusCode)
 
 	c.JSON(statusCode, response)
@@ -28,7 +36,7 @@ func respondSuccess(c *gin.Context, data any, message string) {
 		Message: message,
 	}
 
-	logrus.Infof("%s %s %s %d", c.Request.Method, c.Request.URL.Path,
+	logging.FromContext(c.Request.Context()).Infof("%s %s %s %d", c.Request.Method, c.Request.URL.Path,
 		c.GetString("account"), http.StatusOK)
 
 	c.JSON(http.StatusOK, response)
@@ -44,10 +52,65 @@ func handleTranscribe(a *AppServer) gin.HandlerFunc {
 		}
 
 		if len(req.Model) == 0 {
-			req.Model = a.defaultModel
+			req.Model = a.defaultModel()
+		}
+		if err := req.Preprocess.Validate(); err != nil {
+			respondError(c, http.StatusBadRequest, "INVALID_PREPROCESS", "invalid preprocess options", err.Error())
+			return
+		}
+		if err := req.validateAudioStream(); err != nil {
+			respondError(c, http.StatusBadRequest, "INVALID_AUDIO_STREAM", "invalid audio stream", err.Error())
+			return
+		}
+		if _, _, err := req.outputSink(); errors.Is(err, sink.ErrDestNotAllowed) {
+			respondError(c, http.StatusForbidden, "OUTPUT_NOT_ALLOWED", "output destination not allowed", err.Error())
+			return
+		} else if err != nil {
+			respondError(c, http.StatusBadRequest, "INVALID_OUTPUT", "invalid output options", err.Error())
+			return
+		}
+
+		// 带回调地址：转为异步任务，立即返回任务 ID
+		if req.CallbackURL != "" {
+			job, err := a.jobs.Submit(c.Request.Context(), &req)
+			if errors.Is(err, ErrShuttingDown) {
+				respondError(c, http.StatusServiceUnavailable, "SHUTTING_DOWN", "server is shutting down", err.Error())
+				return
+			}
+			if errors.Is(err, ErrJobQueueFull) {
+				c.Header("Retry-After", "30")
+				respondError(c, http.StatusServiceUnavailable, "QUEUE_FULL", "too many queued jobs", err.Error())
+				return
+			}
+			if errors.Is(err, ErrWebhookNotAllowed) {
+				respondError(c, http.StatusBadRequest, "CALLBACK_NOT_ALLOWED", "callback url not allowed", err.Error())
+				return
+			}
+			if err != nil {
+				respondError(c, http.StatusInternalServerError, "JOB_SUBMIT_FAILED", "failed to submit job", err.Error())
+				return
+			}
+			logging.FromContext(c.Request.Context()).Infof("%s %s %d %s", c.Request.Method, c.Request.URL.Path, http.StatusAccepted, job.ID)
+			c.JSON(http.StatusAccepted, SuccessResponse{Success: true, Data: job, Message: "accepted"})
+			return
 		}
 
 		out, err := a.whisperService.Transcribe(c.Request.Context(), &req)
+		switch transcribeErrorCode(err) {
+		case "SHUTTING_DOWN":
+			respondError(c, http.StatusServiceUnavailable, "SHUTTING_DOWN", "server is shutting down", err.Error())
+			return
+		case "CANCELLED":
+			respondError(c, statusClientClosedRequest, "CANCELLED", "transcription cancelled", err.Error())
+			return
+		case "TIMEOUT":
+			respondError(c, http.StatusGatewayTimeout, "TIMEOUT", "transcription timed out", err.Error())
+			return
+		}
+		if errors.Is(err, downloader.ErrTooManyFiles) {
+			respondError(c, http.StatusBadRequest, "TOO_MANY_FILES", "too many input files", err.Error())
+			return
+		}
 		if err != nil {
 			respondError(c, http.StatusInternalServerError, "TranscribeError", "transcription failed", err.Error())
 			return
@@ -57,12 +120,137 @@ func handleTranscribe(a *AppServer) gin.HandlerFunc {
 	}
 }
 
-// healthHandler 健康检查
-func healthHandler(c *gin.Context) {
-	respondSuccess(c, map[string]any{
-		"status":    "healthy",
-		"service":   "go-whisper-mcp",
-		"account":   "ai-report",
-		"timestamp": "now",
-	}, "服务正常")
+// handleInspect 列出媒体文件的音频流（序号、语言、编码），用于选择 audio_stream
+func handleInspect(a *AppServer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req InspectRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondError(c, http.StatusBadRequest, "INVALID_REQUEST",
+				"请求参数错误", err.Error())
+			return
+		}
+
+		info, err := a.whisperService.Inspect(c.Request.Context(), req.InPath)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "InspectError", "media inspection failed", err.Error())
+			return
+		}
+
+		respondSuccess(c, info, "ok")
+	}
+}
+
+// handleModelImport 导入本地 ggml/gguf 模型到 MODELS_DIR 并登记到注册表
+func handleModelImport(a *AppServer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req ModelImportRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondError(c, http.StatusBadRequest, "INVALID_REQUEST",
+				"请求参数错误", err.Error())
+			return
+		}
+
+		src, err := pkg.ResolveImportSource(configs.GetModelsImportDir(), req.SrcPath)
+		if errors.Is(err, pkg.ErrImportNotAllowed) {
+			respondError(c, http.StatusForbidden, "IMPORT_NOT_ALLOWED", "model import source not allowed", err.Error())
+			return
+		}
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "ModelImportError", "model import failed", err.Error())
+			return
+		}
+
+		entry, err := pkg.ImportModel(c.Request.Context(), a.modelsDir(), src, pkg.ModelEntry{
+			Name:      req.Name,
+			Path:      req.Filename,
+			URL:       req.URL,
+			Languages: req.Languages,
+			SHA256:    req.SHA256,
+			Defaults:  req.Defaults,
+		}, req.Overwrite)
+		if errors.Is(err, pkg.ErrModelExists) {
+			respondError(c, http.StatusConflict, "MODEL_EXISTS", "model already exists", err.Error())
+			return
+		}
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "ModelImportError", "model import failed", err.Error())
+			return
+		}
+
+		respondSuccess(c, entry, "ok")
+	}
+}
+
+func handleJobGet(a *AppServer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		job, ok := a.jobs.Get(c.Param("id"))
+		if !ok {
+			respondError(c, http.StatusNotFound, "JOB_NOT_FOUND", "job not found", c.Param("id"))
+			return
+		}
+		respondSuccess(c, job, "ok")
+	}
+}
+
+// handleJobCancel 取消排队或运行中的任务，推理在下一个编码窗口前中止
+func handleJobCancel(a *AppServer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		job, err := a.jobs.Cancel(c.Param("id"))
+		if errors.Is(err, ErrJobNotFound) {
+			respondError(c, http.StatusNotFound, "JOB_NOT_FOUND", "job not found", c.Param("id"))
+			return
+		}
+		if errors.Is(err, ErrJobFinished) {
+			respondError(c, http.StatusConflict, "JOB_FINISHED", "job already finished", job)
+			return
+		}
+		respondSuccess(c, job, "cancelling")
+	}
+}
+
+// handleConfigGet 生效配置（密钥已隐去）
+func handleConfigGet(c *gin.Context) {
+	respondSuccess(c, configs.Get().Redacted(), "ok")
+}
+
+// handleConfigReload 重新加载配置文件与模型注册表（同 SIGHUP）；失败时保留当前配置
+func handleConfigReload(a *AppServer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if a.reloader == nil {
+			respondError(c, http.StatusNotImplemented, "RELOAD_UNAVAILABLE", "config reload is not enabled", nil)
+			return
+		}
+		res, err := a.reloader.Reload("api")
+		if err != nil {
+			respondError(c, http.StatusUnprocessableEntity, "RELOAD_FAILED", "config reload failed, current config kept", err.Error())
+			return
+		}
+		respondSuccess(c, res, "reloaded")
+	}
+}
+
+// LogLevelRequest PUT /admin/log/level 请求体
+type LogLevelRequest struct {
+	Level string `json:"level" binding:"required"` // trace | debug | info | warn | error
+}
+
+// handleLogLevelGet 当前日志级别
+func handleLogLevelGet(c *gin.Context) {
+	respondSuccess(c, map[string]string{"level": logging.Level()}, "ok")
+}
+
+// handleLogLevelSet 运行时调整日志级别，立即生效；重新加载配置时恢复为 log.level
+func handleLogLevelSet(c *gin.Context) {
+	var req LogLevelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, "INVALID_REQUEST", "请求参数错误", err.Error())
+		return
+	}
+	previous := logging.Level()
+	if err := logging.SetLevel(req.Level); err != nil {
+		respondError(c, http.StatusBadRequest, "INVALID_LOG_LEVEL", "invalid log level", err.Error())
+		return
+	}
+	logging.FromContext(c.Request.Context()).Warnf("日志级别已调整: %s -> %s", previous, logging.Level())
+	respondSuccess(c, map[string]string{"level": logging.Level(), "previous": previous}, "updated")
 }
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go-whisper-mcp/configs"
+	"go-whisper-mcp/pkg"
+	"go-whisper-mcp/whisper"
+)
+
+// 健康检查项状态
+const (
+	checkOK   = "ok"
+	checkFail = "fail"
+)
+
+// HealthCheck 单项检查结果
+type HealthCheck struct {
+	Status string `json:"status"` // ok | fail
+	Detail string `json:"detail,omitempty"`
+}
+
+// QueueStatus 转录队列水位
+type QueueStatus struct {
+	Depth      int64   `json:"depth"`                // 已接收、尚未完成的文件数
+	Limit      int     `json:"limit,omitempty"`      // MAX_QUEUE_DEPTH，0 不限制
+	Saturation float64 `json:"saturation,omitempty"` // depth / limit
+}
+
+// HealthReport /health/ready 的检查结果
+type HealthReport struct {
+	Ready        bool                   `json:"ready"`
+	Version      string                 `json:"version"`
+	Uptime       string                 `json:"uptime"`
+	Checks       map[string]HealthCheck `json:"checks"`
+	Queue        QueueStatus            `json:"queue"`
+	LoadedModels map[string]int         `json:"loaded_models"` // 模型路径 -> 正在使用它的推理数
+	SystemInfo   string                 `json:"system_info"`   // whisper.cpp / ggml 编译特性
+	Timestamp    time.Time              `json:"timestamp"`
+}
+
+var startedAt = time.Now()
+
+// handleHealthLive 存活检查：进程能响应即为存活，不检查依赖
+func handleHealthLive(c *gin.Context) {
+	respondSuccess(c, map[string]any{
+		"status":    "alive",
+		"service":   "go-whisper-mcp",
+		"version":   version,
+		"uptime":    time.Since(startedAt).Truncate(time.Second).String(),
+		"timestamp": time.Now(),
+	}, "服务正常")
+}
+
+// handleHealthReady 就绪检查：依赖齐全、未在关闭且队列未满时返回 200，否则 503
+func handleHealthReady(a *AppServer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		report := a.healthReport()
+		if !report.Ready {
+			respondError(c, http.StatusServiceUnavailable, "NOT_READY", "service not ready", report)
+			return
+		}
+		respondSuccess(c, report, "ready")
+	}
+}
+
+// healthReport 执行全部就绪检查
+func (a *AppServer) healthReport() *HealthReport {
+	r := &HealthReport{
+		Ready:        true,
+		Version:      version,
+		Uptime:       time.Since(startedAt).Truncate(time.Second).String(),
+		Checks:       map[string]HealthCheck{},
+		LoadedModels: whisper.LoadedModels(),
+		SystemInfo:   whisper.SystemInfo(),
+		Timestamp:    time.Now(),
+	}
+	set := func(name string, err error, detail string) {
+		if err != nil {
+			r.Ready = false
+			r.Checks[name] = HealthCheck{Status: checkFail, Detail: err.Error()}
+			return
+		}
+		r.Checks[name] = HealthCheck{Status: checkOK, Detail: detail}
+	}
+
+	if a.draining.Load() {
+		r.Ready = false
+		r.Checks["shutdown"] = HealthCheck{Status: checkFail, Detail: "server is shutting down"}
+	} else {
+		r.Checks["shutdown"] = HealthCheck{Status: checkOK}
+	}
+
+	set("ffmpeg", pkg.EnsureFFmpeg(), "")
+	models := configs.Get().Models
+	set("models_dir", checkDirWritable(models.Dir), models.Dir)
+
+	localPath, installed, err := pkg.CheckModelAvailable(models.Dir, models.Default)
+	detail := "downloadable: " + models.Default
+	if installed {
+		detail = "installed: " + localPath
+	}
+	set("default_model", err, detail)
+
+	r.Queue = QueueStatus{Depth: a.whisperService.QueueDepth(), Limit: configs.GetMaxQueueDepth()}
+	if r.Queue.Limit > 0 {
+		r.Queue.Saturation = float64(r.Queue.Depth) / float64(r.Queue.Limit)
+		if r.Queue.Depth >= int64(r.Queue.Limit) {
+			r.Ready = false
+			r.Checks["queue"] = HealthCheck{Status: checkFail, Detail: "queue is full"}
+		} else {
+			r.Checks["queue"] = HealthCheck{Status: checkOK}
+		}
+	}
+	return r
+}
+
+// checkDirWritable 目录可创建且可写入（写入并删除一个临时文件）
+func checkDirWritable(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, ".health-*")
+	if err != nil {
+		return err
+	}
+	name := f.Name()
+	_ = f.Close()
+	return os.Remove(name)
+}
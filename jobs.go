@@ -0,0 +1,426 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go-whisper-mcp/configs"
+	"go-whisper-mcp/pkg/logging"
+)
+
+// JobStatus 异步任务状态
+type JobStatus string
+
+const (
+	JobQueued    JobStatus = "queued"
+	JobRunning   JobStatus = "running"
+	JobSucceeded JobStatus = "succeeded"
+	JobFailed    JobStatus = "failed"
+	JobCancelled JobStatus = "cancelled"
+)
+
+// 任务操作错误
+var (
+	ErrJobNotFound  = errors.New("job not found")
+	ErrJobFinished  = errors.New("job already finished")
+	ErrJobQueueFull = errors.New("job queue full")
+)
+
+// 回调事件
+const (
+	EventTranscriptionCompleted = "transcription.completed"
+	EventTranscriptionFailed    = "transcription.failed"
+	EventTranscriptionCancelled = "transcription.cancelled"
+)
+
+// jobRetention 已结束任务的保留时长
+const jobRetention = 24 * time.Hour
+
+// jobAbortGrace 排空超时中断任务后，等待任务收尾（含进行中的回调投递）的时长
+const jobAbortGrace = 10 * time.Second
+
+// Job 异步转录任务
+type Job struct {
+	ID         string                   `json:"id"`
+	Status     JobStatus                `json:"status"`
+	Inputs     []string                 `json:"inputs"`
+	CreatedAt  time.Time                `json:"created_at"`
+	StartedAt  *time.Time               `json:"started_at,omitempty"`
+	FinishedAt *time.Time               `json:"finished_at,omitempty"`
+	Result     *TranscribeBatchResponse `json:"result,omitempty"`
+	Error      string                   `json:"error,omitempty"`
+	ErrorCode  string                   `json:"error_code,omitempty"` // CANCELLED / TIMEOUT
+	Webhook    *WebhookDelivery         `json:"webhook,omitempty"`
+}
+
+// WebhookPayload 回调请求体
+type WebhookPayload struct {
+	Event  string                   `json:"event"`
+	JobID  string                   `json:"job_id"`
+	Status JobStatus                `json:"status"`
+	Result *TranscribeBatchResponse `json:"result,omitempty"`
+	Error  string                   `json:"error,omitempty"`
+	Code   string                   `json:"error_code,omitempty"`
+}
+
+// JobManager 异步任务管理：后台执行转录（同时执行的数量受限，其余排队），结束后投递回调
+type JobManager struct {
+	svc         *WhisperService
+	webhooks    *WebhookSender
+	requeuePath string        // 关闭时未完成任务的保存位置，为空时不保存
+	slots       chan struct{} // 执行槽：每个任务各自加载模型，限制同时转录的任务数
+
+	mu       sync.RWMutex
+	jobs     map[string]*Job
+	cancels  map[string]context.CancelFunc // 未结束任务的取消函数
+	waiting  int                           // 排队等待执行槽的任务数
+	wg       sync.WaitGroup
+	closed   bool          // 排空中：不再接收新任务
+	aborting bool          // 排空超时：进行中的任务已被中断
+	requeued []requeuedJob // 被中断、等待重启后重新执行的任务
+}
+
+// requeuedJob 关闭时未完成的任务及其原始请求
+type requeuedJob struct {
+	Job     *Job               `json:"job"`
+	Request *TranscribeRequest `json:"request"`
+}
+
+// NewJobManager 创建任务管理器，最多同时执行 workers 个任务；requeuePath 非空时关闭中断的任务写入该文件，下次启动由 Restore 重新提交
+func NewJobManager(svc *WhisperService, webhooks *WebhookSender, workers int, requeuePath string) *JobManager {
+	return &JobManager{
+		svc:         svc,
+		webhooks:    webhooks,
+		requeuePath: requeuePath,
+		slots:       make(chan struct{}, max(workers, 1)),
+		jobs:        map[string]*Job{},
+		cancels:     map[string]context.CancelFunc{},
+	}
+}
+
+// Submit 提交异步任务并立即返回任务快照；任务日志沿用 ctx 上的请求 ID。
+// 请求带 callback_url 时会先校验白名单，排队任务已达 MAX_QUEUED_JOBS 时返回 ErrJobQueueFull，关闭中返回 ErrShuttingDown
+func (m *JobManager) Submit(ctx context.Context, req *TranscribeRequest) (*Job, error) {
+	if req.CallbackURL != "" {
+		if err := m.webhooks.CheckURL(req.CallbackURL); err != nil {
+			return nil, err
+		}
+	}
+
+	job := &Job{
+		ID:        newJobID(),
+		Status:    JobQueued,
+		Inputs:    req.InPaths,
+		CreatedAt: time.Now(),
+	}
+	return m.start(logging.RequestID(ctx), job, req, configs.GetMaxQueuedJobs())
+}
+
+// start 登记任务并在后台等待执行槽；requestID 为空时以任务 ID 作为请求 ID，maxQueued <= 0 时不限制排队数
+func (m *JobManager) start(requestID string, job *Job, req *TranscribeRequest, maxQueued int) (*Job, error) {
+	if req.CallbackURL != "" {
+		job.Webhook = &WebhookDelivery{URL: req.CallbackURL}
+	}
+	if requestID == "" {
+		requestID = job.ID
+	}
+
+	ctx, cancel := context.WithCancel(logging.WithRequestID(context.Background(), requestID))
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		cancel()
+		return nil, ErrShuttingDown
+	}
+	if maxQueued > 0 && m.waiting >= maxQueued {
+		m.mu.Unlock()
+		cancel()
+		return nil, fmt.Errorf("%w: %d jobs waiting", ErrJobQueueFull, maxQueued)
+	}
+	m.pruneLocked()
+	m.jobs[job.ID] = job
+	m.cancels[job.ID] = cancel
+	m.waiting++
+	snapshot := job.snapshot()
+	m.wg.Add(1)
+	m.mu.Unlock()
+
+	go func() {
+		defer m.wg.Done()
+		m.run(ctx, job, req)
+	}()
+	return snapshot, nil
+}
+
+// Get 查询任务快照
+func (m *JobManager) Get(id string) (*Job, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	job, ok := m.jobs[id]
+	if !ok {
+		return nil, false
+	}
+	return job.snapshot(), true
+}
+
+// Cancel 取消未结束的任务（排队中或执行中）；任务随后以 cancelled 状态结束并照常投递回调。
+// 关闭时被中断、等待重启后重新执行的任务直接标记为 cancelled，不再恢复
+func (m *JobManager) Cancel(id string) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[id]
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+	if cancel, ok := m.cancels[id]; ok {
+		cancel()
+		return job.snapshot(), nil
+	}
+	if job.Status != JobQueued {
+		return job.snapshot(), ErrJobFinished
+	}
+	now := time.Now()
+	job.Status, job.FinishedAt = JobCancelled, &now
+	job.Error, job.ErrorCode = ErrCancelled.Error(), transcribeErrorCode(ErrCancelled)
+	m.requeued = slices.DeleteFunc(m.requeued, func(r requeuedJob) bool { return r.Job.ID == id })
+	return job.snapshot(), nil
+}
+
+// Drain 停止接收新任务并等待进行中的任务（含回调投递）结束；ctx 到期时中断剩余任务，
+// 被中断的任务不投递回调，写入 requeuePath 等待下次启动重新执行
+func (m *JobManager) Drain(ctx context.Context) {
+	m.mu.Lock()
+	m.closed = true
+	m.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		m.mu.Lock()
+		m.aborting = true
+		logrus.Warnf("排空超时，中断 %d 个未完成的任务", len(m.cancels))
+		for _, cancel := range m.cancels {
+			cancel()
+		}
+		m.mu.Unlock()
+		select {
+		case <-done:
+		case <-time.After(jobAbortGrace):
+			logrus.Warnf("等待任务收尾超时，放弃等待")
+		}
+	}
+
+	m.mu.Lock()
+	requeued := m.requeued
+	m.mu.Unlock()
+	if len(requeued) == 0 || m.requeuePath == "" {
+		return
+	}
+	if err := m.saveRequeue(requeued); err != nil {
+		logrus.Errorf("保存未完成任务失败: %v", err)
+		return
+	}
+	logrus.Infof("%d 个未完成的任务已保存到 %s，重启后重新执行", len(requeued), m.requeuePath)
+}
+
+// saveRequeue 原子写入未完成任务；文件含回调签名密钥，仅属主可读
+func (m *JobManager) saveRequeue(items []requeuedJob) error {
+	data, err := json.MarshalIndent(items, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := m.requeuePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, m.requeuePath)
+}
+
+// Restore 重新提交上次关闭时未完成的任务，沿用原任务 ID；已转录完成的文件从缓存复用
+func (m *JobManager) Restore() error {
+	if m.requeuePath == "" {
+		return nil
+	}
+	data, err := os.ReadFile(m.requeuePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var items []requeuedJob
+	if err := json.Unmarshal(data, &items); err != nil {
+		return fmt.Errorf("parse %s: %w", m.requeuePath, err)
+	}
+
+	restored := 0
+	for _, it := range items {
+		if it.Job == nil || it.Request == nil {
+			continue
+		}
+		// 白名单可能已收紧：不再允许的回调地址不重新执行
+		if it.Request.CallbackURL != "" {
+			if err := m.webhooks.CheckURL(it.Request.CallbackURL); err != nil {
+				logrus.Warnf("job %s: 放弃恢复: %v", it.Job.ID, err)
+				continue
+			}
+		}
+		job := &Job{
+			ID:        it.Job.ID,
+			Status:    JobQueued,
+			Inputs:    it.Request.InPaths,
+			CreatedAt: it.Job.CreatedAt,
+		}
+		// 恢复的任务不受排队上限约束
+		if _, err := m.start("", job, it.Request, 0); err != nil {
+			return err
+		}
+		restored++
+	}
+	if err := os.Remove(m.requeuePath); err != nil {
+		return err
+	}
+	logrus.Infof("已恢复 %d 个上次关闭时未完成的任务", restored)
+	return nil
+}
+
+func (m *JobManager) run(ctx context.Context, job *Job, req *TranscribeRequest) {
+	log := logging.FromContext(ctx).WithField("job_id", job.ID)
+	out, err := m.execute(ctx, job, req)
+
+	if m.interrupted(err) {
+		// 关闭时被中断：回到排队状态、不投递回调，重启后重新执行
+		m.update(job, func(j *Job) {
+			j.Status, j.StartedAt = JobQueued, nil
+			m.requeued = append(m.requeued, requeuedJob{Job: j.snapshot(), Request: req})
+			m.cancels[j.ID]()
+			delete(m.cancels, j.ID)
+		})
+		log.Info("job interrupted by shutdown, requeued")
+		return
+	}
+
+	payload := WebhookPayload{JobID: job.ID}
+	m.update(job, func(j *Job) {
+		now := time.Now()
+		j.FinishedAt = &now
+		if err != nil {
+			j.Status = JobFailed
+			if errors.Is(err, ErrCancelled) {
+				j.Status = JobCancelled
+			}
+			j.Error, j.ErrorCode = err.Error(), transcribeErrorCode(err)
+		} else {
+			j.Status = JobSucceeded
+			j.Result = out
+		}
+		payload.Status, payload.Result, payload.Error, payload.Code = j.Status, j.Result, j.Error, j.ErrorCode
+
+		// 与状态在同一把锁内移除，结束后的任务不可再取消
+		m.cancels[j.ID]()
+		delete(m.cancels, j.ID)
+	})
+	log.Infof("job %s", payload.Status)
+
+	if req.CallbackURL == "" {
+		return
+	}
+	switch payload.Status {
+	case JobFailed:
+		payload.Event = EventTranscriptionFailed
+	case JobCancelled:
+		payload.Event = EventTranscriptionCancelled
+	default:
+		payload.Event = EventTranscriptionCompleted
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.Errorf("marshal webhook payload: %v", err)
+		return
+	}
+	// 任务 ctx 可能已取消，回调投递使用独立的 ctx
+	err = m.webhooks.Deliver(context.Background(), req.CallbackURL, req.CallbackSecret, payload.Event, job.ID, body, func(a WebhookAttempt) {
+		m.update(job, func(j *Job) {
+			j.Webhook.Attempts = append(j.Webhook.Attempts, a)
+			j.Webhook.Delivered = a.Error == ""
+		})
+	})
+	if err != nil {
+		log.Warn(err)
+	}
+}
+
+// execute 等待空闲的执行槽后转录；排队期间被取消时不占用执行槽，直接返回 ErrCancelled
+func (m *JobManager) execute(ctx context.Context, job *Job, req *TranscribeRequest) (*TranscribeBatchResponse, error) {
+	select {
+	case m.slots <- struct{}{}:
+	case <-ctx.Done():
+		m.update(job, func(*Job) { m.waiting-- })
+		return nil, ErrCancelled
+	}
+	defer func() { <-m.slots }()
+
+	m.update(job, func(j *Job) {
+		m.waiting--
+		now := time.Now()
+		j.Status = JobRunning
+		j.StartedAt = &now
+	})
+	return m.svc.Transcribe(ctx, req)
+}
+
+// interrupted 任务是否因服务关闭而中断
+func (m *JobManager) interrupted(err error) bool {
+	if errors.Is(err, ErrShuttingDown) {
+		return true
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.aborting && errors.Is(err, ErrCancelled)
+}
+
+func (m *JobManager) update(job *Job, fn func(*Job)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	fn(job)
+}
+
+// pruneLocked 清理超过保留时长的已结束任务（调用方持有锁）
+func (m *JobManager) pruneLocked() {
+	for id, j := range m.jobs {
+		if j.FinishedAt != nil && time.Since(*j.FinishedAt) > jobRetention {
+			delete(m.jobs, id)
+		}
+	}
+}
+
+// snapshot 拷贝可变字段，避免调用方读到并发修改（调用方持有锁）
+func (j *Job) snapshot() *Job {
+	cp := *j
+	if j.Webhook != nil {
+		wh := *j.Webhook
+		wh.Attempts = append([]WebhookAttempt(nil), j.Webhook.Attempts...)
+		cp.Webhook = &wh
+	}
+	return &cp
+}
+
+func newJobID() string {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return "job_" + hex.EncodeToString(b)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go-whisper-mcp/configs"
+)
+
+func newTestJobManager(t *testing.T, workers, maxQueued int) *JobManager {
+	t.Helper()
+	cfg := configs.Default()
+	cfg.Media.Dir = t.TempDir()
+	cfg.Cache.TranscriptsDir = filepath.Join(cfg.Media.Dir, "transcripts")
+	cfg.Limits.MaxQueuedJobs = maxQueued
+	configs.Set(cfg)
+	t.Cleanup(func() { configs.Set(configs.Default()) })
+	return NewJobManager(NewWhisperService(), NewWebhookSender(WebhookOptions{}), workers, "")
+}
+
+func waitJob(t *testing.T, m *JobManager, id string, want JobStatus) *Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		job, ok := m.Get(id)
+		if !ok {
+			t.Fatalf("job %s not found", id)
+		}
+		if job.Status == want {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s status = %s, want %s", id, job.Status, want)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestJobManagerQueuesBeyondWorkers(t *testing.T) {
+	m := newTestJobManager(t, 1, 2)
+	// 占住唯一的执行槽：之后提交的任务都只能排队
+	m.slots <- struct{}{}
+
+	// 输入不存在：解析失败，无需加载模型
+	req := func() *TranscribeRequest {
+		return &TranscribeRequest{InPaths: []string{filepath.Join(t.TempDir(), "missing.wav")}}
+	}
+	ctx := context.Background()
+	j1, err := m.Submit(ctx, req())
+	if err != nil {
+		t.Fatal(err)
+	}
+	j2, err := m.Submit(ctx, req())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Submit(ctx, req()); !errors.Is(err, ErrJobQueueFull) {
+		t.Fatalf("third submit err = %v, want ErrJobQueueFull", err)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	for _, id := range []string{j1.ID, j2.ID} {
+		if job, _ := m.Get(id); job.Status != JobQueued || job.StartedAt != nil {
+			t.Errorf("job %s = %s, want queued without a worker", id, job.Status)
+		}
+	}
+
+	// 排队中的任务可以取消，并腾出排队名额
+	if _, err := m.Cancel(j1.ID); err != nil {
+		t.Fatal(err)
+	}
+	if job := waitJob(t, m, j1.ID, JobCancelled); job.StartedAt != nil {
+		t.Error("cancelled job should never have started")
+	}
+	j3, err := m.Submit(ctx, req())
+	if err != nil {
+		t.Fatalf("submit after cancel: %v", err)
+	}
+
+	<-m.slots
+	waitJob(t, m, j2.ID, JobSucceeded)
+	waitJob(t, m, j3.ID, JobSucceeded)
+	m.wg.Wait()
+}
+
+func TestJobManagerCancelRequeued(t *testing.T) {
+	m := newTestJobManager(t, 1, 10)
+	job := &Job{ID: "job_requeued", Status: JobQueued, CreatedAt: time.Now()}
+	m.jobs[job.ID] = job
+	m.requeued = []requeuedJob{{Job: job.snapshot(), Request: &TranscribeRequest{InPaths: []string{"a.wav"}}}}
+
+	got, err := m.Cancel(job.ID)
+	if err != nil {
+		t.Fatalf("cancel requeued job: %v", err)
+	}
+	if got.Status != JobCancelled || got.FinishedAt == nil {
+		t.Errorf("job = %+v, want cancelled", got)
+	}
+	if len(m.requeued) != 0 {
+		t.Error("cancelled job is still scheduled for restore")
+	}
+	if _, err := m.Cancel(job.ID); !errors.Is(err, ErrJobFinished) {
+		t.Errorf("second cancel err = %v, want ErrJobFinished", err)
+	}
+}
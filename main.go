@@ -1,36 +1,227 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/sirupsen/logrus"
+	"go-whisper-mcp/configs"
+	"go-whisper-mcp/pkg"
+	"go-whisper-mcp/pkg/logging"
+	"go-whisper-mcp/pkg/metrics"
+	"go-whisper-mcp/pkg/tracing"
 )
 
+// version 构建版本，发布时通过 -ldflags "-X main.version=v1.2.3" 注入
+var version = "dev"
+
+const usage = `用法:
+  go-whisper-mcp [serve] [flags]              启动 HTTP / MCP 服务（缺省子命令）
+  go-whisper-mcp transcribe [flags] <输入>...  直接转录本地文件 / 目录 / glob / URL
+  go-whisper-mcp models list|pull|rm|verify|import ...
+  go-whisper-mcp config [flags]               校验并打印生效配置（密钥已隐去）
+
+各子命令使用 -h 查看参数。
+`
+
 func main() {
-	if os.Getenv("NATIVE_LOG_SILENT") != "0" { // 缺省静音；设置 0 则打开
-		DisableNativeLogs()
+	os.Exit(run(os.Args[1:]))
+}
+
+// run 分发子命令，返回进程退出码
+func run(args []string) int {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") && args[0] != "-h" && args[0] != "--help" {
+		// 兼容旧用法：不带子命令直接启动服务
+		return runServe(args)
+	}
+	switch args[0] {
+	case "serve":
+		return runServe(args[1:])
+	case "transcribe":
+		return runTranscribe(args[1:])
+	case "models":
+		return runModels(args[1:])
+	case "config":
+		return runConfig(args[1:])
+	case "help", "-h", "--help":
+		fmt.Fprint(os.Stdout, usage)
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "未知子命令 %q\n\n%s", args[0], usage)
+		return 2
+	}
+}
+
+// setupConfig 加载配置（默认值 < 配置文件 < 环境变量），override 应用命令行参数后校验并设为当前配置
+func setupConfig(path string, override func(*configs.Config)) (*configs.Config, error) {
+	cfg, err := configs.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	if override != nil {
+		override(cfg)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config:\n%w", err)
+	}
+	configs.Set(cfg)
+	metrics.ConfigLastReloadSuccess.SetToCurrentTime()
+	if err := logging.Setup(cfg.Log.Format, cfg.Log.Level); err != nil {
+		return nil, err
+	}
+	RouteNativeLogs()
+	pkg.SetDenoiseModel(cfg.Decode.DenoiseModel)
+	return cfg, nil
+}
+
+// setupModels 加载本地模型注册表并设置离线模式
+func setupModels(models configs.Models) error {
+	registry, err := loadModelRegistry(models)
+	if err != nil {
+		return err
+	}
+	pkg.SetModelRegistry(registry)
+	pkg.SetOffline(models.Offline)
+	return nil
+}
+
+// loadModelRegistry 读取模型注册表（models.registry，缺省 <models.dir>/registry.json）
+func loadModelRegistry(models configs.Models) (*pkg.ModelRegistry, error) {
+	registryPath := models.Registry
+	if registryPath == "" {
+		registryPath = filepath.Join(models.Dir, "registry.json")
 	}
+	registry, err := pkg.LoadModelRegistry(registryPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load model registry: %w", err)
+	}
+	return registry, nil
+}
 
+// runServe 启动 HTTP / MCP 服务（可同时运行监听目录模式）
+func runServe(args []string) int {
 	var (
-		flagDefaultM string // 浏览器二进制文件路径
+		common       cliCommon
+		flagDefaultM string
 		port         string
+		transport    string
+		watch        WatchOptions
+		watchDirs    string
+		watchFormats string
 	)
-	flag.StringVar(&flagDefaultM, "default-model", "medium", "default model spec")
-	flag.StringVar(&port, "port", ":28796", "端口")
-	flag.Parse()
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	common.register(fs)
+	fs.StringVar(&flagDefaultM, "default-model", "", "默认模型（缺省读取配置 models.default，medium）")
+	fs.StringVar(&port, "port", "", "监听地址（缺省读取配置 server.port，:28796）")
+	fs.StringVar(&transport, "transport", "", "MCP 传输方式：http | stdio | both（缺省读取配置 server.transport）")
+	fs.StringVar(&watchDirs, "watch", os.Getenv("WATCH_DIRS"), "监听目录（逗号分隔），放入的媒体文件自动转录")
+	fs.StringVar(&watch.OutDir, "watch-out", os.Getenv("WATCH_OUT_DIR"), "监听模式的输出目录")
+	fs.StringVar(&watchFormats, "watch-formats", "json,srt", "监听模式的输出格式（json,srt,vtt,txt）")
+	fs.StringVar(&watch.DoneDir, "watch-done", os.Getenv("WATCH_DONE_DIR"), "处理成功的文件移入该目录")
+	fs.StringVar(&watch.ErrorDir, "watch-error", os.Getenv("WATCH_ERROR_DIR"), "处理失败的文件移入该目录")
+	fs.StringVar(&watch.Lang, "watch-lang", "", "监听模式的语言（缺省自动）")
+	if err := fs.Parse(args); err != nil {
+		return exitCodeForParse(err)
+	}
+	override := common.overrides(fs, func(cfg *configs.Config) {
+		fs.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "default-model":
+				cfg.Models.Default = flagDefaultM
+			case "port":
+				cfg.Server.Port = port
+			case "transport":
+				cfg.Server.Transport = transport
+			}
+		})
+	})
+	cfg, err := common.setup(override)
+	if err != nil {
+		logrus.Error(err)
+		return 1
+	}
+	transport = cfg.Server.Transport
+
+	// 链路追踪（OTEL_TRACES_EXPORTER=otlp 时导出到 OTLP collector）
+	shutdownTracing, err := setupTracing()
+	if err != nil {
+		logrus.Error(err)
+		return 1
+	}
+	defer shutdownTracing()
 
 	// 初始化服务
 	whisperService := NewWhisperService()
 
-	modelsDir := "./models"
-	if s := os.Getenv("MODELS_DIR"); len(s) > 0 {
-		modelsDir = s
+	if cfg.Models.Offline {
+		logrus.Infof("离线模式已开启，模型目录: %s", cfg.Models.Dir)
+	}
+
+	// 创建应用服务器
+	appServer := NewAppServer(whisperService, &configReloader{path: common.configPath, override: override})
+
+	// 监听目录模式：与 HTTP 服务一起运行，关闭时与其他转录一起排空
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if watchDirs != "" {
+		watch.Dirs = splitList(watchDirs)
+		watch.Formats = []string{watchFormats}
+		watcher, err := NewWatcher(whisperService, watch)
+		if err != nil {
+			logrus.Errorf("failed to start watcher: %v", err)
+			return 1
+		}
+		go func() {
+			if err := watcher.Run(ctx); err != nil {
+				logrus.Errorf("watcher stopped: %v", err)
+			}
+		}()
+		appServer.AddDrainer(watcher)
 	}
 
-	// 创建并启动应用服务器
-	appServer := NewAppServer(modelsDir, flagDefaultM, whisperService)
-	if err := appServer.Start(port); err != nil {
-		logrus.Fatalf("failed to run server: %v", err)
+	// 启动应用服务器
+	if err := appServer.Run(cfg.Server.Port, transport); err != nil {
+		logrus.Errorf("failed to run server: %v", err)
+		return 1
+	}
+	return 0
+}
+
+// setupTracing 按 OTEL_TRACES_EXPORTER 初始化链路追踪，返回的函数在退出前刷出剩余 span
+func setupTracing() (func(), error) {
+	shutdown, err := tracing.Setup(context.Background(), configs.GetTracesExporter())
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up tracing: %w", err)
+	}
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdown(ctx); err != nil {
+			logrus.Warnf("failed to flush traces: %v", err)
+		}
+	}, nil
+}
+
+// exitCodeForParse -h 返回 0，其余参数错误返回 2
+func exitCodeForParse(err error) int {
+	if err == flag.ErrHelp {
+		return 0
+	}
+	return 2
+}
+
+// splitList 拆分逗号分隔的列表，忽略空项
+func splitList(s string) []string {
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
 	}
+	return out
 }
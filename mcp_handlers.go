@@ -1,73 +1,230 @@
 package main
 
 import (
-	"bytes"
+	"cmp"
 	"context"
-	"encoding/json"
 	"fmt"
-	"github.com/sirupsen/logrus"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"go-whisper-mcp/pkg"
+	"go-whisper-mcp/pkg/logging"
 )
 
 // MCP 工具处理函数
 
-// handleTranscribe 转换
-func (a *AppServer) handleTranscribe(ctx context.Context, args map[string]interface{}) *MCPToolResult {
-	logrus.Info("MCP: 转换", args)
+// transcribe 工具输出的详细程度
+const (
+	VerbositySummary  = "summary"  // 仅状态、时长与 transcript_id
+	VerbosityText     = "text"     // 附带全文（默认）
+	VerbositySegments = "segments" // 附带逐段时间戳
+)
 
-	inPaths, _ := args["in_paths"].([]interface{})
-	model, _ := args["model"].(string)
-	lang, _ := args["lang"].(string)
-	t, _ := args["t"].(int)
+// TranscribeToolOutput transcribe 工具的结构化输出
+type TranscribeToolOutput struct {
+	Model     string               `json:"model" jsonschema:"实际使用的模型文件"`
+	Language  string               `json:"language" jsonschema:"请求的语言（空或 auto 表示自动识别）"`
+	DurationS string               `json:"duration_s" jsonschema:"整批耗时"`
+	Succeeded int                  `json:"succeeded"`
+	Failed    int                  `json:"failed"`
+	Files     []TranscribeToolFile `json:"files" jsonschema:"与输入（目录/glob 展开后）一一对应的结果"`
+}
 
-	var mediaPaths []string
-	for _, path := range inPaths {
-		if pathStr, ok := path.(string); ok {
-			mediaPaths = append(mediaPaths, pathStr)
-		}
+// TranscribeToolFile 单个文件的结果
+type TranscribeToolFile struct {
+	Path         string                  `json:"path"`
+	Success      bool                    `json:"success"`
+	Error        string                  `json:"error,omitempty"`
+	TranscriptID string                  `json:"transcript_id,omitempty" jsonschema:"可通过 transcript://{id} 资源读取完整结果或字幕"`
+	Source       string                  `json:"source,omitempty"`
+	Title        string                  `json:"title,omitempty"`
+	DurationS    string                  `json:"duration_s,omitempty"`
+	SegmentCount int                     `json:"segment_count"`
+	Text         string                  `json:"text,omitempty" jsonschema:"全文（verbosity 为 text 或 segments 时返回）"`
+	Segments     []TranscribeToolSegment `json:"segments,omitempty" jsonschema:"逐段时间戳（verbosity 为 segments 时返回）"`
+	Truncated    bool                    `json:"truncated,omitempty" jsonschema:"受 max_chars 限制被截断"`
+
+	Language       string  `json:"language,omitempty" jsonschema:"识别出的语言（auto 时为检测结果）"`
+	AudioDurationS float64 `json:"audio_duration_s,omitempty" jsonschema:"音频时长（秒）"`
+
+	AudioStream *pkg.AudioStream `json:"audio_stream,omitempty" jsonschema:"按 audio_stream 转录的音频流；all 时同一文件每条音频流各一项"`
+}
+
+// TranscribeToolSegment 带时间戳的分段
+type TranscribeToolSegment struct {
+	StartMs int64  `json:"start_ms"`
+	EndMs   int64  `json:"end_ms"`
+	Text    string `json:"text"`
+}
+
+// handleTranscribe 转换，返回文本摘要与结构化结果
+func (a *AppServer) handleTranscribe(ctx context.Context, args TranscribeArgs) (*mcp.CallToolResult, *TranscribeToolOutput, error) {
+	logging.FromContext(ctx).Info("MCP: 转换 ", args.InPaths)
+
+	verbosity := strings.ToLower(strings.TrimSpace(args.Verbosity))
+	switch verbosity {
+	case "":
+		verbosity = VerbosityText
+	case VerbositySummary, VerbosityText, VerbositySegments:
+	default:
+		return nil, nil, fmt.Errorf("invalid verbosity %q (summary, text, segments)", args.Verbosity)
 	}
 
+	model := args.Model
 	if len(model) == 0 {
-		model = a.defaultModel
+		model = a.defaultModel()
 	}
 
 	req := &TranscribeRequest{
-		InPaths:   mediaPaths,
+		InPaths:   args.InPaths,
 		Model:     model,
-		Lang:      lang,
-		Threads:   t,
-		ModelsDir: a.modelsDir,
+		Lang:      args.Lang,
+		Threads:   args.Threads,
+		ModelsDir: a.modelsDir(),
+		TimeoutS:  args.TimeoutS,
+
+		Preprocess:  args.Preprocess,
+		AudioStream: args.AudioStream,
 	}
 
-	transcribeBatchResponse, err := a.whisperService.Transcribe(ctx, req)
+	batch, err := a.whisperService.Transcribe(ctx, req)
+	if code := transcribeErrorCode(err); code != "" {
+		return nil, nil, fmt.Errorf("%s: %w", code, err)
+	}
 	if err != nil {
-		return &MCPToolResult{Content: []MCPContent{{Type: "text", Text: "转换失败: " + err.Error()}}, IsError: true}
+		return nil, nil, fmt.Errorf("转换失败: %w", err)
+	}
+
+	out := buildToolOutput(batch, verbosity, args.MaxChars)
+	res := &mcp.CallToolResult{
+		Content: []mcp.Content{&mcp.TextContent{Text: toolSummary(out)}},
+		IsError: len(out.Files) > 0 && out.Succeeded == 0,
+	}
+	return res, out, nil
+}
+
+// buildToolOutput 按详细程度裁剪批量结果；maxChars > 0 时限制每个文件的文本长度
+func buildToolOutput(batch *TranscribeBatchResponse, verbosity string, maxChars int) *TranscribeToolOutput {
+	out := &TranscribeToolOutput{
+		Language:  batch.Language,
+		DurationS: batch.DurationS,
+		Files:     make([]TranscribeToolFile, 0, len(batch.Results)),
+	}
+	if batch.ModelPath != "" {
+		out.Model = filepath.Base(batch.ModelPath)
 	}
 
-	var list []string
+	for _, r := range batch.Results {
+		f := TranscribeToolFile{
+			Path:         r.Path,
+			Success:      r.IsSuccess,
+			Error:        r.Error,
+			TranscriptID: r.TranscriptID,
+			Source:       r.Source,
+			DurationS:    r.DurationS,
+			SegmentCount: len(r.Segments),
+			AudioStream:  r.AudioStream,
 
-	for _, result := range transcribeBatchResponse.Results {
-		var buffer bytes.Buffer
-		for _, segment := range result.Segments {
-			buffer.WriteString(segment.Text)
+			Language:       r.Language,
+			AudioDurationS: r.AudioDuration,
 		}
-		list = append(list, buffer.String()+",")
+		if r.Meta != nil {
+			f.Title = r.Meta.Title
+		}
+		if r.IsSuccess {
+			out.Succeeded++
+		} else {
+			out.Failed++
+		}
+
+		switch verbosity {
+		case VerbosityText:
+			f.Text, f.Truncated = truncateRunes(TranscriptText(r), maxChars)
+		case VerbositySegments:
+			used := 0
+			for _, seg := range r.Segments {
+				text := strings.TrimSpace(seg.Text)
+				if maxChars > 0 && used+utf8.RuneCountInString(text) > maxChars {
+					f.Truncated = true
+					break
+				}
+				used += utf8.RuneCountInString(text)
+				start, end := segmentBounds(seg)
+				f.Segments = append(f.Segments, TranscribeToolSegment{
+					StartMs: start.Milliseconds(),
+					EndMs:   end.Milliseconds(),
+					Text:    text,
+				})
+			}
+		}
+		out.Files = append(out.Files, f)
 	}
+	return out
+}
 
-	jsonData, err := json.MarshalIndent(list, "", "  ")
+// handleInspect 列出音频流，返回文本摘要与结构化结果
+func (a *AppServer) handleInspect(ctx context.Context, args InspectArgs) (*mcp.CallToolResult, *pkg.MediaInfo, error) {
+	logging.FromContext(ctx).Info("MCP: 检查 ", args.InPath)
+
+	info, err := a.whisperService.Inspect(ctx, args.InPath)
 	if err != nil {
-		return &MCPToolResult{
-			Content: []MCPContent{{
-				Type: "text",
-				Text: fmt.Sprintf("转换成功，但序列化失败: %v", err),
-			}},
-			IsError: true,
+		return nil, nil, fmt.Errorf("检查失败: %w", err)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s：%d 条音频流\n", args.InPath, len(info.AudioStreams))
+	for _, st := range info.AudioStreams {
+		fmt.Fprintf(&b, "  #%d %s %s %dch", st.Index, cmp.Or(st.Language, "und"), st.Codec, st.Channels)
+		if st.Title != "" {
+			fmt.Fprintf(&b, " %q", st.Title)
+		}
+		if st.Default {
+			b.WriteString(" (default)")
 		}
+		b.WriteString("\n")
 	}
+	return &mcp.CallToolResult{Content: []mcp.Content{&mcp.TextContent{Text: b.String()}}}, info, nil
+}
+
+// toolSummary 给人看的摘要：每个文件一行，附文本开头
+func toolSummary(out *TranscribeToolOutput) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "转录完成：%d/%d 成功", out.Succeeded, len(out.Files))
+	if out.Model != "" {
+		fmt.Fprintf(&b, "，模型 %s", out.Model)
+	}
+	fmt.Fprintf(&b, "，耗时 %s\n", out.DurationS)
+	for _, f := range out.Files {
+		if !f.Success {
+			fmt.Fprintf(&b, "✘ %s: %s\n", f.Path, f.Error)
+			continue
+		}
+		fmt.Fprintf(&b, "✔ %s（", f.Path)
+		if st := f.AudioStream; st != nil {
+			fmt.Fprintf(&b, "音轨 %d %s，", st.Index, cmp.Or(st.Language, "und"))
+		}
+		fmt.Fprintf(&b, "%d 段", f.SegmentCount)
+		if f.TranscriptID != "" {
+			fmt.Fprintf(&b, "，transcript://%s", f.TranscriptID)
+		}
+		b.WriteString("）\n")
+		if f.Text != "" {
+			preview, cut := truncateRunes(strings.ReplaceAll(f.Text, "\n", " "), 200)
+			if cut {
+				preview += "…"
+			}
+			fmt.Fprintf(&b, "  %s\n", preview)
+		}
+	}
+	return b.String()
+}
 
-	return &MCPToolResult{
-		Content: []MCPContent{{
-			Type: "text", Text: string(jsonData),
-		}},
-		IsError: false,
+// truncateRunes 按字符数截断，n <= 0 表示不限制
+func truncateRunes(s string, n int) (string, bool) {
+	if n <= 0 || utf8.RuneCountInString(s) <= n {
+		return s, false
 	}
+	return string([]rune(s)[:n]), true
 }
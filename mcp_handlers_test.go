@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+
+	"go-whisper-mcp/whisper"
+)
+
+func TestBuildToolOutputPerFileLanguage(t *testing.T) {
+	batch := &TranscribeBatchResponse{
+		ModelPath: "/models/ggml-small.bin",
+		Language:  "auto",
+		Results: []*TranscribeResponse{
+			{Path: "a.mp3", IsSuccess: true, Language: "zh", AudioDuration: 12.5, Segments: []whisper.TranscribeAudioResult{{Text: "你好"}}},
+			{Path: "b.mp3", IsSuccess: true, Language: "en", AudioDuration: 3},
+			{Path: "c.mp3", Error: "decode failed"},
+		},
+	}
+	out := buildToolOutput(batch, VerbositySummary, 0)
+	if out.Model != "ggml-small.bin" || out.Succeeded != 2 || out.Failed != 1 {
+		t.Fatalf("out = %+v", out)
+	}
+	want := []struct {
+		lang string
+		dur  float64
+	}{{"zh", 12.5}, {"en", 3}, {"", 0}}
+	for i, w := range want {
+		f := out.Files[i]
+		if f.Language != w.lang || f.AudioDurationS != w.dur {
+			t.Errorf("file %d: language %q duration %v, want %q %v", i, f.Language, f.AudioDurationS, w.lang, w.dur)
+		}
+	}
+}
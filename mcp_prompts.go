@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// transcriptPrompt 基于转录文本的提示词模板
+type transcriptPrompt struct {
+	name        string
+	title       string
+	description string
+	instruction string
+}
+
+// transcriptPrompts 常用的转录处理流程
+var transcriptPrompts = []transcriptPrompt{
+	{
+		name:        "summarize_transcript",
+		title:       "总结转录内容",
+		description: "按主题总结转录内容，保留关键观点与结论",
+		instruction: "请阅读下面带时间戳的转录文本，写一份结构化摘要：先用 2-3 句话概括主旨，再按主题列出要点，每个要点标注对应的时间戳。不要编造转录中没有的信息。",
+	},
+	{
+		name:        "meeting_minutes",
+		title:       "生成会议纪要",
+		description: "把会议录音的转录整理为会议纪要",
+		instruction: "请把下面带时间戳的会议转录整理成会议纪要，包含：会议主题、参与者（若能从内容判断）、讨论议题、已达成的决定、待办事项（负责人与截止时间，若有提及）、未决问题。引用原话时标注时间戳。",
+	},
+	{
+		name:        "extract_action_items",
+		title:       "提取待办事项",
+		description: "从转录中提取待办事项、负责人与截止时间",
+		instruction: "请从下面带时间戳的转录文本中提取所有待办事项。每一项给出：任务描述、负责人（未提及写“未指定”）、截止时间（未提及写“未指定”）、出处时间戳。只列出转录中明确提到或约定的事项，以 Markdown 表格输出。",
+	},
+	{
+		name:        "generate_chapters",
+		title:       "生成章节",
+		description: "按内容切分章节并生成带时间戳的目录",
+		instruction: "请根据下面带时间戳的转录文本把内容划分为若干章节，每个章节给出开始时间（HH:MM:SS）、简短标题和一句话说明，按时间顺序输出，格式如 `00:00:00 标题 - 说明`。第一个章节从 00:00:00 开始。",
+	},
+}
+
+// promptArguments 所有转录提示词共用的参数
+var promptArguments = []*mcp.PromptArgument{
+	{Name: "transcript_id", Description: "已有转录的 ID（transcribe 结果中的 transcript_id），与 media_path 二选一"},
+	{Name: "media_path", Description: "媒体文件路径或 URL，未提供 transcript_id 时先转录（已转录过的文件会直接复用结果）"},
+	{Name: "lang", Description: "转录语言（仅 media_path 时使用），缺省自动识别"},
+	{Name: "output_language", Description: "输出使用的语言，缺省与转录内容一致"},
+}
+
+// registerPrompts 注册常用转录流程的提示词
+func registerPrompts(server *mcp.Server, appServer *AppServer) {
+	for _, p := range transcriptPrompts {
+		server.AddPrompt(&mcp.Prompt{
+			Name:        p.name,
+			Title:       p.title,
+			Description: p.description,
+			Arguments:   promptArguments,
+		}, appServer.transcriptPromptHandler(p))
+	}
+}
+
+func (a *AppServer) transcriptPromptHandler(p transcriptPrompt) mcp.PromptHandler {
+	return func(ctx context.Context, req *mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+		args := req.Params.Arguments
+		r, err := a.promptTranscript(ctx, args)
+		if err != nil {
+			return nil, err
+		}
+
+		var b strings.Builder
+		b.WriteString(p.instruction)
+		if lang := strings.TrimSpace(args["output_language"]); lang != "" {
+			fmt.Fprintf(&b, "\n请使用 %s 输出。", lang)
+		}
+		b.WriteString("\n\n")
+		source := r.Path
+		if r.Meta != nil && r.Meta.Title != "" {
+			source = fmt.Sprintf("%s（%s）", r.Meta.Title, r.Path)
+		}
+		fmt.Fprintf(&b, "来源：%s\n转录 ID：%s\n\n<transcript>\n%s</transcript>\n",
+			source, r.TranscriptID, timestampedTranscript(r))
+
+		return &mcp.GetPromptResult{
+			Description: p.description,
+			Messages: []*mcp.PromptMessage{{
+				Role:    "user",
+				Content: &mcp.TextContent{Text: b.String()},
+			}},
+		}, nil
+	}
+}
+
+// promptTranscript 按 transcript_id 读取已有结果，或转录 media_path
+func (a *AppServer) promptTranscript(ctx context.Context, args map[string]string) (*TranscribeResponse, error) {
+	if id := strings.TrimSpace(args["transcript_id"]); id != "" {
+		return a.whisperService.Transcripts().Get(id)
+	}
+	mediaPath := strings.TrimSpace(args["media_path"])
+	if mediaPath == "" {
+		return nil, errors.New("transcript_id or media_path is required")
+	}
+	out, err := a.whisperService.Transcribe(ctx, &TranscribeRequest{
+		InPaths:   []string{mediaPath},
+		Model:     a.defaultModel(),
+		Lang:      args["lang"],
+		ModelsDir: a.modelsDir(),
+		MaxFiles:  1,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(out.Results) == 0 {
+		return nil, fmt.Errorf("no result for %s", mediaPath)
+	}
+	if r := out.Results[0]; !r.IsSuccess {
+		return nil, fmt.Errorf("transcribe %s: %s", mediaPath, r.Error)
+	}
+	return out.Results[0], nil
+}
+
+// timestampedTranscript 每段一行：[HH:MM:SS.mmm - HH:MM:SS.mmm] 文本
+func timestampedTranscript(r *TranscribeResponse) string {
+	var b strings.Builder
+	for _, seg := range r.Segments {
+		text := strings.TrimSpace(seg.Text)
+		if text == "" {
+			continue
+		}
+		start, end := segmentBounds(seg)
+		fmt.Fprintf(&b, "[%s - %s] %s\n", subtitleTime(start, "."), subtitleTime(end, "."), text)
+	}
+	return b.String()
+}
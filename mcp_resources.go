@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/sirupsen/logrus"
+	"go-whisper-mcp/pkg"
+)
+
+// 资源 URI 前缀
+const (
+	transcriptScheme = "transcript://"
+	modelScheme      = "model://"
+	jobScheme        = "job://"
+)
+
+// ModelResource model://{name} 返回的模型元数据
+type ModelResource struct {
+	Name       string          `json:"name"`
+	Path       string          `json:"path"`
+	Installed  bool            `json:"installed"`
+	Size       int64           `json:"size,omitempty"`
+	Registered bool            `json:"registered"`
+	Entry      *pkg.ModelEntry `json:"entry,omitempty"` // 注册表条目（语言、校验和、默认参数）
+}
+
+// registerResources 注册转录、模型与任务资源；新转录写入时追加具体资源并触发 list_changed 通知
+func registerResources(server *mcp.Server, appServer *AppServer) {
+	store := appServer.whisperService.Transcripts()
+
+	server.AddResourceTemplate(&mcp.ResourceTemplate{
+		Name:        "transcript",
+		URITemplate: transcriptScheme + "{id}",
+		Description: "已完成的转录结果（JSON，含分段与时间戳）",
+		MIMEType:    "application/json",
+	}, appServer.readTranscriptResource)
+	server.AddResourceTemplate(&mcp.ResourceTemplate{
+		Name:        "transcript-format",
+		URITemplate: transcriptScheme + "{id}/{format}",
+		Description: "转录结果的指定格式：json / srt / vtt / txt",
+	}, appServer.readTranscriptResource)
+	server.AddResourceTemplate(&mcp.ResourceTemplate{
+		Name:        "model",
+		URITemplate: modelScheme + "{name}",
+		Description: "模型元数据：本地路径、是否已安装、注册表中的语言与默认参数",
+		MIMEType:    "application/json",
+	}, appServer.readModelResource)
+	server.AddResourceTemplate(&mcp.ResourceTemplate{
+		Name:        "job",
+		URITemplate: jobScheme + "{id}",
+		Description: "异步转录任务状态",
+		MIMEType:    "application/json",
+	}, appServer.readJobResource)
+
+	// 已有的转录与模型
+	if list, err := store.List(); err != nil {
+		logrus.Warnf("list transcripts: %v", err)
+	} else {
+		for _, info := range list {
+			server.AddResource(transcriptResource(info), appServer.readTranscriptResource)
+		}
+	}
+	if models, err := pkg.ListInstalledModels(appServer.modelsDir()); err == nil {
+		for _, m := range models {
+			server.AddResource(&mcp.Resource{
+				URI:         modelScheme + url.PathEscape(m.Name),
+				Name:        m.Name,
+				Description: m.Path,
+				MIMEType:    "application/json",
+			}, appServer.readModelResource)
+		}
+	}
+
+	store.OnPut(func(info *TranscriptInfo) {
+		server.AddResource(transcriptResource(info), appServer.readTranscriptResource)
+	})
+}
+
+func transcriptResource(info *TranscriptInfo) *mcp.Resource {
+	title := info.Title
+	if title == "" {
+		title = info.Path
+	}
+	return &mcp.Resource{
+		URI:         transcriptScheme + info.ID,
+		Name:        info.ID,
+		Title:       title,
+		Description: fmt.Sprintf("%s（%d 段）", info.Path, info.Segments),
+		MIMEType:    "application/json",
+	}
+}
+
+// readTranscriptResource transcript://{id} 与 transcript://{id}/{format}
+func (a *AppServer) readTranscriptResource(_ context.Context, req *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+	uri := req.Params.URI
+	id, format, _ := strings.Cut(strings.TrimPrefix(uri, transcriptScheme), "/")
+	if format == "" {
+		format = FormatJSON
+	}
+	r, err := a.whisperService.Transcripts().Get(id)
+	if errors.Is(err, ErrTranscriptNotFound) {
+		return nil, mcp.ResourceNotFoundError(uri)
+	}
+	if err != nil {
+		return nil, err
+	}
+	data, ct, err := FormatTranscript(r, format)
+	if err != nil {
+		return nil, err
+	}
+	return textResource(uri, ct, data), nil
+}
+
+// readModelResource model://{name}
+func (a *AppServer) readModelResource(_ context.Context, req *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+	uri := req.Params.URI
+	name, err := url.PathUnescape(strings.TrimPrefix(uri, modelScheme))
+	if err != nil || name == "" {
+		return nil, mcp.ResourceNotFoundError(uri)
+	}
+	m := ModelResource{Name: name, Path: pkg.ModelLocalPath(a.modelsDir(), name)}
+	if entry, ok := pkg.LookupModel(name); ok {
+		m.Registered, m.Entry = true, entry
+	}
+	if fi, err := os.Stat(m.Path); err == nil {
+		m.Installed, m.Size = true, fi.Size()
+	}
+	if !m.Installed && !m.Registered {
+		return nil, mcp.ResourceNotFoundError(uri)
+	}
+	return jsonResource(uri, m)
+}
+
+// readJobResource job://{id}
+func (a *AppServer) readJobResource(_ context.Context, req *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+	uri := req.Params.URI
+	job, ok := a.jobs.Get(strings.TrimPrefix(uri, jobScheme))
+	if !ok {
+		return nil, mcp.ResourceNotFoundError(uri)
+	}
+	return jsonResource(uri, job)
+}
+
+func jsonResource(uri string, v any) (*mcp.ReadResourceResult, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return textResource(uri, "application/json", data), nil
+}
+
+func textResource(uri, contentType string, data []byte) *mcp.ReadResourceResult {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mt = contentType
+	}
+	return &mcp.ReadResourceResult{
+		Contents: []*mcp.ResourceContents{{URI: uri, MIMEType: mt, Text: string(data)}},
+	}
+}
@@ -2,10 +2,18 @@ package main
 
 import (
 	"context"
-	"encoding/base64"
+	"fmt"
+	"sync"
+	"time"
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 	"github.com/sirupsen/logrus"
+	"go-whisper-mcp/pkg"
+	"go-whisper-mcp/pkg/logging"
+	"go-whisper-mcp/pkg/metrics"
+	"go-whisper-mcp/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 )
 
 // MCP 工具参数结构体定义
@@ -16,6 +24,18 @@ type TranscribeArgs struct {
 	Model   string   `json:"model" jsonschema:"模型规格或文件名（例如 tiny、medium、large-v3、ggml-small.bin）"`
 	Lang    string   `json:"lang" jsonschema:"语言代码或“auto”（例如 zh、en、auto）"`
 	Threads int      `json:"t" jsonschema:"线程"`
+
+	Verbosity string `json:"verbosity,omitempty" jsonschema:"输出详细程度：summary（仅状态与 transcript_id）、text（默认，附全文）、segments（附逐段时间戳）"`
+	MaxChars  int    `json:"max_chars,omitempty" jsonschema:"每个文件返回文本的最大字符数，0 表示不限制；完整结果可通过 transcript://{id} 读取"`
+	TimeoutS  int    `json:"timeout_s,omitempty" jsonschema:"最长耗时（秒），超时返回 TIMEOUT；0 表示只受服务端上限限制"`
+
+	Preprocess  *pkg.AudioFilters `json:"preprocess,omitempty" jsonschema:"可选的音频预处理：降噪、响度归一化、高/低通、声道或音轨选择、裁剪、变速"`
+	AudioStream string            `json:"audio_stream,omitempty" jsonschema:"多音轨媒体：音频流序号、语言代码（如 en、eng）或 all（每条音频流单独一项结果）；可先用 inspect_media 查看"`
+}
+
+// InspectArgs 媒体检查的参数
+type InspectArgs struct {
+	InPath string `json:"in_path" jsonschema:"本地文件路径或 URL（与 transcribe 的 in_paths 单项相同）"`
 }
 
 // InitMCPServer 初始化 MCP Server
@@ -32,6 +52,15 @@ func InitMCPServer(appServer *AppServer) *mcp.Server {
 	// 注册所有工具
 	registerTools(server, appServer)
 
+	// 注册资源：转录结果 / 模型 / 任务
+	registerResources(server, appServer)
+
+	// 注册提示词：总结 / 会议纪要 / 待办 / 章节
+	registerPrompts(server, appServer)
+
+	// 请求 ID、工具调用指标与链路追踪（从 _meta.traceparent 继续上游链路）
+	server.AddReceivingMiddleware(requestIDMCPMiddleware, toolMetricsMiddleware, tracingMCPMiddleware)
+
 	logrus.Info("MCP Server initialized with official SDK")
 
 	return server
@@ -43,64 +72,153 @@ func registerTools(server *mcp.Server, appServer *AppServer) {
 	mcp.AddTool(server,
 		&mcp.Tool{
 			Name:        "transcribe",
-			Description: "将 mp4/wav 转录为文本（支持 model/lang/threads）",
+			Description: "将音视频转录为文本（支持 model/lang/threads）；返回结构化结果，可用 verbosity / max_chars 控制返回内容大小",
 		},
-		func(ctx context.Context, req *mcp.CallToolRequest, args TranscribeArgs) (*mcp.CallToolResult, any, error) {
-			argsMap := map[string]any{
-				"in_paths": convertStringsToInterfaces(args.InPaths),
-				"model":    args.Model,
-				"lang":     args.Lang,
-				"t":        args.Threads,
+		func(ctx context.Context, req *mcp.CallToolRequest, args TranscribeArgs) (*mcp.CallToolResult, *TranscribeToolOutput, error) {
+			// 客户端带 progressToken 时，把下载进度转发为 MCP progress 通知
+			if token := req.Params.GetProgressToken(); token != nil && req.Session != nil {
+				ctx = pkg.WithProgress(ctx, newMCPProgress(ctx, req.Session, token))
 			}
-			r := appServer.handleTranscribe(ctx, argsMap) // *MCPToolResult
-
-			// 1) 内容区：摘要文本 / 图片（不塞 structured）
-			res := convertToMCPResult(r)
+			return appServer.handleTranscribe(ctx, args)
+		},
+	)
 
-			return res, nil, nil
+	// 工具 2: 媒体检查（列出音频流）
+	mcp.AddTool(server,
+		&mcp.Tool{
+			Name:        "inspect_media",
+			Description: "用 ffprobe 列出音视频文件的音频流（序号、语言、编码、声道），用于多音轨文件选择 transcribe 的 audio_stream",
+		},
+		func(ctx context.Context, req *mcp.CallToolRequest, args InspectArgs) (*mcp.CallToolResult, *pkg.MediaInfo, error) {
+			return appServer.handleInspect(ctx, args)
 		},
 	)
 
-	logrus.Infof("Registered %d MCP tools", 8)
+	logrus.Infof("Registered %d MCP tools", 2)
 }
 
-// convertToMCPResult 将自定义的 MCPToolResult 转换为官方 SDK 的格式
-func convertToMCPResult(result *MCPToolResult) *mcp.CallToolResult {
-	var contents []mcp.Content
-	for _, c := range result.Content {
-		switch c.Type {
-		case "text":
-			contents = append(contents, &mcp.TextContent{Text: c.Text})
-		case "image":
-			// 解码 base64 字符串为 []byte
-			imageData, err := base64.StdEncoding.DecodeString(c.Data)
-			if err != nil {
-				logrus.WithError(err).Error("Failed to decode base64 image data")
-				// 如果解码失败，添加错误文本
-				contents = append(contents, &mcp.TextContent{
-					Text: "图片数据解码失败: " + err.Error(),
-				})
-			} else {
-				contents = append(contents, &mcp.ImageContent{
-					Data:     imageData,
-					MIMEType: c.MimeType,
-				})
+// requestIDMCPMiddleware 为每个 MCP 请求附加请求 ID：HTTP 传输沿用 X-Request-ID（由 requestIDMiddleware 补齐），stdio 传输逐个生成
+func requestIDMCPMiddleware(next mcp.MethodHandler) mcp.MethodHandler {
+	return func(ctx context.Context, method string, req mcp.Request) (mcp.Result, error) {
+		id := ""
+		if extra := req.GetExtra(); extra != nil && extra.Header != nil {
+			id = extra.Header.Get(logging.HeaderRequestID)
+		}
+		if !logging.ValidRequestID(id) {
+			id = logging.NewRequestID()
+		}
+		ctx = logging.WithRequestID(ctx, id)
+		logging.FromContext(ctx).WithField("mcp_method", method).Debug("mcp request")
+		return next(ctx, method, req)
+	}
+}
+
+// toolMetricsMiddleware 记录 tools/call 的调用数与耗时；返回 error 或 isError 结果均计为失败
+func toolMetricsMiddleware(next mcp.MethodHandler) mcp.MethodHandler {
+	return func(ctx context.Context, method string, req mcp.Request) (mcp.Result, error) {
+		call, ok := req.(*mcp.CallToolRequest)
+		if !ok || call.Params == nil {
+			return next(ctx, method, req)
+		}
+		start := time.Now()
+		res, err := next(ctx, method, req)
+		failed := err != nil
+		if r, ok := res.(*mcp.CallToolResult); ok && r != nil && r.IsError {
+			failed = true
+		}
+		metrics.MCPToolCalls.WithLabelValues(call.Params.Name, metrics.Status(!failed)).Inc()
+		metrics.MCPToolDuration.WithLabelValues(call.Params.Name).Observe(time.Since(start).Seconds())
+		return res, err
+	}
+}
+
+// tracingMCPMiddleware 为工具调用、提示词与资源读取创建服务端 span
+func tracingMCPMiddleware(next mcp.MethodHandler) mcp.MethodHandler {
+	return func(ctx context.Context, method string, req mcp.Request) (mcp.Result, error) {
+		var (
+			meta   map[string]any
+			target string
+		)
+		switch r := req.(type) {
+		case *mcp.CallToolRequest:
+			if r.Params != nil {
+				meta, target = r.Params.Meta, r.Params.Name
+			}
+		case *mcp.GetPromptRequest:
+			if r.Params != nil {
+				meta, target = r.Params.Meta, r.Params.Name
 			}
+		case *mcp.ReadResourceRequest:
+			if r.Params != nil {
+				meta, target = r.Params.Meta, r.Params.URI
+			}
+		default:
+			return next(ctx, method, req)
+		}
+
+		ctx = tracing.Extract(ctx, tracing.MetaCarrier(meta))
+		ctx, span := tracing.StartServer(ctx, method+" "+target,
+			attribute.String("mcp.method.name", method),
+			attribute.String("mcp.target", target))
+		res, err := next(ctx, method, req)
+		if r, ok := res.(*mcp.CallToolResult); ok && r != nil && r.IsError && err == nil {
+			span.SetStatus(codes.Error, "tool returned an error result")
 		}
+		tracing.End(span, err)
+		return res, err
 	}
+}
 
-	return &mcp.CallToolResult{
-		StructuredContent: result.StructuredContent,
-		Content:           contents,
-		IsError:           result.IsError,
+// mcpProgress 将下载进度事件转为 notifications/progress（多个文件累计，保证单调递增）
+type mcpProgress struct {
+	ctx     context.Context
+	session *mcp.ServerSession
+	token   any
+
+	mu    sync.Mutex
+	done  map[string]int64
+	total map[string]int64
+}
+
+func newMCPProgress(ctx context.Context, session *mcp.ServerSession, token any) *mcpProgress {
+	return &mcpProgress{
+		ctx:     ctx,
+		session: session,
+		token:   token,
+		done:    map[string]int64{},
+		total:   map[string]int64{},
 	}
 }
 
-// convertStringsToInterfaces 辅助函数：将 []string 转换为 []interface{}
-func convertStringsToInterfaces(strs []string) []interface{} {
-	result := make([]interface{}, len(strs))
-	for i, s := range strs {
-		result[i] = s
+// Report 实现 pkg.ProgressReporter
+func (p *mcpProgress) Report(e pkg.ProgressEvent) {
+	key := e.Kind + "/" + e.Name
+	p.mu.Lock()
+	if e.BytesDone > p.done[key] {
+		p.done[key] = e.BytesDone
+	}
+	if e.BytesTotal > 0 {
+		p.total[key] = e.BytesTotal
+	}
+	var done, total int64
+	for _, v := range p.done {
+		done += v
+	}
+	for _, v := range p.total {
+		total += v
+	}
+	p.mu.Unlock()
+
+	msg := fmt.Sprintf("%s %s: %s", e.Kind, e.Phase, e.Name)
+	if e.Error != "" {
+		msg += " (" + e.Error + ")"
+	}
+	if err := p.session.NotifyProgress(p.ctx, &mcp.ProgressNotificationParams{
+		ProgressToken: p.token,
+		Message:       msg,
+		Progress:      float64(done),
+		Total:         float64(total),
+	}); err != nil {
+		logging.FromContext(p.ctx).WithError(err).Debug("MCP progress notification failed")
 	}
-	return result
 }
@@ -1,10 +1,22 @@
 package main
 
 import (
+	"crypto/subtle"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
+	"go-whisper-mcp/configs"
+	"go-whisper-mcp/pkg/logging"
+	"go-whisper-mcp/pkg/metrics"
+	"go-whisper-mcp/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
 )
 
 // corsMiddleware CORS 中间件
@@ -12,7 +24,8 @@ func corsMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key, X-Request-ID")
+		c.Header("Access-Control-Expose-Headers", "X-Request-ID")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(http.StatusNoContent)
@@ -23,10 +36,165 @@ func corsMiddleware() gin.HandlerFunc {
 	}
 }
 
+// authMiddleware 校验 API Key（Authorization: Bearer <key> 或 X-API-Key）；未配置 auth.api_keys 时放行。
+// 通过后把 key 的名称记为 account，用于日志与限流；openAI 为 true 时以 OpenAI 格式返回错误
+func authMiddleware(openAI bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		keys := configs.Get().Auth.APIKeys
+		if len(keys) == 0 {
+			c.Next()
+			return
+		}
+		presented := c.GetHeader("X-API-Key")
+		if s, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok {
+			presented = strings.TrimSpace(s)
+		}
+		for _, k := range keys {
+			if presented != "" && subtle.ConstantTimeCompare([]byte(presented), []byte(k.Key)) == 1 {
+				c.Set("account", k.Name)
+				c.Next()
+				return
+			}
+		}
+
+		c.Header("WWW-Authenticate", `Bearer realm="go-whisper-mcp"`)
+		if openAI {
+			respondOpenAIError(c, http.StatusUnauthorized, "invalid_request_error", "Incorrect API key provided", "", "invalid_api_key")
+		} else {
+			respondError(c, http.StatusUnauthorized, "UNAUTHORIZED", "missing or invalid API key", nil)
+		}
+		c.Abort()
+	}
+}
+
+// adminMiddleware 管理接口只在配置了 auth.api_keys 时开放：未配置时返回 403，而不是像其他接口一样放行
+func adminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if len(configs.Get().Auth.APIKeys) == 0 {
+			respondError(c, http.StatusForbidden, "ADMIN_DISABLED", "admin API requires auth.api_keys to be configured", nil)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// rateLimitMiddleware 按 account（未鉴权时按客户端 IP）限流（limits.rate_limit 次/秒，突发 limits.rate_burst）
+func rateLimitMiddleware(limiter *rateLimiter, openAI bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limits := configs.Get().Limits
+		if limits.RateLimit <= 0 {
+			c.Next()
+			return
+		}
+		client := c.GetString("account")
+		if client == "" {
+			client = "ip:" + c.ClientIP()
+		}
+		ok, wait := limiter.allow(client, limits.RateLimit, limits.RateBurst, time.Now())
+		if ok {
+			c.Next()
+			return
+		}
+
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		if openAI {
+			respondOpenAIError(c, http.StatusTooManyRequests, "requests", "Rate limit reached, please retry later", "", "rate_limit_exceeded")
+		} else {
+			respondError(c, http.StatusTooManyRequests, "RATE_LIMITED", "rate limit exceeded", map[string]any{"retry_after_s": wait.Seconds()})
+		}
+		c.Abort()
+	}
+}
+
+// requestIDMiddleware 沿用合法的 X-Request-ID，否则生成新的；写回响应头与请求头（供 MCP 处理器读取），并放入请求 ctx
+func requestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(logging.HeaderRequestID)
+		if !logging.ValidRequestID(id) {
+			id = logging.NewRequestID()
+			c.Request.Header.Set(logging.HeaderRequestID, id)
+		}
+		c.Header(logging.HeaderRequestID, id)
+		c.Set("request_id", id)
+		c.Request = c.Request.WithContext(logging.WithRequestID(c.Request.Context(), id))
+		c.Next()
+	}
+}
+
+// accessLogMiddleware 结构化访问日志；健康探针与指标抓取记为 debug，避免刷屏
+func accessLogMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		entry := logging.FromContext(c.Request.Context()).WithFields(logrus.Fields{
+			"method":     c.Request.Method,
+			"path":       c.Request.URL.Path,
+			"status":     c.Writer.Status(),
+			"latency_ms": time.Since(start).Milliseconds(),
+			"client_ip":  c.ClientIP(),
+		})
+		if account := c.GetString("account"); account != "" {
+			entry = entry.WithField("account", account)
+		}
+		route := c.FullPath()
+		if route == "/metrics" || strings.HasPrefix(route, "/health") {
+			entry.Debug("http request")
+			return
+		}
+		entry.Info("http request")
+	}
+}
+
+// metricsMiddleware 按路由模板记录请求数与耗时（不用原始路径，避免 /api/jobs/:id 等产生高基数标签）
+func metricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		metrics.HTTPRequests.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		metrics.HTTPDuration.WithLabelValues(c.Request.Method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// tracingMiddleware 从请求头（traceparent）继续上游链路，为每个请求创建服务端 span（指标抓取与健康探针除外）
+func tracingMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if route == "/metrics" || strings.HasPrefix(route, "/health") {
+			c.Next()
+			return
+		}
+		if route == "" {
+			route = "unmatched"
+		}
+		ctx := tracing.Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+		ctx, span := tracing.StartServer(ctx, c.Request.Method+" "+route,
+			attribute.String("http.request.method", c.Request.Method),
+			attribute.String("http.route", route),
+			attribute.String("url.path", c.Request.URL.Path))
+		defer span.End()
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	}
+}
+
 // errorHandlingMiddleware 错误处理中间件
 func errorHandlingMiddleware() gin.HandlerFunc {
 	return gin.CustomRecovery(func(c *gin.Context, recovered any) {
-		logrus.Errorf("服务器内部错误: %v, path: %s", recovered, c.Request.URL.Path)
+		logging.FromContext(c.Request.Context()).Errorf("服务器内部错误: %v, path: %s", recovered, c.Request.URL.Path)
 
 		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR",
 			"服务器内部错误", recovered)
@@ -0,0 +1,101 @@
+package main
+
+/*
+   // —— 不需要任何 #include 或 -I —— //
+   // 声明要用到的 C 符号（用最通用的签名，避免依赖 enum/typedef）：
+
+   // ggml 的日志回调设置：一直存在
+   extern void ggml_log_set(void (*log_cb)(int, const char*, void*), void*);
+
+   // whisper 的日志回调设置：不同版本可能没有。用弱符号防止链接报错
+   void whisper_log_set(void (*log_cb)(int, const char*, void*), void*) __attribute__((weak));
+
+   // Go 侧的接收函数（native_log_export.go）
+   extern void goNativeLog(int level, char* text);
+
+   static void forward_log(int level, const char * text, void * user_data) {
+       (void)user_data;
+       goNativeLog(level, (char *)text);
+   }
+
+   static void route_native_logs() {
+       // 如果当前链接的库里导出了 whisper_log_set，就调用；否则跳过
+       if (whisper_log_set) {
+           whisper_log_set(forward_log, 0);
+       }
+       // ggml 的总开关
+       ggml_log_set(forward_log, 0);
+   }
+*/
+import "C"
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"go-whisper-mcp/configs"
+)
+
+// ggml_log_level
+const (
+	ggmlLogNone  = 0
+	ggmlLogDebug = 1
+	ggmlLogInfo  = 2
+	ggmlLogWarn  = 3
+	ggmlLogError = 4
+	ggmlLogCont  = 5 // 接续上一条日志
+)
+
+// RouteNativeLogs 把 whisper.cpp / ggml 的日志转交 logrus：警告与错误始终输出，
+// info 与 debug 仅在 log.native 开启时输出（随配置热加载生效）
+func RouteNativeLogs() {
+	C.route_native_logs()
+}
+
+// nativeLog native 日志按行缓冲：C 侧常分多次输出同一行（如进度点），遇到换行才写出
+var nativeLog struct {
+	sync.Mutex
+	level int
+	buf   strings.Builder
+}
+
+func writeNativeLog(level int, text string) {
+	nativeLog.Lock()
+	defer nativeLog.Unlock()
+	if level != ggmlLogCont {
+		nativeLog.level = level
+	}
+	nativeLog.buf.WriteString(text)
+	s := nativeLog.buf.String()
+	i := strings.LastIndexByte(s, '\n')
+	if i < 0 {
+		return
+	}
+	nativeLog.buf.Reset()
+	nativeLog.buf.WriteString(s[i+1:])
+
+	lv, ok := nativeLogLevel(nativeLog.level)
+	if !ok {
+		return
+	}
+	for _, line := range strings.Split(s[:i], "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			logrus.WithField("source", "native").Log(lv, line)
+		}
+	}
+}
+
+// nativeLogLevel ggml 日志级别对应的 logrus 级别；未开启 log.native 时丢弃 info 与 debug
+func nativeLogLevel(level int) (logrus.Level, bool) {
+	switch level {
+	case ggmlLogError:
+		return logrus.ErrorLevel, true
+	case ggmlLogWarn:
+		return logrus.WarnLevel, true
+	case ggmlLogDebug:
+		return logrus.DebugLevel, configs.GetLogNative()
+	default:
+		return logrus.InfoLevel, configs.GetLogNative()
+	}
+}
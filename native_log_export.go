@@ -0,0 +1,10 @@
+package main
+
+import "C"
+
+// goNativeLog whisper.cpp / ggml 日志回调的 Go 侧入口；可能在推理线程上调用
+//
+//export goNativeLog
+func goNativeLog(level C.int, text *C.char) {
+	writeNativeLog(int(level), C.GoString(text))
+}
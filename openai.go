@@ -0,0 +1,322 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go-whisper-mcp/configs"
+	"go-whisper-mcp/pkg"
+	"go-whisper-mcp/pkg/downloader"
+	"go-whisper-mcp/pkg/logging"
+	"go-whisper-mcp/whisper"
+)
+
+// OpenAI 兼容的音频接口：POST /v1/audio/transcriptions 与 /v1/audio/translations（multipart/form-data）
+
+// response_format 取值
+const (
+	openAIFormatJSON        = "json"
+	openAIFormatText        = "text"
+	openAIFormatSRT         = "srt"
+	openAIFormatVerboseJSON = "verbose_json"
+	openAIFormatVTT         = "vtt"
+)
+
+// OpenAIErrorResponse OpenAI 格式的错误响应
+type OpenAIErrorResponse struct {
+	Error OpenAIError `json:"error"`
+}
+
+// OpenAIError 错误详情
+type OpenAIError struct {
+	Message string  `json:"message"`
+	Type    string  `json:"type"`
+	Param   *string `json:"param"`
+	Code    *string `json:"code"`
+}
+
+// OpenAITranscription response_format=json
+type OpenAITranscription struct {
+	Text string `json:"text"`
+}
+
+// OpenAIVerboseTranscription response_format=verbose_json
+type OpenAIVerboseTranscription struct {
+	Task     string          `json:"task"`
+	Language string          `json:"language"`
+	Duration float64         `json:"duration"`
+	Text     string          `json:"text"`
+	Segments []OpenAISegment `json:"segments,omitempty"`
+	Words    []OpenAIWord    `json:"words,omitempty"`
+}
+
+// OpenAISegment verbose_json 分段
+type OpenAISegment struct {
+	ID               int     `json:"id"`
+	Seek             int     `json:"seek"`
+	Start            float64 `json:"start"`
+	End              float64 `json:"end"`
+	Text             string  `json:"text"`
+	Tokens           []int   `json:"tokens"`
+	Temperature      float64 `json:"temperature"`
+	AvgLogprob       float64 `json:"avg_logprob"`
+	CompressionRatio float64 `json:"compression_ratio"`
+	NoSpeechProb     float64 `json:"no_speech_prob"` // whisper.cpp 绑定未提供，恒为 0
+}
+
+// OpenAIWord verbose_json 词级时间戳
+type OpenAIWord struct {
+	Word  string  `json:"word"`
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+}
+
+// handleOpenAIAudio 处理 OpenAI 音频接口；task 为 transcribe 或 translate
+func handleOpenAIAudio(a *AppServer, task string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if limit := configs.GetMediaMaxBytes(); limit > 0 {
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		}
+		fh, err := c.FormFile("file")
+		if err != nil {
+			respondOpenAIError(c, http.StatusBadRequest, "invalid_request_error", "file is required: "+err.Error(), "file", "")
+			return
+		}
+
+		format := c.DefaultPostForm("response_format", openAIFormatJSON)
+		switch format {
+		case openAIFormatJSON, openAIFormatText, openAIFormatSRT, openAIFormatVerboseJSON, openAIFormatVTT:
+		default:
+			respondOpenAIError(c, http.StatusBadRequest, "invalid_request_error",
+				"response_format must be one of json, text, srt, verbose_json, vtt", "response_format", "")
+			return
+		}
+
+		var temperature float64
+		if s := c.PostForm("temperature"); s != "" {
+			if temperature, err = strconv.ParseFloat(s, 32); err != nil || temperature < 0 || temperature > 1 {
+				respondOpenAIError(c, http.StatusBadRequest, "invalid_request_error", "temperature must be a number between 0 and 1", "temperature", "")
+				return
+			}
+		}
+
+		// 词/分段时间戳只用于转录，且必须配合 verbose_json
+		var granularities []string
+		if task == TaskTranscribe {
+			granularities = append(c.PostFormArray("timestamp_granularities[]"), c.PostFormArray("timestamp_granularities")...)
+		}
+		for _, g := range granularities {
+			if g != "word" && g != "segment" {
+				respondOpenAIError(c, http.StatusBadRequest, "invalid_request_error",
+					"timestamp_granularities must contain only word or segment", "timestamp_granularities", "")
+				return
+			}
+		}
+		if len(granularities) > 0 && format != openAIFormatVerboseJSON {
+			respondOpenAIError(c, http.StatusBadRequest, "invalid_request_error",
+				"response_format must be verbose_json to use timestamp_granularities", "timestamp_granularities", "")
+			return
+		}
+		// 扩展字段：JSON 形式的音频预处理选项
+		var preprocess *pkg.AudioFilters
+		if s := c.PostForm("preprocess"); s != "" {
+			if err := json.Unmarshal([]byte(s), &preprocess); err != nil {
+				respondOpenAIError(c, http.StatusBadRequest, "invalid_request_error", "preprocess must be a JSON object: "+err.Error(), "preprocess", "")
+				return
+			}
+			if err := preprocess.Validate(); err != nil {
+				respondOpenAIError(c, http.StatusBadRequest, "invalid_request_error", err.Error(), "preprocess", "")
+				return
+			}
+		}
+
+		// 扩展字段：多音轨媒体选择音频流（序号或语言代码）；OpenAI 响应只有一份结果，不支持 all
+		audioStream := strings.TrimSpace(c.PostForm("audio_stream"))
+		if err := pkg.ValidateStreamSelector(audioStream); err != nil || strings.EqualFold(audioStream, pkg.StreamAll) ||
+			(audioStream != "" && preprocess != nil && preprocess.Stream != nil) {
+			respondOpenAIError(c, http.StatusBadRequest, "invalid_request_error",
+				"audio_stream must be a stream index or a language code, and cannot be combined with preprocess.stream", "audio_stream", "")
+			return
+		}
+
+		withWords := slices.Contains(granularities, "word")
+		withSegments := len(granularities) == 0 || slices.Contains(granularities, "segment")
+
+		// 上传文件落盘到 MEDIA_DIR 下的临时目录，请求结束后删除
+		if err := os.MkdirAll(configs.GetMediaPath(), 0755); err != nil {
+			respondOpenAIError(c, http.StatusInternalServerError, "server_error", err.Error(), "", "")
+			return
+		}
+		dir, err := os.MkdirTemp(configs.GetMediaPath(), "upload-")
+		if err != nil {
+			respondOpenAIError(c, http.StatusInternalServerError, "server_error", err.Error(), "", "")
+			return
+		}
+		defer os.RemoveAll(dir)
+		name := filepath.Base(fh.Filename)
+		if name == "." || name == "/" || strings.HasPrefix(name, ".") {
+			name = "audio" + filepath.Ext(name)
+		}
+		localPath := filepath.Join(dir, name)
+		if err := c.SaveUploadedFile(fh, localPath); err != nil {
+			respondOpenAIError(c, http.StatusInternalServerError, "server_error", err.Error(), "", "")
+			return
+		}
+
+		out, err := a.whisperService.Transcribe(c.Request.Context(), &TranscribeRequest{
+			InPaths:        []string{localPath},
+			Model:          openAIModel(c.PostForm("model"), a.defaultModel()),
+			Lang:           c.PostForm("language"),
+			ModelsDir:      a.modelsDir(),
+			MaxFiles:       1,
+			Task:           task,
+			Prompt:         c.PostForm("prompt"),
+			Temperature:    float32(temperature),
+			WordTimestamps: withWords,
+			Preprocess:     preprocess,
+			AudioStream:    audioStream,
+		})
+		switch code := transcribeErrorCode(err); code {
+		case "SHUTTING_DOWN":
+			respondOpenAIError(c, http.StatusServiceUnavailable, "server_error", err.Error(), "", code)
+			return
+		case "CANCELLED":
+			respondOpenAIError(c, statusClientClosedRequest, "invalid_request_error", err.Error(), "", code)
+			return
+		case "TIMEOUT":
+			respondOpenAIError(c, http.StatusGatewayTimeout, "server_error", err.Error(), "", code)
+			return
+		}
+		if errors.Is(err, downloader.ErrTooManyFiles) {
+			respondOpenAIError(c, http.StatusBadRequest, "invalid_request_error", err.Error(), "file", "")
+			return
+		}
+		if err != nil {
+			respondOpenAIError(c, http.StatusInternalServerError, "server_error", err.Error(), "", "")
+			return
+		}
+		if len(out.Results) == 0 || !out.Results[0].IsSuccess {
+			msg := "no transcription result"
+			if len(out.Results) > 0 {
+				msg = out.Results[0].Error
+			}
+			respondOpenAIError(c, http.StatusBadRequest, "invalid_request_error", msg, "file", "")
+			return
+		}
+		r := out.Results[0]
+		logging.FromContext(c.Request.Context()).Infof("%s %s %s %d", c.Request.Method, c.Request.URL.Path, format, http.StatusOK)
+
+		switch format {
+		case openAIFormatJSON:
+			c.JSON(http.StatusOK, OpenAITranscription{Text: openAIText(r)})
+		case openAIFormatText:
+			c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(openAIText(r)+"\n"))
+		case openAIFormatSRT, openAIFormatVTT:
+			f := FormatSRT
+			if format == openAIFormatVTT {
+				f = FormatVTT
+			}
+			data, ct, err := FormatTranscript(r, f)
+			if err != nil {
+				respondOpenAIError(c, http.StatusInternalServerError, "server_error", err.Error(), "", "")
+				return
+			}
+			c.Data(http.StatusOK, ct, data)
+		case openAIFormatVerboseJSON:
+			c.JSON(http.StatusOK, verboseTranscription(r, task, temperature, withSegments, withWords))
+		}
+	}
+}
+
+// openAIModel OpenAI 模型名映射：whisper-1 与 gpt-4o 系列转录模型使用服务默认模型，其余按本地别名 / 文件名处理
+func openAIModel(name, defaultModel string) string {
+	switch name {
+	case "", "whisper-1", "gpt-4o-transcribe", "gpt-4o-mini-transcribe":
+		return defaultModel
+	}
+	return name
+}
+
+// openAIText 拼接分段文本（whisper 分段自带前导空格）
+func openAIText(r *TranscribeResponse) string {
+	var b strings.Builder
+	for _, seg := range r.Segments {
+		b.WriteString(seg.Text)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+func verboseTranscription(r *TranscribeResponse, task string, temperature float64, withSegments, withWords bool) *OpenAIVerboseTranscription {
+	v := &OpenAIVerboseTranscription{
+		Task:     task,
+		Language: whisper.LanguageName(r.Language),
+		Duration: r.AudioDuration,
+		Text:     openAIText(r),
+	}
+	for i, seg := range r.Segments {
+		start, end := segmentBounds(seg)
+		if withSegments {
+			tokens := seg.Tokens
+			if tokens == nil {
+				tokens = []int{}
+			}
+			v.Segments = append(v.Segments, OpenAISegment{
+				ID:               i,
+				Seek:             int(start.Milliseconds()/30000) * 3000, // 所在 30s 窗口的起点（10ms 帧）
+				Start:            start.Seconds(),
+				End:              end.Seconds(),
+				Text:             seg.Text,
+				Tokens:           tokens,
+				Temperature:      temperature,
+				AvgLogprob:       seg.AvgLogprob,
+				CompressionRatio: compressionRatio(seg.Text),
+			})
+		}
+		if withWords {
+			for _, w := range seg.Words {
+				v.Words = append(v.Words, OpenAIWord{
+					Word:  w.Word,
+					Start: float64(w.StartMs) / 1000,
+					End:   float64(w.EndMs) / 1000,
+				})
+			}
+		}
+	}
+	return v
+}
+
+// compressionRatio 文本长度与 zlib 压缩后长度之比（与 openai-whisper 的计算方式一致）
+func compressionRatio(text string) float64 {
+	if text == "" {
+		return 0
+	}
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	_, _ = zw.Write([]byte(text))
+	_ = zw.Close()
+	return float64(len(text)) / float64(buf.Len())
+}
+
+// respondOpenAIError 返回 OpenAI 格式的错误
+func respondOpenAIError(c *gin.Context, statusCode int, typ, message, param, code string) {
+	e := OpenAIError{Message: message, Type: typ}
+	if param != "" {
+		e.Param = &param
+	}
+	if code != "" {
+		e.Code = &code
+	}
+
+	logging.FromContext(c.Request.Context()).Errorf("%s %s %d %s", c.Request.Method, c.Request.URL.Path, statusCode, message)
+
+	c.JSON(statusCode, OpenAIErrorResponse{Error: e})
+}
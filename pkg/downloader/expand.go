@@ -0,0 +1,280 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ErrTooManyFiles 展开后的文件数超过上限
+var ErrTooManyFiles = errors.New("too many input files")
+
+// DefaultMediaExts 目录展开时默认收录的音视频扩展名
+var DefaultMediaExts = []string{
+	".wav", ".mp3", ".m4a", ".aac", ".flac", ".ogg", ".opus", ".wma", ".amr", ".aiff",
+	".mp4", ".mkv", ".mov", ".avi", ".webm", ".flv", ".wmv", ".ts", ".m4v", ".3gp",
+}
+
+// ExpandOptions 目录 / 通配符展开选项（只作用于展开出的文件，显式列出的文件不受影响）
+type ExpandOptions struct {
+	Include  []string // glob（支持 **）；不含 / 时匹配文件名，否则匹配相对路径；为空时目录只收录 DefaultMediaExts
+	Exclude  []string // 同 Include，命中即排除
+	MaxFiles int      // 展开后的总数上限，0 表示不限制
+}
+
+// ExpandedRef 展开后的单个引用
+type ExpandedRef struct {
+	Ref    string // 具体文件或原样保留的远程引用
+	Origin string // 来源目录/模式；非展开项为空
+	Err    error  // 展开失败（如模式没有匹配任何文件）
+}
+
+// ExpandInputs 将本地目录与 glob 模式展开为具体文件：组内按路径排序，组间保持请求顺序，重复文件只保留第一次
+// URL、s3:// 等远程引用原样保留
+func ExpandInputs(refs []string, opts ExpandOptions) ([]ExpandedRef, error) {
+	var out []ExpandedRef
+	seen := map[string]bool{}
+	add := func(r ExpandedRef) error {
+		if r.Err == nil {
+			key := r.Ref
+			if abs, err := filepath.Abs(r.Ref); err == nil && !IsMediaURL(r.Ref) && !IsS3URI(r.Ref) {
+				key = abs
+			}
+			if seen[key] {
+				return nil
+			}
+			seen[key] = true
+		}
+		out = append(out, r)
+		if opts.MaxFiles > 0 && len(out) > opts.MaxFiles {
+			return fmt.Errorf("%w: more than %d", ErrTooManyFiles, opts.MaxFiles)
+		}
+		return nil
+	}
+
+	for _, ref := range refs {
+		if IsMediaURL(ref) || IsS3URI(ref) {
+			if err := add(ExpandedRef{Ref: ref}); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		// 先按字面路径查找：已存在的文件/目录即使名称含 [ ] * ?（如 yt-dlp 的 "Title [id].mp4"）也不当作模式
+		var (
+			files []string
+			err   error
+		)
+		fi, statErr := os.Stat(ref)
+		switch {
+		case statErr == nil && fi.IsDir():
+			files, err = expandDir(ref, "", opts)
+		case statErr != nil && hasGlobMeta(ref):
+			files, err = expandGlob(ref, opts)
+		default:
+			// 普通文件（或不存在，留给解析阶段报错）
+			if err := add(ExpandedRef{Ref: ref}); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if errors.Is(err, ErrTooManyFiles) {
+			return nil, err
+		}
+		if err == nil && len(files) == 0 {
+			err = fmt.Errorf("no files match %s", ref)
+		}
+		if err != nil {
+			if err := add(ExpandedRef{Ref: ref, Origin: ref, Err: err}); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		for _, f := range files {
+			if err := add(ExpandedRef{Ref: f, Origin: ref}); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return out, nil
+}
+
+// expandGlob 拆出不含通配符的根目录，遍历后用剩余模式匹配相对路径
+func expandGlob(pattern string, opts ExpandOptions) ([]string, error) {
+	pattern = filepath.ToSlash(pattern)
+	segs := strings.Split(pattern, "/")
+	i := 0
+	for i < len(segs) && !hasGlobMeta(segs[i]) {
+		i++
+	}
+	root := strings.Join(segs[:i], "/")
+	switch {
+	case root == "" && strings.HasPrefix(pattern, "/"):
+		root = "/"
+	case root == "":
+		root = "."
+	}
+	rel := strings.Join(segs[i:], "/")
+	if _, err := path.Match(strings.ReplaceAll(rel, "**", "*"), ""); err != nil {
+		return nil, fmt.Errorf("invalid pattern %s: %w", pattern, err)
+	}
+	if !isDir(root) {
+		return nil, fmt.Errorf("no files match %s", pattern)
+	}
+	return expandDir(filepath.FromSlash(root), rel, opts)
+}
+
+// expandDir 递归遍历目录；match 非空时相对路径须匹配该模式，且只进入可能匹配的子目录
+// 无权限读取的子目录 / 文件直接跳过，不影响其余结果
+func expandDir(root, match string, opts ExpandOptions) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p != root && errors.Is(err, fs.ErrPermission) {
+				if d != nil && d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			return err
+		}
+		name := d.Name()
+		if p != root && strings.HasPrefix(name, ".") {
+			// 跳过隐藏文件/目录
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			if p != root && match != "" && !dirMayMatch(match, rel) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if match != "" && !matchGlob(match, rel) {
+			return nil
+		}
+		if !included(rel, match != "", opts) {
+			return nil
+		}
+		files = append(files, p)
+		if opts.MaxFiles > 0 && len(files) > opts.MaxFiles {
+			return fmt.Errorf("%w: more than %d under %s", ErrTooManyFiles, opts.MaxFiles, root)
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, ErrTooManyFiles) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("walk %s: %w", root, err)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// included 应用 include / exclude；没有 include 且不是模式展开时按扩展名过滤
+func included(rel string, byPattern bool, opts ExpandOptions) bool {
+	for _, p := range opts.Exclude {
+		if matchFilter(p, rel) {
+			return false
+		}
+	}
+	if len(opts.Include) > 0 {
+		for _, p := range opts.Include {
+			if matchFilter(p, rel) {
+				return true
+			}
+		}
+		return false
+	}
+	if byPattern {
+		return true
+	}
+	ext := strings.ToLower(path.Ext(rel))
+	for _, e := range DefaultMediaExts {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+func matchFilter(pattern, rel string) bool {
+	pattern = filepath.ToSlash(strings.TrimSpace(pattern))
+	if pattern == "" {
+		return false
+	}
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(rel))
+		return ok
+	}
+	return matchGlob(pattern, rel)
+}
+
+// matchGlob 按 / 分段匹配，** 匹配任意层目录
+func matchGlob(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(ps, ns []string) bool {
+	for len(ps) > 0 {
+		if ps[0] == "**" {
+			for i := 0; i <= len(ns); i++ {
+				if matchSegments(ps[1:], ns[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(ns) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(ps[0], ns[0]); !ok {
+			return false
+		}
+		ps, ns = ps[1:], ns[1:]
+	}
+	return len(ns) == 0
+}
+
+// dirMayMatch 目录 rel 之下是否可能有文件匹配 pattern：逐段匹配到 ** 为止，
+// 不含 ** 时目录层数必须少于模式段数（如 /*.mp4 只看根目录，不遍历整棵树）
+func dirMayMatch(pattern, rel string) bool {
+	ps, ns := strings.Split(pattern, "/"), strings.Split(rel, "/")
+	for i, n := range ns {
+		if ps[i] == "**" {
+			return true
+		}
+		if i == len(ps)-1 {
+			return false
+		}
+		if ok, _ := path.Match(ps[i], n); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func hasGlobMeta(s string) bool {
+	return strings.ContainsAny(s, "*?[")
+}
+
+func isDir(p string) bool {
+	fi, err := os.Stat(p)
+	return err == nil && fi.IsDir()
+}
@@ -0,0 +1,134 @@
+package downloader
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func touch(t *testing.T, paths ...string) {
+	t.Helper()
+	for _, p := range paths {
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// 名称含 [ ] * ? 的已有文件按字面路径处理，而不是当作模式
+func TestExpandInputsLiteralFileWithGlobMeta(t *testing.T) {
+	dir := t.TempDir()
+	names := []string{"Talk [dQw4w9WgXcQ].mp4", "meeting [1].mp3", "what?.wav", "a*b.m4a"}
+	var refs []string
+	for _, n := range names {
+		p := filepath.Join(dir, n)
+		touch(t, p)
+		refs = append(refs, p)
+	}
+	// 同目录下能被 [1] 当作字符类匹配到的文件不应被带出
+	touch(t, filepath.Join(dir, "meeting 1.mp3"))
+
+	got, err := ExpandInputs(refs, ExpandOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(refs) {
+		t.Fatalf("got %d refs, want %d: %+v", len(got), len(refs), got)
+	}
+	for i, r := range got {
+		if r.Err != nil || r.Ref != refs[i] || r.Origin != "" {
+			t.Errorf("ref %d = %+v, want literal %q", i, r, refs[i])
+		}
+	}
+
+	// 不存在的路径仍按模式展开
+	got, err = ExpandInputs([]string{filepath.Join(dir, "meeting [1].mp3"), filepath.Join(dir, "meeting [0-9].mp3")}, ExpandOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[1].Ref != filepath.Join(dir, "meeting 1.mp3") || got[1].Origin == "" {
+		t.Errorf("pattern expansion = %+v", got)
+	}
+}
+
+func TestExpandGlobDepth(t *testing.T) {
+	dir := t.TempDir()
+	touch(t,
+		filepath.Join(dir, "a.mp4"),
+		filepath.Join(dir, "sub", "b.mp4"),
+		filepath.Join(dir, "sub", "deep", "c.mp4"),
+	)
+
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{"*.mp4", []string{"a.mp4"}},
+		{"*/*.mp4", []string{"sub/b.mp4"}},
+		{"s*/*/*.mp4", []string{"sub/deep/c.mp4"}},
+		{"**/*.mp4", []string{"a.mp4", "sub/b.mp4", "sub/deep/c.mp4"}},
+	}
+	for _, tt := range tests {
+		files, err := expandGlob(filepath.Join(dir, tt.pattern), ExpandOptions{})
+		if err != nil {
+			t.Errorf("expandGlob(%q): %v", tt.pattern, err)
+			continue
+		}
+		var rels []string
+		for _, f := range files {
+			rel, _ := filepath.Rel(dir, f)
+			rels = append(rels, filepath.ToSlash(rel))
+		}
+		if !reflect.DeepEqual(rels, tt.want) {
+			t.Errorf("expandGlob(%q) = %v, want %v", tt.pattern, rels, tt.want)
+		}
+	}
+}
+
+// 不可读的子目录被跳过，不影响其余文件
+func TestExpandDirSkipsUnreadable(t *testing.T) {
+	dir := t.TempDir()
+	locked := filepath.Join(dir, "locked")
+	touch(t, filepath.Join(dir, "a.mp4"), filepath.Join(locked, "b.mp4"))
+	if err := os.Chmod(locked, 0); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chmod(locked, 0o755) })
+	if _, err := os.ReadDir(locked); err == nil {
+		t.Skip("running with privileges that bypass directory permissions")
+	}
+
+	for _, match := range []string{"", "**/*.mp4"} {
+		files, err := expandDir(dir, match, ExpandOptions{})
+		if err != nil {
+			t.Fatalf("expandDir(%q): %v", match, err)
+		}
+		if len(files) != 1 || filepath.Base(files[0]) != "a.mp4" {
+			t.Errorf("expandDir(%q) = %v, want only a.mp4", match, files)
+		}
+	}
+}
+
+func TestDirMayMatch(t *testing.T) {
+	tests := []struct {
+		pattern, rel string
+		want         bool
+	}{
+		{"*.mp4", "sub", false},
+		{"*/*.mp4", "sub", true},
+		{"*/*.mp4", "sub/deep", false},
+		{"s*/x/*.mp4", "other", false},
+		{"s*/x/*.mp4", "sub/x", true},
+		{"s*/**/*.mp4", "sub/a/b/c", true},
+		{"**/*.mp4", "a/b", true},
+	}
+	for _, tt := range tests {
+		if got := dirMayMatch(tt.pattern, tt.rel); got != tt.want {
+			t.Errorf("dirMayMatch(%q, %q) = %v, want %v", tt.pattern, tt.rel, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package downloader
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+)
+
+// fileLocks 按本地目标文件（缓存文件名前缀）串行化下载。包级共享：每个请求各自创建的
+// MediaDownloader / S3Source / YtDlpSource 写同一个 .part 时也互斥；无人持有或等待时删除
+var fileLocks = struct {
+	sync.Mutex
+	m map[string]*fileLock
+}{m: map[string]*fileLock{}}
+
+type fileLock struct {
+	sem  chan struct{}
+	refs int // 持有者 + 等待者
+}
+
+// lockFile 获取 base 的独占锁；ctx 取消时放弃等待并返回 ctx.Err()。成功时必须调用 unlock
+func lockFile(ctx context.Context, base string) (unlock func(), err error) {
+	if abs, err := filepath.Abs(base); err == nil {
+		base = abs
+	}
+
+	fileLocks.Lock()
+	l, ok := fileLocks.m[base]
+	if !ok {
+		l = &fileLock{sem: make(chan struct{}, 1)}
+		fileLocks.m[base] = l
+	}
+	l.refs++
+	fileLocks.Unlock()
+
+	release := func() {
+		fileLocks.Lock()
+		if l.refs--; l.refs == 0 {
+			delete(fileLocks.m, base)
+		}
+		fileLocks.Unlock()
+	}
+
+	select {
+	case l.sem <- struct{}{}:
+		return func() {
+			<-l.sem
+			release()
+		}, nil
+	case <-ctx.Done():
+		release()
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,113 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// wavBytes 可通过内容嗅探的最小 WAV 数据
+func wavBytes(n int) []byte {
+	b := make([]byte, 44+n)
+	copy(b, "RIFF")
+	copy(b[8:], "WAVEfmt ")
+	for i := 44; i < len(b); i++ {
+		b[i] = byte(i)
+	}
+	return b
+}
+
+// 每个请求各自创建下载器（与 MediaProcessor 的用法一致）时，同一 URL 的并发下载也只拉取一次且文件完整
+func TestDownloadMediaConcurrentSameURL(t *testing.T) {
+	data := wavBytes(256 << 10)
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Content-Type", "audio/wav")
+		w.WriteHeader(http.StatusOK)
+		// 分块慢速写出，保证并发请求在下载过程中到达
+		for off := 0; off < len(data); off += 32 << 10 {
+			end := min(off+32<<10, len(data))
+			_, _ = w.Write(data[off:end])
+			w.(http.Flusher).Flush()
+			time.Sleep(10 * time.Millisecond)
+		}
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	const n = 6
+	paths := make([]string, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			paths[i], errs[i] = NewMediaDownloader(dir).DownloadMedia(context.Background(), srv.URL+"/talk.wav")
+		}()
+	}
+	wg.Wait()
+
+	for i := range n {
+		if errs[i] != nil {
+			t.Fatalf("download %d: %v", i, errs[i])
+		}
+		if paths[i] != paths[0] {
+			t.Errorf("download %d resolved to %q, want %q", i, paths[i], paths[0])
+		}
+	}
+	got, err := os.ReadFile(paths[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("downloaded file corrupted: %d bytes, want %d", len(got), len(data))
+	}
+	if c := requests.Load(); c != 1 {
+		t.Errorf("server received %d requests, want 1", c)
+	}
+	if _, err := os.Stat(paths[0][:len(paths[0])-len(".wav")] + ".part"); !os.IsNotExist(err) {
+		t.Errorf(".part left behind: %v", err)
+	}
+}
+
+func TestLockFileCancelAndCleanup(t *testing.T) {
+	base := t.TempDir() + "/media_x"
+	unlock, err := lockFile(context.Background(), base)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := lockFile(ctx, base); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("waiting for a held lock: err = %v, want deadline exceeded", err)
+	}
+
+	acquired := make(chan func())
+	go func() {
+		u, _ := lockFile(context.Background(), base)
+		acquired <- u
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("lock acquired while held")
+	case <-time.After(20 * time.Millisecond):
+	}
+	unlock()
+	(<-acquired)()
+
+	fileLocks.Lock()
+	defer fileLocks.Unlock()
+	if len(fileLocks.m) != 0 {
+		t.Errorf("%d lock entries left after release", len(fileLocks.m))
+	}
+}
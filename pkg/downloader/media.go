@@ -1,100 +1,267 @@
 package downloader
 
 import (
+	"context"
 	"crypto/sha256"
 	"errors"
 	"fmt"
 	"io"
+	"mime"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/h2non/filetype"
+	"go-whisper-mcp/pkg"
 )
 
-// MediaDownloader 媒体下载器
+// sniffLen 内容嗅探读取的头部字节数
+const sniffLen = 8192
+
+// ErrMediaTooLarge 超过大小上限
+var ErrMediaTooLarge = errors.New("media exceeds max size")
+
+// ErrNotMedia 下载内容不是音频/视频
+var ErrNotMedia = errors.New("downloaded file is not audio or video")
+
+// MediaDownloaderOptions 下载器选项
+type MediaDownloaderOptions struct {
+	MaxBytes       int64         // 单文件上限，0 表示不限制
+	ConnectTimeout time.Duration // 建连 + 等待响应头超时
+	IdleTimeout    time.Duration // 传输中连续无数据的超时，0 表示不限制
+	TotalTimeout   time.Duration // 单个下载总超时，0 表示只受 ctx 控制
+}
+
+// MediaDownloader 媒体下载器：流式落盘、内容嗅探、断点续传、按 URL 去重
 type MediaDownloader struct {
 	savePath   string
 	httpClient *http.Client
+	opts       MediaDownloaderOptions
+	progress   pkg.ProgressReporter
 }
 
-// NewMediaDownloader 创建媒体下载器
+// NewMediaDownloader 创建媒体下载器（默认 30s 建连、60s 空闲超时，不限大小）
 func NewMediaDownloader(savePath string) *MediaDownloader {
+	return NewMediaDownloaderWithOptions(savePath, MediaDownloaderOptions{
+		ConnectTimeout: 30 * time.Second,
+		IdleTimeout:    60 * time.Second,
+	})
+}
+
+// NewMediaDownloaderWithOptions 按选项创建媒体下载器
+func NewMediaDownloaderWithOptions(savePath string, opts MediaDownloaderOptions) *MediaDownloader {
 	// 确保保存目录存在
 	if err := os.MkdirAll(savePath, 0755); err != nil {
 		panic(fmt.Sprintf("failed to create save path: %v", err))
 	}
 
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if opts.ConnectTimeout > 0 {
+		transport.DialContext = (&net.Dialer{Timeout: opts.ConnectTimeout, KeepAlive: 30 * time.Second}).DialContext
+		transport.ResponseHeaderTimeout = opts.ConnectTimeout
+		transport.TLSHandshakeTimeout = opts.ConnectTimeout
+	}
+
 	return &MediaDownloader{
 		savePath: savePath,
-		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+		// 不设置 Client.Timeout：大文件由空闲超时与总超时控制
+		httpClient: &http.Client{Transport: transport},
+		opts:       opts,
 	}
 }
 
+// SetProgress 设置下载进度接收者（nil 表示不上报）
+func (d *MediaDownloader) SetProgress(p pkg.ProgressReporter) {
+	d.progress = p
+}
+
 // DownloadMedia 下载媒体
-// 返回本地文件路径
-func (d *MediaDownloader) DownloadMedia(mediaURL string) (string, error) {
+// 返回本地文件路径；同一 URL 已下载过时直接复用缓存文件
+func (d *MediaDownloader) DownloadMedia(ctx context.Context, mediaURL string) (string, error) {
 	// 验证URL格式
 	if !d.isValidMediaURL(mediaURL) {
 		return "", errors.New("invalid media URL format")
 	}
 
-	// 下载媒体数据
-	resp, err := d.httpClient.Get(mediaURL)
+	// 同一 URL 的并发下载串行化（跨请求），后到的直接复用先完成的文件
+	base := filepath.Join(d.savePath, d.generateFileName(mediaURL))
+	unlock, err := lockFile(ctx, base)
 	if err != nil {
-		return "", errors.Join(err, errors.New("failed to download media"))
+		return "", err
+	}
+	defer unlock()
+
+	if cached, ok := findCompleted(base); ok {
+		return cached, nil
+	}
+
+	if d.opts.TotalTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.opts.TotalTimeout)
+		defer cancel()
+	}
+
+	// 416 时丢弃 .part 重来一次
+	var lastErr error
+	for attempt := 0; attempt < 2; attempt++ {
+		filePath, retry, err := d.download(ctx, mediaURL, base)
+		if err == nil {
+			return filePath, nil
+		}
+		lastErr = err
+		if !retry {
+			break
+		}
+	}
+	return "", lastErr
+}
+
+func (d *MediaDownloader) download(ctx context.Context, mediaURL, base string) (filePath string, retry bool, err error) {
+	partPath := base + ".part"
+	var offset int64
+	if fi, e := os.Stat(partPath); e == nil {
+		offset = fi.Size()
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("download failed with status: %d", resp.StatusCode)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, mediaURL, nil)
+	if err != nil {
+		return "", false, err
+	}
+	req.Header.Set("User-Agent", "go-whisper-mcp/media-downloader")
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
 	}
 
-	// 读取媒体数据
-	mediaData, err := io.ReadAll(resp.Body)
+	resp, err := d.httpClient.Do(req)
 	if err != nil {
-		return "", errors.Join(err, errors.New("failed to read media data"))
+		return "", false, fmt.Errorf("failed to download media: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		offset = 0 // 服务器不支持 Range，从头开始
+	case http.StatusPartialContent:
+		if start, ok := contentRangeStart(resp.Header.Get("Content-Range")); !ok || start != offset {
+			_ = os.Remove(partPath)
+			return "", true, fmt.Errorf("unexpected Content-Range %q", resp.Header.Get("Content-Range"))
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		_ = os.Remove(partPath)
+		return "", true, errors.New("range not satisfiable, restarting")
+	default:
+		return "", false, fmt.Errorf("download failed with status: %d", resp.StatusCode)
 	}
 
-	// 检测媒体格式
-	kind, err := filetype.Match(mediaData)
+	var total int64 = -1
+	if resp.ContentLength >= 0 {
+		total = offset + resp.ContentLength
+	}
+	if d.opts.MaxBytes > 0 && total > d.opts.MaxBytes {
+		return "", false, fmt.Errorf("%w: %d > %d bytes", ErrMediaTooLarge, total, d.opts.MaxBytes)
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY
+	if offset > 0 {
+		flags |= os.O_APPEND
+	} else {
+		flags |= os.O_TRUNC
+	}
+	f, err := os.OpenFile(partPath, flags, 0644)
 	if err != nil {
-		return "", errors.Join(err, errors.New("failed to detect file type"))
+		return "", false, errors.Join(err, errors.New("failed to save media"))
 	}
+	defer f.Close()
 
-	if !filetype.IsImage(mediaData) && !filetype.IsVideo(mediaData) {
-		return "", errors.New("downloaded file is not a valid media")
+	var body io.Reader = resp.Body
+	if d.opts.IdleTimeout > 0 {
+		body = newIdleReader(resp.Body, d.opts.IdleTimeout, cancel)
 	}
+	tracker := pkg.NewProgressTracker(d.progress, "media", mediaURL, total)
+	tracker.SetOffset(offset)
+	body = io.TeeReader(body, tracker)
 
-	// 生成唯一文件名
-	fileName := d.generateFileName(mediaURL, kind.Extension)
-	filePath := filepath.Join(d.savePath, fileName)
+	// 新下载：先读头部做嗅探，避免把网页等非媒体内容整个拉下来
+	var head []byte
+	if offset == 0 {
+		head = make([]byte, sniffLen)
+		n, rerr := io.ReadFull(body, head)
+		head = head[:n]
+		if rerr != nil && !errors.Is(rerr, io.EOF) && !errors.Is(rerr, io.ErrUnexpectedEOF) {
+			tracker.Finish(rerr)
+			return "", false, errors.Join(rerr, errors.New("failed to read media data"))
+		}
+		if _, err := sniffMedia(head, resp.Header.Get("Content-Type"), mediaURL); err != nil {
+			tracker.Finish(err)
+			_ = os.Remove(partPath)
+			return "", false, err
+		}
+		if _, err := f.Write(head); err != nil {
+			tracker.Finish(err)
+			return "", false, errors.Join(err, errors.New("failed to save media"))
+		}
+	}
 
-	// 如果文件已存在，直接返回路径
-	if _, err := os.Stat(filePath); err == nil {
-		return filePath, nil
+	limit := int64(-1)
+	if d.opts.MaxBytes > 0 {
+		limit = d.opts.MaxBytes - offset - int64(len(head))
+	}
+	if limit >= 0 {
+		body = io.LimitReader(body, limit+1)
+	}
+	n, err := io.Copy(f, body)
+	if err == nil && limit >= 0 && n > limit {
+		err = fmt.Errorf("%w: > %d bytes", ErrMediaTooLarge, d.opts.MaxBytes)
+		_ = f.Close()
+		_ = os.Remove(partPath)
+	}
+	if err != nil && ctx.Err() != nil && !errors.Is(err, ErrMediaTooLarge) {
+		err = fmt.Errorf("download interrupted: %w", err)
+	}
+	tracker.Finish(err)
+	if err != nil {
+		// 保留 .part 以便下次续传
+		return "", false, err
+	}
+	if err := f.Close(); err != nil {
+		return "", false, err
 	}
 
-	// 保存到文件
-	if err := os.WriteFile(filePath, mediaData, 0644); err != nil {
-		return "", errors.Join(err, errors.New("failed to save media"))
+	// 续传场景：从已落盘的文件头嗅探
+	if head == nil {
+		if head, err = readHead(partPath); err != nil {
+			return "", false, err
+		}
+	}
+	ext, err := sniffMedia(head, resp.Header.Get("Content-Type"), mediaURL)
+	if err != nil {
+		_ = os.Remove(partPath)
+		return "", false, err
 	}
 
-	return filePath, nil
+	filePath = base + "." + ext
+	if err := os.Rename(partPath, filePath); err != nil {
+		return "", false, errors.Join(err, errors.New("failed to save media"))
+	}
+	return filePath, false, nil
 }
 
 // DownloadMedias 批量下载媒体
-func (d *MediaDownloader) DownloadMedias(mediaURLs []string) ([]string, error) {
+func (d *MediaDownloader) DownloadMedias(ctx context.Context, mediaURLs []string) ([]string, error) {
 	var localPaths []string
 	var errs []error
 
 	for _, mediaURL := range mediaURLs {
-		localPath, err := d.DownloadMedia(mediaURL)
+		localPath, err := d.DownloadMedia(ctx, mediaURL)
 		if err != nil {
 			errs = append(errs, fmt.Errorf("failed to download %s: %w", mediaURL, err))
 			continue
@@ -126,19 +293,106 @@ func (d *MediaDownloader) isValidMediaURL(rawURL string) bool {
 	return parsedURL.Scheme != "" && parsedURL.Host != ""
 }
 
-// generateFileName 生成唯一的文件名
-func (d *MediaDownloader) generateFileName(mediaURL, extension string) string {
-	// 使用URL的SHA256哈希作为文件名，确保唯一性
+// generateFileName 按 URL 生成稳定的文件名前缀（不含扩展名），相同 URL 命中同一缓存
+func (d *MediaDownloader) generateFileName(mediaURL string) string {
 	hash := sha256.Sum256([]byte(mediaURL))
-	hashStr := fmt.Sprintf("%x", hash)
+	return fmt.Sprintf("media_%x", hash[:8])
+}
+
+// findCompleted 查找形如 base.<ext> 的已完成文件：扩展名只能是单段字母数字，
+// 排除 .part / .ytdl 临时文件、sidecar（.json）及其写入中的 .json.tmp、yt-dlp 的 .info.json 与分片
+func findCompleted(base string) (string, bool) {
+	matches, _ := filepath.Glob(base + ".*")
+	prefix := filepath.Base(base) + "."
+	for _, m := range matches {
+		ext := strings.ToLower(strings.TrimPrefix(filepath.Base(m), prefix))
+		if ext == "" || strings.ContainsFunc(ext, func(r rune) bool { return !('a' <= r && r <= 'z' || '0' <= r && r <= '9') }) {
+			continue
+		}
+		switch ext {
+		case "part", "json", "ytdl", "tmp":
+			continue
+		}
+		if fi, err := os.Stat(m); err == nil && fi.Mode().IsRegular() && fi.Size() > 0 {
+			return m, true
+		}
+	}
+	return "", false
+}
+
+// sniffMedia 通过文件头判断音频/视频，返回扩展名；识别不出时退回 Content-Type
+func sniffMedia(head []byte, contentType, rawURL string) (string, error) {
+	if kind, _ := filetype.Audio(head); kind != filetype.Unknown {
+		return kind.Extension, nil
+	}
+	if kind, _ := filetype.Video(head); kind != filetype.Unknown {
+		return kind.Extension, nil
+	}
 
-	// 取前16位哈希值作为文件名
-	shortHash := hashStr[:16]
+	mt, _, _ := mime.ParseMediaType(contentType)
+	if strings.HasPrefix(mt, "audio/") || strings.HasPrefix(mt, "video/") {
+		if u, err := url.Parse(rawURL); err == nil {
+			if ext := strings.TrimPrefix(path.Ext(u.Path), "."); ext != "" && len(ext) <= 5 {
+				return strings.ToLower(ext), nil
+			}
+		}
+		if exts, _ := mime.ExtensionsByType(mt); len(exts) > 0 {
+			return strings.TrimPrefix(exts[0], "."), nil
+		}
+		return "media", nil
+	}
+
+	if kind, _ := filetype.Match(head); kind != filetype.Unknown {
+		return "", fmt.Errorf("%w (detected %s)", ErrNotMedia, kind.MIME.Value)
+	}
+	return "", ErrNotMedia
+}
+
+func readHead(p string) ([]byte, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, err
+	}
+	return head[:n], nil
+}
+
+// contentRangeStart 解析 "bytes start-end/total" 的 start
+func contentRangeStart(v string) (int64, bool) {
+	v = strings.TrimSpace(strings.TrimPrefix(v, "bytes"))
+	dash := strings.IndexByte(v, '-')
+	if dash <= 0 {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(v[:dash]), 10, 64)
+	return n, err == nil
+}
+
+// idleReader 连续 timeout 内没有读到数据时取消下载
+type idleReader struct {
+	r       io.Reader
+	timer   *time.Timer
+	timeout time.Duration
+}
 
-	// 添加时间戳确保更好的唯一性
-	timestamp := time.Now().Unix()
+func newIdleReader(r io.Reader, timeout time.Duration, cancel context.CancelFunc) *idleReader {
+	return &idleReader{r: r, timeout: timeout, timer: time.AfterFunc(timeout, cancel)}
+}
 
-	return fmt.Sprintf("img_%s_%d.%s", shortHash, timestamp, extension)
+func (r *idleReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		r.timer.Reset(r.timeout)
+	}
+	if err != nil {
+		r.timer.Stop()
+	}
+	return n, err
 }
 
 // IsMediaURL 判断字符串是否为媒体URL
@@ -0,0 +1,51 @@
+package downloader
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFindCompleted(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []string
+		want  string
+	}{
+		{"media file", []string{"media_ab.mp3"}, "media_ab.mp3"},
+		{"sidecar being written", []string{"media_ab.json.tmp", "media_ab.json"}, ""},
+		{"partial download", []string{"media_ab.part", "media_ab.mp4.part"}, ""},
+		{"yt-dlp leftovers", []string{"media_ab.info.json", "media_ab.webm.ytdl", "media_ab.f140.m4a.part-Frag3"}, ""},
+		{"skips temp, finds media", []string{"media_ab.json.tmp", "media_ab.webm"}, "media_ab.webm"},
+		{"other prefix", []string{"media_abc.mp3"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, f := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, f), []byte("data"), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, ok := findCompleted(filepath.Join(dir, "media_ab"))
+			if tt.want == "" {
+				if ok {
+					t.Errorf("findCompleted = %q, want none", got)
+				}
+				return
+			}
+			if !ok || got != filepath.Join(dir, tt.want) {
+				t.Errorf("findCompleted = %q, %v; want %q", got, ok, tt.want)
+			}
+		})
+	}
+
+	// 空文件不算完成
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "media_ab.mp3"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := findCompleted(filepath.Join(dir, "media_ab")); ok {
+		t.Errorf("empty file returned: %q", got)
+	}
+}
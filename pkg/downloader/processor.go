@@ -1,50 +1,153 @@
 package downloader
 
 import (
+	"context"
+	"errors"
 	"fmt"
 
 	"go-whisper-mcp/configs"
+	"go-whisper-mcp/pkg"
+	"go-whisper-mcp/pkg/metrics"
+	"go-whisper-mcp/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
+// SourceType 输入来源类型
+type SourceType string
+
+const (
+	SourceLocal SourceType = "local" // 本地文件
+	SourceHTTP  SourceType = "http"  // http(s) 直链下载
+)
+
+// ResolvedInput 解析后的单个输入，与请求中的引用一一对应
+type ResolvedInput struct {
+	Ref       string     // 请求中的原始引用（路径或 URL）；目录/glob 展开时为具体文件
+	Origin    string     // 展开来源的目录或模式；直接列出的输入为空
+	LocalPath string     // 可供 ffmpeg 读取的本地路径；失败时为空
+	Source    SourceType // 来源类型
+	Meta      *MediaMeta // 来源附带的元数据，可能为 nil
+	Err       error      // 单项解析失败原因
+}
+
 // MediaProcessor 媒体处理器
 type MediaProcessor struct {
-	downloader *MediaDownloader
+	resolvers []SourceResolver // 按优先级排列，第一个 Match 的解析器生效
 }
 
 // NewMediaProcessor 创建媒体处理器
 func NewMediaProcessor() *MediaProcessor {
+	return NewMediaProcessorWithProgress(nil)
+}
+
+// NewMediaProcessorWithProgress 创建媒体处理器，下载进度上报给 prog（可为 nil）
+func NewMediaProcessorWithProgress(prog pkg.ProgressReporter) *MediaProcessor {
+	d := NewMediaDownloaderWithOptions(configs.GetMediaPath(), MediaDownloaderOptions{
+		MaxBytes:       configs.GetMediaMaxBytes(),
+		ConnectTimeout: configs.GetMediaConnectTimeout(),
+		IdleTimeout:    configs.GetMediaIdleTimeout(),
+		TotalTimeout:   configs.GetMediaTotalTimeout(),
+	})
+	d.SetProgress(prog)
+
+	ytdlp := NewYtDlpSource(configs.GetMediaPath(), YtDlpOptions{
+		Binary:  configs.GetYtDlpBinary(),
+		Hosts:   configs.GetYtDlpHosts(),
+		Timeout: configs.GetYtDlpTimeout(),
+	})
+	ytdlp.SetProgress(prog)
+
+	s3 := NewS3Source(configs.GetMediaPath(), configs.GetS3())
+	s3.SetProgress(prog)
+
 	return &MediaProcessor{
-		downloader: NewMediaDownloader(configs.GetMediaPath()),
+		resolvers: []SourceResolver{s3, ytdlp, &httpSource{downloader: d}, localSource{}},
 	}
 }
 
-// ProcessMedias 处理媒体列表，返回本地文件路径
-// 支持两种输入格式：
-// 1. URL格式 (http/https开头) - 自动下载到本地
-// 2. 本地文件路径 - 直接使用
-func (p *MediaProcessor) ProcessMedias(medias []string) ([]string, error) {
-	var localPaths []string
-	var urlsToDownload []string
+// AddResolver 注册自定义来源，优先于内置来源匹配
+func (p *MediaProcessor) AddResolver(r SourceResolver) {
+	p.resolvers = append([]SourceResolver{r}, p.resolvers...)
+}
+
+// ResolveInputs 按请求顺序解析输入，返回与 refs 等长的结果；单项失败记录在 Err 中，不影响其他项
+// 支持的输入格式（按优先级）：
+// 0. s3://bucket/key - 从 S3 兼容存储下载
+// 1. 视频平台页面（YouTube/Bilibili/抖音等）- 通过 yt-dlp 抽取音轨
+// 2. URL格式 (http/https开头) - 自动下载到本地
+// 3. 本地文件路径 - 校验存在后直接使用
+func (p *MediaProcessor) ResolveInputs(ctx context.Context, refs []string) []*ResolvedInput {
+	out := make([]*ResolvedInput, 0, len(refs))
+	for _, ref := range refs {
+		out = append(out, p.resolve(ctx, ref))
+	}
+	return out
+}
 
-	// 分离URL和本地路径
-	for _, image := range medias {
-		if IsMediaURL(image) {
-			urlsToDownload = append(urlsToDownload, image)
+// ResolveExpanded 解析 ExpandInputs 的结果，展开失败的项直接带上错误
+func (p *MediaProcessor) ResolveExpanded(ctx context.Context, refs []ExpandedRef) []*ResolvedInput {
+	out := make([]*ResolvedInput, 0, len(refs))
+	for _, r := range refs {
+		var in *ResolvedInput
+		if r.Err != nil {
+			in = &ResolvedInput{Ref: r.Ref, Source: SourceLocal, Err: r.Err}
 		} else {
-			// 本地路径直接添加
-			localPaths = append(localPaths, image)
+			in = p.resolve(ctx, r.Ref)
 		}
+		in.Origin = r.Origin
+		out = append(out, in)
 	}
+	return out
+}
 
-	// 批量下载URL媒体
-	if len(urlsToDownload) > 0 {
-		downloadedPaths, err := p.downloader.DownloadMedias(urlsToDownload)
+func (p *MediaProcessor) resolve(ctx context.Context, ref string) *ResolvedInput {
+	in := &ResolvedInput{Ref: ref}
+	if err := ctx.Err(); err != nil {
+		in.Err = err
+		return in
+	}
+
+	for _, r := range p.resolvers {
+		if !r.Match(ref) {
+			continue
+		}
+		in.Source = r.Type()
+		rctx, span := tracing.Start(ctx, "resolve_input",
+			attribute.String("whisper.input", ref), attribute.String("whisper.source", string(in.Source)))
+		res, err := r.Resolve(rctx, ref)
+		tracing.End(span, err)
+		metrics.InputResolutions.WithLabelValues(string(in.Source), metrics.Status(err == nil)).Inc()
 		if err != nil {
-			return nil, fmt.Errorf("failed to download medias: %w", err)
+			in.Err = err
+			return in
 		}
-		localPaths = append(localPaths, downloadedPaths...)
+		in.LocalPath = res.LocalPath
+		in.Meta = res.Meta
+		return in
 	}
+	in.Err = fmt.Errorf("no source can handle %s", ref)
+	return in
+}
 
+// ProcessMedias 处理媒体列表，按输入顺序返回成功解析的本地文件路径
+// 任一项失败时返回错误（已成功的路径仍然返回）
+func (p *MediaProcessor) ProcessMedias(ctx context.Context, medias []string) (_ []string, err error) {
+	ctx, span := tracing.Start(ctx, "process_medias", attribute.Int("whisper.inputs", len(medias)))
+	defer func() { tracing.End(span, err) }()
+
+	var localPaths []string
+	var errs []error
+	for _, in := range p.ResolveInputs(ctx, medias) {
+		if in.Err != nil {
+			errs = append(errs, in.Err)
+			continue
+		}
+		localPaths = append(localPaths, in.LocalPath)
+	}
+
+	if len(errs) > 0 {
+		return localPaths, fmt.Errorf("failed to resolve medias: %w", errors.Join(errs...))
+	}
 	if len(localPaths) == 0 {
 		return nil, fmt.Errorf("no valid medias found")
 	}
@@ -0,0 +1,143 @@
+package downloader
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"go-whisper-mcp/configs"
+	"go-whisper-mcp/pkg"
+)
+
+// SourceS3 S3 兼容对象存储
+const SourceS3 SourceType = "s3"
+
+// NewS3Client 按配置创建 S3 兼容客户端（MinIO / AWS S3 等）
+func NewS3Client(cfg configs.S3) (*minio.Client, error) {
+	if cfg.Endpoint == "" {
+		return nil, errors.New("s3 endpoint not configured")
+	}
+	return minio.New(cfg.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, cfg.SessionToken),
+		Secure: cfg.UseSSL,
+		Region: cfg.Region,
+	})
+}
+
+// IsS3URI 判断是否为 s3://bucket/key
+func IsS3URI(ref string) bool {
+	return strings.HasPrefix(strings.ToLower(ref), "s3://")
+}
+
+// ParseS3URI 解析 s3://bucket/key，key 可以为空（表示桶根或前缀）
+func ParseS3URI(ref string) (bucket, key string, err error) {
+	if !IsS3URI(ref) {
+		return "", "", fmt.Errorf("not an s3 uri: %s", ref)
+	}
+	rest := ref[len("s3://"):]
+	bucket, key, _ = strings.Cut(rest, "/")
+	if bucket == "" {
+		return "", "", fmt.Errorf("s3 uri without bucket: %s", ref)
+	}
+	return bucket, key, nil
+}
+
+// S3Source 将 s3://bucket/key 下载到本地缓存目录
+type S3Source struct {
+	savePath string
+	cfg      configs.S3
+	progress pkg.ProgressReporter
+
+	once   sync.Once
+	client *minio.Client
+	err    error
+}
+
+// NewS3Source 创建 S3 来源（客户端在首次使用时创建）
+func NewS3Source(savePath string, cfg configs.S3) *S3Source {
+	return &S3Source{savePath: savePath, cfg: cfg}
+}
+
+// SetProgress 设置下载进度接收者
+func (s *S3Source) SetProgress(p pkg.ProgressReporter) {
+	s.progress = p
+}
+
+// Type 实现 SourceResolver
+func (s *S3Source) Type() SourceType { return SourceS3 }
+
+// Match 实现 SourceResolver
+func (s *S3Source) Match(ref string) bool { return IsS3URI(ref) }
+
+// Resolve 实现 SourceResolver：按 ETag 缓存，对象未变化时复用本地文件
+func (s *S3Source) Resolve(ctx context.Context, ref string) (*ResolvedInput, error) {
+	bucket, key, err := ParseS3URI(ref)
+	if err != nil {
+		return nil, err
+	}
+	if key == "" || strings.HasSuffix(key, "/") {
+		return nil, fmt.Errorf("s3 uri must point to an object: %s", ref)
+	}
+	s.once.Do(func() { s.client, s.err = NewS3Client(s.cfg) })
+	if s.err != nil {
+		return nil, fmt.Errorf("s3 client: %w", s.err)
+	}
+
+	info, err := s.client.StatObject(ctx, bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("stat %s: %w", ref, err)
+	}
+
+	hash := sha256.Sum256([]byte(ref + "\x00" + info.ETag))
+	ext := strings.ToLower(path.Ext(key))
+	if ext == "" || len(ext) > 6 {
+		ext = ".media"
+	}
+	localPath := filepath.Join(s.savePath, fmt.Sprintf("s3_%x%s", hash[:8], ext))
+	unlock, err := lockFile(ctx, localPath)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	if fi, err := os.Stat(localPath); err == nil && fi.Size() == info.Size {
+		return &ResolvedInput{LocalPath: localPath}, nil
+	}
+
+	if err := os.MkdirAll(s.savePath, 0755); err != nil {
+		return nil, err
+	}
+	obj, err := s.client.GetObject(ctx, bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("get %s: %w", ref, err)
+	}
+	defer obj.Close()
+
+	partPath := localPath + ".part"
+	f, err := os.Create(partPath)
+	if err != nil {
+		return nil, err
+	}
+	tracker := pkg.NewProgressTracker(s.progress, "media", ref, info.Size)
+	_, err = io.Copy(f, io.TeeReader(obj, tracker))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	tracker.Finish(err)
+	if err != nil {
+		_ = os.Remove(partPath)
+		return nil, fmt.Errorf("download %s: %w", ref, err)
+	}
+	if err := os.Rename(partPath, localPath); err != nil {
+		return nil, err
+	}
+	return &ResolvedInput{LocalPath: localPath}, nil
+}
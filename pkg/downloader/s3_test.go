@@ -0,0 +1,142 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/johannesboyne/gofakes3"
+	"github.com/johannesboyne/gofakes3/backend/s3mem"
+	"github.com/minio/minio-go/v7"
+	"go-whisper-mcp/configs"
+)
+
+func TestParseS3URI(t *testing.T) {
+	tests := []struct {
+		ref, bucket, key string
+		wantErr          bool
+	}{
+		{ref: "s3://media/in/a.mp4", bucket: "media", key: "in/a.mp4"},
+		{ref: "S3://media/a.mp4", bucket: "media", key: "a.mp4"},
+		{ref: "s3://media", bucket: "media"},
+		{ref: "s3://media/", bucket: "media"},
+		{ref: "s3://media/out/", bucket: "media", key: "out/"},
+		{ref: "s3:///a.mp4", wantErr: true},
+		{ref: "s3://", wantErr: true},
+		{ref: "https://media/a.mp4", wantErr: true},
+		{ref: "/data/a.mp4", wantErr: true},
+	}
+	for _, tt := range tests {
+		bucket, key, err := ParseS3URI(tt.ref)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseS3URI(%q) err = %v, wantErr %v", tt.ref, err, tt.wantErr)
+			continue
+		}
+		if bucket != tt.bucket || key != tt.key {
+			t.Errorf("ParseS3URI(%q) = %q, %q; want %q, %q", tt.ref, bucket, key, tt.bucket, tt.key)
+		}
+	}
+}
+
+// newFakeS3 启动进程内的 S3 兼容服务，返回连接配置与客户端
+func newFakeS3(t *testing.T, bucket string) (configs.S3, *minio.Client) {
+	t.Helper()
+	srv := httptest.NewServer(gofakes3.New(s3mem.New()).Server())
+	t.Cleanup(srv.Close)
+
+	cfg := configs.S3{
+		Endpoint:  strings.TrimPrefix(srv.URL, "http://"),
+		AccessKey: "test",
+		SecretKey: "test-secret",
+		Region:    "us-east-1",
+	}
+	client, err := NewS3Client(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.MakeBucket(context.Background(), bucket, minio.MakeBucketOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	return cfg, client
+}
+
+func putObject(t *testing.T, client *minio.Client, bucket, key string, data []byte) {
+	t.Helper()
+	_, err := client.PutObject(context.Background(), bucket, key, bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestS3SourceResolve(t *testing.T) {
+	cfg, client := newFakeS3(t, "media")
+	data := bytes.Repeat([]byte("audio"), 1000)
+	putObject(t, client, "media", "in/talk.mp3", data)
+
+	src := NewS3Source(t.TempDir(), cfg)
+	if !src.Match("s3://media/in/talk.mp3") || src.Match("https://example.com/a.mp3") {
+		t.Fatal("Match")
+	}
+
+	ctx := context.Background()
+	in, err := src.Resolve(ctx, "s3://media/in/talk.mp3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(in.LocalPath, ".mp3") {
+		t.Errorf("local path %q should keep the .mp3 extension", in.LocalPath)
+	}
+	got, err := os.ReadFile(in.LocalPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("downloaded %d bytes, want %d", len(got), len(data))
+	}
+
+	// 对象未变化：复用本地文件
+	again, err := src.Resolve(ctx, "s3://media/in/talk.mp3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.LocalPath != in.LocalPath {
+		t.Errorf("unchanged object resolved to %q, want cached %q", again.LocalPath, in.LocalPath)
+	}
+
+	// 对象内容变化（ETag 不同）：重新下载到新文件
+	changed := bytes.Repeat([]byte("other"), 10)
+	putObject(t, client, "media", "in/talk.mp3", changed)
+	fresh, err := src.Resolve(ctx, "s3://media/in/talk.mp3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fresh.LocalPath == in.LocalPath {
+		t.Error("changed object reused the stale local file")
+	}
+	if got, _ := os.ReadFile(fresh.LocalPath); !bytes.Equal(got, changed) {
+		t.Error("changed object content mismatch")
+	}
+}
+
+func TestS3SourceErrors(t *testing.T) {
+	cfg, _ := newFakeS3(t, "media")
+	src := NewS3Source(t.TempDir(), cfg)
+	ctx := context.Background()
+
+	for _, ref := range []string{"s3://media", "s3://media/dir/"} {
+		if _, err := src.Resolve(ctx, ref); err == nil || !strings.Contains(err.Error(), "must point to an object") {
+			t.Errorf("Resolve(%q) err = %v, want object error", ref, err)
+		}
+	}
+	if _, err := src.Resolve(ctx, "s3://media/missing.mp3"); err == nil {
+		t.Error("missing object should fail")
+	}
+
+	noEndpoint := NewS3Source(t.TempDir(), configs.S3{})
+	if _, err := noEndpoint.Resolve(ctx, "s3://media/a.mp3"); err == nil || !strings.Contains(err.Error(), "endpoint") {
+		t.Errorf("err = %v, want endpoint not configured", err)
+	}
+}
@@ -0,0 +1,61 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+// MediaMeta 来源附带的媒体元数据（如视频平台的标题/作者）
+type MediaMeta struct {
+	Title      string  `json:"title,omitempty"`
+	Uploader   string  `json:"uploader,omitempty"`
+	WebpageURL string  `json:"webpage_url,omitempty"`
+	DurationS  float64 `json:"duration_s,omitempty"`
+	Extractor  string  `json:"extractor,omitempty"`
+}
+
+// SourceResolver 输入来源解析器：把某类引用（本地路径、直链、视频平台页面……）解析为本地文件
+type SourceResolver interface {
+	// Type 来源类型
+	Type() SourceType
+	// Match 是否由该解析器处理
+	Match(ref string) bool
+	// Resolve 解析为本地文件，返回的 ResolvedInput 至少填充 LocalPath
+	Resolve(ctx context.Context, ref string) (*ResolvedInput, error)
+}
+
+// httpSource http(s) 直链
+type httpSource struct {
+	downloader *MediaDownloader
+}
+
+func (s *httpSource) Type() SourceType { return SourceHTTP }
+
+func (s *httpSource) Match(ref string) bool { return IsMediaURL(ref) }
+
+func (s *httpSource) Resolve(ctx context.Context, ref string) (*ResolvedInput, error) {
+	localPath, err := s.downloader.DownloadMedia(ctx, ref)
+	if err != nil {
+		return nil, fmt.Errorf("download %s: %w", ref, err)
+	}
+	return &ResolvedInput{LocalPath: localPath}, nil
+}
+
+// localSource 本地文件（兜底）
+type localSource struct{}
+
+func (localSource) Type() SourceType { return SourceLocal }
+
+func (localSource) Match(string) bool { return true }
+
+func (localSource) Resolve(_ context.Context, ref string) (*ResolvedInput, error) {
+	fi, err := os.Stat(ref)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s is not a regular file", ref)
+	}
+	return &ResolvedInput{LocalPath: ref}, nil
+}
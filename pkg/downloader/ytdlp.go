@@ -0,0 +1,208 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go-whisper-mcp/pkg"
+	"go-whisper-mcp/pkg/logging"
+)
+
+// SourceYtDlp 视频平台页面，通过 yt-dlp 抽取音频
+const SourceYtDlp SourceType = "yt-dlp"
+
+// DefaultYtDlpHosts 默认交给 yt-dlp 处理的站点（域名后缀匹配）
+var DefaultYtDlpHosts = []string{
+	"youtube.com", "youtu.be",
+	"bilibili.com", "b23.tv",
+	"douyin.com", "iesdouyin.com",
+	"tiktok.com",
+}
+
+// YtDlpOptions yt-dlp 来源选项
+type YtDlpOptions struct {
+	Binary  string        // 可执行文件，默认 "yt-dlp"（每次调用时从 PATH 查找）
+	Hosts   []string      // 匹配的站点域名后缀，默认 DefaultYtDlpHosts
+	Format  string        // 格式选择，默认 "bestaudio/best"
+	Timeout time.Duration // 单次抽取超时，0 表示只受 ctx 控制
+}
+
+// YtDlpSource 通过 yt-dlp 下载视频平台的最佳音轨
+type YtDlpSource struct {
+	savePath string
+	opts     YtDlpOptions
+	progress pkg.ProgressReporter
+}
+
+// NewYtDlpSource 创建 yt-dlp 来源，文件保存到 savePath
+func NewYtDlpSource(savePath string, opts YtDlpOptions) *YtDlpSource {
+	if opts.Binary == "" {
+		opts.Binary = "yt-dlp"
+	}
+	if len(opts.Hosts) == 0 {
+		opts.Hosts = DefaultYtDlpHosts
+	}
+	if opts.Format == "" {
+		opts.Format = "bestaudio/best"
+	}
+	return &YtDlpSource{savePath: savePath, opts: opts}
+}
+
+// SetProgress 设置进度接收者（仅上报开始/结束）
+func (s *YtDlpSource) SetProgress(p pkg.ProgressReporter) {
+	s.progress = p
+}
+
+// Type 实现 SourceResolver
+func (s *YtDlpSource) Type() SourceType { return SourceYtDlp }
+
+// Match 实现 SourceResolver：http(s) 且域名命中站点列表
+func (s *YtDlpSource) Match(ref string) bool {
+	if !IsMediaURL(ref) {
+		return false
+	}
+	u, err := url.Parse(ref)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, h := range s.opts.Hosts {
+		h = strings.ToLower(strings.TrimSpace(h))
+		if h != "" && (host == h || strings.HasSuffix(host, "."+h)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve 实现 SourceResolver：调用 yt-dlp 抽取最佳音轨并读取 info.json 元数据
+func (s *YtDlpSource) Resolve(ctx context.Context, ref string) (*ResolvedInput, error) {
+	bin, err := exec.LookPath(s.opts.Binary)
+	if err != nil {
+		return nil, fmt.Errorf("yt-dlp not found in PATH: %w", err)
+	}
+	if err := os.MkdirAll(s.savePath, 0755); err != nil {
+		return nil, err
+	}
+
+	hash := sha256.Sum256([]byte(ref))
+	base := filepath.Join(s.savePath, fmt.Sprintf("ytdlp_%x", hash[:8]))
+	infoPath := base + ".info.json"
+
+	// 同一链接的并发请求串行化，后到的直接复用先完成的结果
+	unlock, err := lockFile(ctx, base)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	// 已抽取过：直接复用
+	if localPath, ok := findCompleted(base); ok && !isYtDlpTemp(localPath) {
+		if meta, err := readYtDlpInfo(infoPath); err == nil {
+			return &ResolvedInput{LocalPath: localPath, Meta: meta}, nil
+		}
+	}
+
+	if s.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.opts.Timeout)
+		defer cancel()
+	}
+
+	args := []string{
+		"--no-playlist", "--no-progress", "--no-warnings",
+		"-f", s.opts.Format,
+		"--write-info-json",
+		"-o", base + ".%(ext)s",
+		"--print", "after_move:filepath",
+		ref,
+	}
+	tracker := pkg.NewProgressTracker(s.progress, "media", ref, -1)
+	var stdout, stderr bytes.Buffer
+	log := logging.FromContext(ctx).WithField("ref", ref)
+	log.Debug("yt-dlp extract")
+	cmd := exec.CommandContext(ctx, bin, args...)
+	pkg.InterruptOnCancel(cmd)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		err = fmt.Errorf("yt-dlp: %w: %s", err, strings.TrimSpace(stderr.String()))
+		tracker.Finish(err)
+		log.WithError(err).Warn("yt-dlp extract failed")
+		return nil, err
+	}
+
+	localPath := lastLine(stdout.String())
+	if localPath == "" {
+		localPath, _ = findCompleted(base)
+	}
+	if localPath == "" {
+		err := errors.New("yt-dlp: no output file reported")
+		tracker.Finish(err)
+		return nil, err
+	}
+	if fi, err := os.Stat(localPath); err == nil {
+		tracker.Add(fi.Size())
+	}
+	tracker.Finish(nil)
+
+	meta, err := readYtDlpInfo(infoPath)
+	if err != nil {
+		// 元数据缺失不影响转录
+		meta = &MediaMeta{WebpageURL: ref}
+	}
+	return &ResolvedInput{LocalPath: localPath, Meta: meta}, nil
+}
+
+// readYtDlpInfo 读取 yt-dlp 写出的 info.json
+func readYtDlpInfo(p string) (*MediaMeta, error) {
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	var info struct {
+		Title      string  `json:"title"`
+		Uploader   string  `json:"uploader"`
+		Channel    string  `json:"channel"`
+		WebpageURL string  `json:"webpage_url"`
+		Duration   float64 `json:"duration"`
+		Extractor  string  `json:"extractor_key"`
+	}
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", p, err)
+	}
+	if info.Uploader == "" {
+		info.Uploader = info.Channel
+	}
+	return &MediaMeta{
+		Title:      info.Title,
+		Uploader:   info.Uploader,
+		WebpageURL: info.WebpageURL,
+		DurationS:  info.Duration,
+		Extractor:  info.Extractor,
+	}, nil
+}
+
+func isYtDlpTemp(p string) bool {
+	return strings.HasSuffix(p, ".ytdl") || strings.Contains(filepath.Base(p), ".part-Frag")
+}
+
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		if l := strings.TrimSpace(lines[i]); l != "" {
+			return l
+		}
+	}
+	return ""
+}
@@ -0,0 +1,195 @@
+package downloader
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stubYtDlp 记录参数，按 -o 模板写出音频与 info.json 并打印最终路径；
+// STUB_YTDLP_MODE=fail 时模拟抽取失败，=noprint 时不打印路径，=badinfo 时写出无法解析的 info.json
+const stubYtDlp = `#!/bin/sh
+printf '%s\n' "$@" > "$STUB_YTDLP_ARGS"
+echo run >> "$STUB_YTDLP_ARGS.calls"
+if [ "$STUB_YTDLP_MODE" = fail ]; then
+	echo "ERROR: [youtube] abc: Video unavailable" >&2
+	exit 1
+fi
+out=""
+prev=""
+for a in "$@"; do
+	if [ "$prev" = "-o" ]; then out="$a"; fi
+	prev="$a"
+done
+base="${out%.%(ext)s}"
+printf 'audio' > "$base.m4a"
+if [ "$STUB_YTDLP_MODE" = badinfo ]; then
+	echo '{' > "$base.info.json"
+else
+	echo '{"title":"Talk [abc]","channel":"Chan","webpage_url":"https://www.youtube.com/watch?v=abc","duration":12.5,"extractor_key":"Youtube"}' > "$base.info.json"
+fi
+if [ "$STUB_YTDLP_MODE" != noprint ]; then
+	echo "[info] done"
+	echo "$base.m4a"
+fi
+`
+
+// installStubYtDlp 把桩 yt-dlp 放到 PATH 最前面，返回记录参数的文件
+func installStubYtDlp(t *testing.T, mode string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("stub script requires a POSIX shell")
+	}
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "yt-dlp"), []byte(stubYtDlp), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	argsFile := filepath.Join(t.TempDir(), "args")
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("STUB_YTDLP_ARGS", argsFile)
+	t.Setenv("STUB_YTDLP_MODE", mode)
+	return argsFile
+}
+
+func stubCalls(argsFile string) int {
+	data, _ := os.ReadFile(argsFile + ".calls")
+	return strings.Count(string(data), "run")
+}
+
+func TestYtDlpSourceMatch(t *testing.T) {
+	src := NewYtDlpSource(t.TempDir(), YtDlpOptions{})
+	for ref, want := range map[string]bool{
+		"https://www.youtube.com/watch?v=abc": true,
+		"https://youtu.be/abc":                true,
+		"https://m.bilibili.com/video/BV1":    true,
+		"https://notyoutube.com/watch?v=abc":  false,
+		"https://example.com/a.mp3":           false,
+		"s3://youtube.com/a.mp3":              false,
+		"/data/youtube.com.mp4":               false,
+	} {
+		if got := src.Match(ref); got != want {
+			t.Errorf("Match(%q) = %v, want %v", ref, got, want)
+		}
+	}
+	custom := NewYtDlpSource(t.TempDir(), YtDlpOptions{Hosts: []string{" Vimeo.com "}})
+	if !custom.Match("https://player.vimeo.com/video/1") || custom.Match("https://youtube.com/watch?v=abc") {
+		t.Error("custom hosts not applied")
+	}
+}
+
+func TestYtDlpSourceResolve(t *testing.T) {
+	argsFile := installStubYtDlp(t, "")
+	dir := t.TempDir()
+	src := NewYtDlpSource(dir, YtDlpOptions{Format: "bestaudio[ext=m4a]"})
+	ref := "https://www.youtube.com/watch?v=abc"
+
+	in, err := src.Resolve(context.Background(), ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Dir(in.LocalPath) != dir || !strings.HasPrefix(filepath.Base(in.LocalPath), "ytdlp_") || filepath.Ext(in.LocalPath) != ".m4a" {
+		t.Errorf("LocalPath = %q", in.LocalPath)
+	}
+	want := &MediaMeta{
+		Title:      "Talk [abc]",
+		Uploader:   "Chan", // uploader 缺失时取 channel
+		WebpageURL: "https://www.youtube.com/watch?v=abc",
+		DurationS:  12.5,
+		Extractor:  "Youtube",
+	}
+	if !reflect.DeepEqual(in.Meta, want) {
+		t.Errorf("Meta = %+v, want %+v", in.Meta, want)
+	}
+
+	data, err := os.ReadFile(argsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	args := strings.Split(strings.TrimSpace(string(data)), "\n")
+	base := strings.TrimSuffix(in.LocalPath, ".m4a")
+	wantArgs := []string{
+		"--no-playlist", "--no-progress", "--no-warnings",
+		"-f", "bestaudio[ext=m4a]",
+		"--write-info-json",
+		"-o", base + ".%(ext)s",
+		"--print", "after_move:filepath",
+		ref,
+	}
+	if !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("args = %q\nwant %q", args, wantArgs)
+	}
+
+	// 再次解析同一链接：复用已抽取的文件，不再调用 yt-dlp
+	again, err := src.Resolve(context.Background(), ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.LocalPath != in.LocalPath || again.Meta.Title != want.Title {
+		t.Errorf("second resolve = %+v", again)
+	}
+	if n := stubCalls(argsFile); n != 1 {
+		t.Errorf("yt-dlp invoked %d times, want 1", n)
+	}
+}
+
+func TestYtDlpSourceErrors(t *testing.T) {
+	ref := "https://youtu.be/abc"
+
+	t.Run("exit status", func(t *testing.T) {
+		installStubYtDlp(t, "fail")
+		_, err := NewYtDlpSource(t.TempDir(), YtDlpOptions{}).Resolve(context.Background(), ref)
+		if err == nil || !strings.Contains(err.Error(), "Video unavailable") {
+			t.Errorf("err = %v, want stderr in error", err)
+		}
+	})
+
+	t.Run("not in PATH", func(t *testing.T) {
+		_, err := NewYtDlpSource(t.TempDir(), YtDlpOptions{Binary: "yt-dlp-missing-for-test"}).Resolve(context.Background(), ref)
+		if err == nil || !strings.Contains(err.Error(), "not found") {
+			t.Errorf("err = %v, want not found", err)
+		}
+	})
+
+	t.Run("path not printed", func(t *testing.T) {
+		installStubYtDlp(t, "noprint")
+		in, err := NewYtDlpSource(t.TempDir(), YtDlpOptions{}).Resolve(context.Background(), ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if filepath.Ext(in.LocalPath) != ".m4a" {
+			t.Errorf("LocalPath = %q, want file found by prefix", in.LocalPath)
+		}
+	})
+
+	t.Run("bad info.json", func(t *testing.T) {
+		installStubYtDlp(t, "badinfo")
+		in, err := NewYtDlpSource(t.TempDir(), YtDlpOptions{}).Resolve(context.Background(), ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if in.Meta == nil || in.Meta.WebpageURL != ref || in.Meta.Title != "" {
+			t.Errorf("Meta = %+v, want fallback with only the webpage URL", in.Meta)
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		bin := t.TempDir()
+		if err := os.WriteFile(filepath.Join(bin, "yt-dlp"), []byte("#!/bin/sh\nexec sleep 10\n"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+		start := time.Now()
+		_, err := NewYtDlpSource(t.TempDir(), YtDlpOptions{Timeout: 200 * time.Millisecond}).Resolve(context.Background(), ref)
+		if err == nil {
+			t.Fatal("expected timeout error")
+		}
+		if d := time.Since(start); d > 5*time.Second {
+			t.Errorf("timeout not enforced: took %v", d)
+		}
+	})
+}
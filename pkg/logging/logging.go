@@ -0,0 +1,120 @@
+// Package logging 日志格式、级别与请求 ID：按请求携带 request_id / trace_id 字段，便于在 JSON 日志中串联一次调用
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// 日志格式（LOG_FORMAT）
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// HeaderRequestID 请求 ID 的 HTTP 头
+const HeaderRequestID = "X-Request-ID"
+
+// maxRequestIDLen 接受调用方传入的请求 ID 的最大长度
+const maxRequestIDLen = 128
+
+// Setup 设置全局日志格式（text | json）与级别（trace、debug、info、warn、error）
+func Setup(format, level string) error {
+	switch format {
+	case "", FormatText:
+		logrus.SetFormatter(&logrus.TextFormatter{FullTimestamp: true})
+	case FormatJSON:
+		logrus.SetFormatter(&logrus.JSONFormatter{TimestampFormat: time.RFC3339Nano})
+	default:
+		return fmt.Errorf("unsupported log format %q (text, json)", format)
+	}
+	return SetLevel(level)
+}
+
+// SetLevel 运行时调整日志级别，空串视为 info
+func SetLevel(level string) error {
+	if level == "" {
+		level = "info"
+	}
+	lv, err := logrus.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	logrus.SetLevel(lv)
+	return nil
+}
+
+// Level 当前日志级别
+func Level() string {
+	return logrus.GetLevel().String()
+}
+
+// ValidLevel level 是否为可识别的日志级别（空串视为 info）
+func ValidLevel(level string) bool {
+	if level == "" {
+		return true
+	}
+	_, err := logrus.ParseLevel(level)
+	return err == nil
+}
+
+type requestIDKey struct{}
+
+// WithRequestID 在 ctx 上附加请求 ID
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID 取出 ctx 上的请求 ID，没有时返回空串
+func RequestID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// NewRequestID 生成随机请求 ID（32 位十六进制）
+func NewRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// ValidRequestID 调用方传入的请求 ID 是否可用：1~128 个可见 ASCII 字符，不含空格
+func ValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// FromContext 带 request_id 与 trace_id（存在时）字段的日志入口
+func FromContext(ctx context.Context) *logrus.Entry {
+	entry := logrus.NewEntry(logrus.StandardLogger())
+	if ctx == nil {
+		return entry
+	}
+	fields := logrus.Fields{}
+	if id := RequestID(ctx); id != "" {
+		fields["request_id"] = id
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		fields["trace_id"] = sc.TraceID().String()
+	}
+	if len(fields) == 0 {
+		return entry
+	}
+	return entry.WithFields(fields)
+}
@@ -0,0 +1,135 @@
+// Package metrics Prometheus 指标（默认注册表，经 /metrics 暴露）
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "whisper"
+
+var (
+	// HTTPRequests HTTP 请求数（route 为注册的路由模板，未匹配时为 unmatched）
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	// HTTPDuration HTTP 请求耗时
+	HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method and route.",
+		Buckets:   []float64{0.01, 0.05, 0.1, 0.5, 1, 5, 15, 30, 60, 120, 300, 600, 1800},
+	}, []string{"method", "route"})
+
+	// MCPToolCalls MCP 工具调用数（status 为 success / failed）
+	MCPToolCalls = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "mcp_tool_calls_total",
+		Help:      "MCP tool calls by tool and outcome.",
+	}, []string{"tool", "status"})
+
+	// MCPToolDuration MCP 工具调用耗时
+	MCPToolDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "mcp_tool_duration_seconds",
+		Help:      "MCP tool call latency by tool.",
+		Buckets:   []float64{0.1, 0.5, 1, 5, 15, 30, 60, 120, 300, 600, 1800},
+	}, []string{"tool"})
+
+	// Transcriptions 单个文件的转录结果数（status 为 success / failed）
+	Transcriptions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "transcriptions_total",
+		Help:      "Transcribed files by outcome.",
+	}, []string{"status"})
+
+	// AudioSeconds 已推理的音频时长
+	AudioSeconds = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "audio_seconds_total",
+		Help:      "Seconds of audio run through inference, by model.",
+	}, []string{"model"})
+
+	// RealTimeFactor 推理耗时 / 音频时长（越小越快）
+	RealTimeFactor = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "real_time_factor",
+		Help:      "Inference wall time divided by audio duration, by model.",
+		Buckets:   []float64{0.01, 0.02, 0.05, 0.1, 0.2, 0.3, 0.5, 0.75, 1, 1.5, 2, 5},
+	}, []string{"model"})
+
+	// ModelLoadDuration 模型加载耗时（每次推理都会加载模型）
+	ModelLoadDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "model_load_duration_seconds",
+		Help:      "Time spent loading the model into memory, by model.",
+		Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2, 5, 10, 30},
+	}, []string{"model"})
+
+	// ModelDownloads 模型下载次数（status 为 success / failed）
+	ModelDownloads = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "model_downloads_total",
+		Help:      "Model downloads by outcome.",
+	}, []string{"status"})
+
+	// QueueDepth 已接收、尚未完成转录的文件数（含正在推理的）
+	QueueDepth = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "queue_depth",
+		Help:      "Files accepted for transcription that have not finished yet.",
+	})
+
+	// CacheLookups 转录缓存查询（result 为 hit / miss），命中率 = hit / (hit + miss)
+	CacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_lookups_total",
+		Help:      "Transcript cache lookups by result.",
+	}, []string{"result"})
+
+	// FFmpegDecodeFailures ffmpeg 解码失败次数（不含取消）
+	FFmpegDecodeFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ffmpeg_decode_failures_total",
+		Help:      "ffmpeg decode failures, excluding cancellations.",
+	})
+
+	// DownloadBytes 下载字节数（kind 为 model / media）
+	DownloadBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "download_bytes_total",
+		Help:      "Bytes downloaded, by kind (model or media).",
+	}, []string{"kind"})
+
+	// ConfigReloads 配置重新加载次数（status 为 success / failed）
+	ConfigReloads = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "config_reloads_total",
+		Help:      "Config and model registry reloads by outcome.",
+	}, []string{"status"})
+
+	// ConfigLastReloadSuccess 最近一次成功加载配置的时间（Unix 秒），含启动时的加载
+	ConfigLastReloadSuccess = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "config_last_reload_success_timestamp_seconds",
+		Help:      "Unix time of the last successful config load.",
+	})
+
+	// InputResolutions 输入解析结果（source 为 local / http / yt-dlp / s3，status 为 success / failed）
+	InputResolutions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "input_resolutions_total",
+		Help:      "Input references resolved to local files, by source and outcome.",
+	}, []string{"source", "status"})
+)
+
+// Status 成功 / 失败标签值
+func Status(ok bool) string {
+	if ok {
+		return "success"
+	}
+	return "failed"
+}
@@ -0,0 +1,241 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ggml 模型文件头魔数（小端 0x67676d6c）与 gguf 魔数
+var (
+	ggmlMagic = []byte{0x6c, 0x6d, 0x67, 0x67}
+	ggufMagic = []byte("GGUF")
+)
+
+// ErrChecksumMismatch 模型校验和不一致
+var ErrChecksumMismatch = errors.New("model checksum mismatch")
+
+// ErrModelExists 模型文件或注册表名称已存在（导入时未指定 overwrite）
+var ErrModelExists = errors.New("model already exists")
+
+// ErrImportNotAllowed 导入来源不在允许的目录内
+var ErrImportNotAllowed = errors.New("model import source not allowed")
+
+// ResolveImportSource 把 HTTP 导入请求的 src 限制在 importDir 内（解析符号链接后比较）；importDir 为空时不允许导入
+func ResolveImportSource(importDir, src string) (string, error) {
+	if importDir == "" {
+		return "", fmt.Errorf("%w: import over HTTP is disabled (set models.import_dir / MODELS_IMPORT_DIR)", ErrImportNotAllowed)
+	}
+	root, err := filepath.EvalSymlinks(importDir)
+	if err != nil {
+		return "", fmt.Errorf("import dir: %w", err)
+	}
+	if root, err = filepath.Abs(root); err != nil {
+		return "", err
+	}
+	p := src
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(root, p)
+	}
+	if p, err = filepath.EvalSymlinks(p); err != nil {
+		return "", err
+	}
+	if p, err = filepath.Abs(p); err != nil {
+		return "", err
+	}
+	if rel, err := filepath.Rel(root, p); err != nil || !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("%w: %s is outside %s", ErrImportNotAllowed, src, importDir)
+	}
+	return p, nil
+}
+
+// CheckModelHeader 校验文件头是否为 ggml/gguf 模型，返回格式名
+func CheckModelHeader(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	head := make([]byte, 4)
+	if _, err := io.ReadFull(f, head); err != nil {
+		return "", fmt.Errorf("read header %s: %w", path, err)
+	}
+	switch {
+	case bytes.Equal(head, ggmlMagic):
+		return "ggml", nil
+	case bytes.Equal(head, ggufMagic):
+		return "gguf", nil
+	}
+	return "", fmt.Errorf("%s is not a ggml/gguf model (header %x)", path, head)
+}
+
+// ImportModel 将本地 ggml/gguf 文件拷贝进 modelsDir 并登记到全局注册表
+// entry.Name 为空时使用文件名（去扩展名）；entry.Path 为空时沿用源文件名。
+// 目标文件已存在、名称已登记或与内置别名（及其文件名）冲突时返回 ErrModelExists，overwrite 为 true 时替换
+func ImportModel(ctx context.Context, modelsDir, src string, entry ModelEntry, overwrite bool) (*ModelEntry, error) {
+	if modelsDir == "" {
+		modelsDir = "./models"
+	}
+	reg := DefaultModelRegistry()
+	if reg == nil {
+		return nil, errors.New("model registry not configured")
+	}
+	if _, err := CheckModelHeader(src); err != nil {
+		return nil, err
+	}
+
+	filename := filepath.Base(entry.Path)
+	if entry.Path == "" {
+		filename = filepath.Base(src)
+	}
+	low := strings.ToLower(filename)
+	if !strings.HasSuffix(low, ".bin") && !strings.HasSuffix(low, ".gguf") {
+		filename += ".bin"
+	}
+	if entry.Name == "" {
+		entry.Name = strings.TrimSuffix(filename, filepath.Ext(filename))
+	}
+	entry.Path = filename
+
+	dst := filepath.Join(modelsDir, filename)
+	if !overwrite {
+		if isBuiltinModel(entry.Name) || isBuiltinModel(filename) {
+			return nil, fmt.Errorf("%w: %s / %s conflicts with a built-in model", ErrModelExists, entry.Name, filename)
+		}
+		if _, ok := reg.Lookup(entry.Name); ok {
+			return nil, fmt.Errorf("%w: name %q already registered", ErrModelExists, entry.Name)
+		}
+		if _, err := os.Lstat(dst); err == nil {
+			return nil, fmt.Errorf("%w: %s", ErrModelExists, dst)
+		}
+	}
+
+	if err := os.MkdirAll(modelsDir, 0o755); err != nil {
+		return nil, fmt.Errorf("mkdir %s: %w", modelsDir, err)
+	}
+	// 每次导入使用独立的临时文件：并发导入同一文件时不会互相覆盖
+	out, err := os.CreateTemp(modelsDir, filename+".*.part")
+	if err != nil {
+		return nil, err
+	}
+	tmp := out.Name()
+	defer os.Remove(tmp)
+	sum, err := copyWithSHA256(ctx, src, out)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, err
+	}
+	if entry.SHA256 != "" && !strings.EqualFold(entry.SHA256, sum) {
+		return nil, fmt.Errorf("%w: want %s, got %s", ErrChecksumMismatch, entry.SHA256, sum)
+	}
+	if err := os.Chmod(tmp, 0o644); err != nil {
+		return nil, err
+	}
+	entry.SHA256 = sum
+	e := entry
+	if overwrite {
+		if err := os.Rename(tmp, dst); err != nil {
+			return nil, fmt.Errorf("rename: %w", err)
+		}
+		reg.Put(&e)
+	} else {
+		// 硬链接不会替换已存在的文件：与上面的检查之间被并发导入占用时同样报错
+		err := os.Link(tmp, dst)
+		if err != nil && !errors.Is(err, os.ErrExist) {
+			// 不支持硬链接的文件系统：退回检查后重命名
+			if _, serr := os.Lstat(dst); serr == nil {
+				err = os.ErrExist
+			} else {
+				err = os.Rename(tmp, dst)
+			}
+		}
+		if errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("%w: %s", ErrModelExists, dst)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("link: %w", err)
+		}
+		if err := reg.Add(&e); err != nil {
+			_ = os.Remove(dst)
+			return nil, err
+		}
+	}
+	if err := reg.Save(); err != nil {
+		return nil, fmt.Errorf("save registry: %w", err)
+	}
+	return &e, nil
+}
+
+// VerifyModel 校验已安装模型：文件头 + 注册表中的校验和（若有）
+func VerifyModel(modelsDir, spec string) (localPath string, err error) {
+	localPath = ModelLocalPath(modelsDir, spec)
+	entry, registered := LookupModel(spec)
+	if _, err := CheckModelHeader(localPath); err != nil {
+		return localPath, err
+	}
+	if registered && entry.SHA256 != "" {
+		if err := verifyFileSHA256(localPath, entry.SHA256); err != nil {
+			return localPath, err
+		}
+	}
+	return localPath, nil
+}
+
+func verifyFileSHA256(path, want string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	got := hex.EncodeToString(h.Sum(nil))
+	if !strings.EqualFold(got, want) {
+		return fmt.Errorf("%w: %s want %s, got %s", ErrChecksumMismatch, filepath.Base(path), want, got)
+	}
+	return nil
+}
+
+func copyWithSHA256(ctx context.Context, src string, out *os.File) (string, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+
+	h := sha256.New()
+	buf := make([]byte, 1<<20)
+	for {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		n, rerr := in.Read(buf)
+		if n > 0 {
+			if _, err := out.Write(buf[:n]); err != nil {
+				return "", fmt.Errorf("write: %w", err)
+			}
+			h.Write(buf[:n])
+		}
+		if errors.Is(rerr, io.EOF) {
+			break
+		}
+		if rerr != nil {
+			return "", rerr
+		}
+	}
+	if err := out.Sync(); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,177 @@
+package pkg
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// writeModel 写一个带 ggml 文件头的假模型
+func writeModel(t *testing.T, path, body string) {
+	t.Helper()
+	if err := os.WriteFile(path, append(append([]byte{}, ggmlMagic...), body...), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func withRegistry(t *testing.T) *ModelRegistry {
+	t.Helper()
+	reg, err := LoadModelRegistry(filepath.Join(t.TempDir(), "registry.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev := DefaultModelRegistry()
+	SetModelRegistry(reg)
+	t.Cleanup(func() { SetModelRegistry(prev) })
+	return reg
+}
+
+func TestImportModelRefusesToReplace(t *testing.T) {
+	reg := withRegistry(t)
+	ctx := context.Background()
+	modelsDir, srcDir := t.TempDir(), t.TempDir()
+	src := filepath.Join(srcDir, "ft.bin")
+	writeModel(t, src, "v1")
+
+	if _, err := ImportModel(ctx, modelsDir, src, ModelEntry{Name: "ft-zh"}, false); err != nil {
+		t.Fatal(err)
+	}
+
+	writeModel(t, src, "v2")
+	for _, tt := range []struct {
+		name  string
+		entry ModelEntry
+	}{
+		{"same name", ModelEntry{Name: "FT-ZH", Path: "other.bin"}},
+		{"same file", ModelEntry{Name: "another", Path: "ft.bin"}},
+		{"built-in alias", ModelEntry{Name: "medium", Path: "custom.bin"}},
+		{"built-in file", ModelEntry{Name: "custom", Path: "ggml-medium.bin"}},
+	} {
+		if _, err := ImportModel(ctx, modelsDir, src, tt.entry, false); !errors.Is(err, ErrModelExists) {
+			t.Errorf("%s: err = %v, want ErrModelExists", tt.name, err)
+		}
+	}
+	if data, _ := os.ReadFile(filepath.Join(modelsDir, "ft.bin")); !strings.HasSuffix(string(data), "v1") {
+		t.Error("existing model file was replaced")
+	}
+	if _, err := os.Stat(filepath.Join(modelsDir, "ggml-medium.bin")); !os.IsNotExist(err) {
+		t.Error("built-in model file was created")
+	}
+	if e, _ := reg.Lookup("ft-zh"); e.Path != "ft.bin" {
+		t.Errorf("registry entry changed: %+v", e)
+	}
+
+	// overwrite：替换文件与注册表条目
+	e, err := ImportModel(ctx, modelsDir, src, ModelEntry{Name: "ft-zh", Languages: []string{"zh"}}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(modelsDir, "ft.bin")); !strings.HasSuffix(string(data), "v2") {
+		t.Error("overwrite did not replace the model file")
+	}
+	if got, _ := reg.Lookup("ft-zh"); got.SHA256 != e.SHA256 || len(got.Languages) != 1 {
+		t.Errorf("registry entry not replaced: %+v", got)
+	}
+	if parts, _ := filepath.Glob(filepath.Join(modelsDir, "*.part")); len(parts) != 0 {
+		t.Errorf(".part left behind: %v", parts)
+	}
+}
+
+func TestImportModelConcurrent(t *testing.T) {
+	reg := withRegistry(t)
+	modelsDir, srcDir := t.TempDir(), t.TempDir()
+	srcs := make([]string, 4)
+	for i := range srcs {
+		srcs[i] = filepath.Join(srcDir, fmt.Sprintf("v%d.bin", i))
+		writeModel(t, srcs[i], strings.Repeat(fmt.Sprint(i), 1<<16))
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(srcs))
+	for i, src := range srcs {
+		wg.Go(func() {
+			_, errs[i] = ImportModel(context.Background(), modelsDir, src, ModelEntry{Name: "ft", Path: "ft.bin"}, true)
+		})
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("import %d: %v", i, err)
+		}
+	}
+	// 最终文件必须完整对应某一次导入，并与注册表中的校验和一致
+	e, _ := reg.Lookup("ft")
+	if err := verifyFileSHA256(filepath.Join(modelsDir, "ft.bin"), e.SHA256); err != nil {
+		t.Error(err)
+	}
+	if parts, _ := filepath.Glob(filepath.Join(modelsDir, "*.part")); len(parts) != 0 {
+		t.Errorf(".part left behind: %v", parts)
+	}
+}
+
+func TestEnsureModelVerifiesExistingFile(t *testing.T) {
+	reg := withRegistry(t)
+	modelsDir := t.TempDir()
+	path := filepath.Join(modelsDir, "ft.bin")
+	writeModel(t, path, "good")
+	sum := sha256.Sum256(append(append([]byte{}, ggmlMagic...), "good"...))
+	if err := reg.Add(&ModelEntry{Name: "ft", Path: "ft.bin", SHA256: hex.EncodeToString(sum[:])}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := EnsureModelInDir(context.Background(), modelsDir, "ft"); err != nil {
+		t.Fatalf("intact model: %v", err)
+	}
+	// 文件被替换后不能沿用之前的校验结果
+	writeModel(t, path, "tampered")
+	if _, _, err := EnsureModelInDir(context.Background(), modelsDir, "ft"); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("tampered model err = %v, want ErrChecksumMismatch", err)
+	}
+}
+
+func TestResolveImportSource(t *testing.T) {
+	importDir, outside := t.TempDir(), t.TempDir()
+	writeModel(t, filepath.Join(importDir, "a.bin"), "")
+	writeModel(t, filepath.Join(outside, "secret.bin"), "")
+
+	for _, src := range []string{"a.bin", filepath.Join(importDir, "a.bin")} {
+		p, err := ResolveImportSource(importDir, src)
+		if err != nil {
+			t.Errorf("ResolveImportSource(%q): %v", src, err)
+		} else if filepath.Base(p) != "a.bin" {
+			t.Errorf("ResolveImportSource(%q) = %q", src, p)
+		}
+	}
+
+	for _, src := range []string{"../" + filepath.Base(outside) + "/secret.bin", filepath.Join(outside, "secret.bin")} {
+		if _, err := ResolveImportSource(importDir, src); !errors.Is(err, ErrImportNotAllowed) {
+			t.Errorf("ResolveImportSource(%q) err = %v, want ErrImportNotAllowed", src, err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(outside, "secret.bin"), filepath.Join(importDir, "link.bin")); err == nil {
+		if _, err := ResolveImportSource(importDir, "link.bin"); !errors.Is(err, ErrImportNotAllowed) {
+			t.Errorf("symlink escape err = %v, want ErrImportNotAllowed", err)
+		}
+	}
+	if _, err := ResolveImportSource("", "a.bin"); !errors.Is(err, ErrImportNotAllowed) {
+		t.Errorf("no import dir err = %v, want ErrImportNotAllowed", err)
+	}
+}
+
+func TestLoadModelRegistryDuplicateNames(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "registry.json")
+	data := `{"models":[{"name":"ft","path":"a.bin"},{"name":"FT","path":"b.bin"},{"name":"ok","path":"c.bin"}]}`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadModelRegistry(path); err == nil || !strings.Contains(err.Error(), `duplicate name "FT"`) {
+		t.Errorf("err = %v, want duplicate name error", err)
+	}
+}
@@ -0,0 +1,102 @@
+package pkg
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// InstalledModel 模型目录中的模型文件
+type InstalledModel struct {
+	Name       string `json:"name"` // 注册表别名，未登记时为文件名
+	Path       string `json:"path"`
+	Size       int64  `json:"size"`
+	Registered bool   `json:"registered"`
+}
+
+// ModelLocalPath 模型在本地的路径：注册表优先，否则按别名/文件名规则推断
+func ModelLocalPath(modelsDir, spec string) string {
+	if modelsDir == "" {
+		modelsDir = "./models"
+	}
+	if entry, ok := LookupModel(spec); ok {
+		return entry.LocalPath(modelsDir)
+	}
+	return filepath.Join(modelsDir, filepath.Base(normalizeSpecToFilename(spec)))
+}
+
+// CheckModelAvailable 模型是否已在本地；不在本地时检查能否下载（离线模式或注册表未登记地址时返回错误），不访问网络
+func CheckModelAvailable(modelsDir, spec string) (localPath string, installed bool, err error) {
+	localPath = ModelLocalPath(modelsDir, spec)
+	if fi, e := os.Stat(localPath); e == nil && fi.Size() > 0 {
+		return localPath, true, nil
+	}
+	if IsOffline() {
+		return localPath, false, fmt.Errorf("%w: %s (%s)", ErrOffline, spec, localPath)
+	}
+	if entry, ok := LookupModel(spec); ok && entry.URL == "" {
+		return localPath, false, fmt.Errorf("registered model %s not found at %s and has no url", spec, localPath)
+	}
+	return localPath, false, nil
+}
+
+// ListInstalledModels 列出模型目录中的 .bin/.gguf 文件，以及注册表中登记但位于其他位置的模型
+func ListInstalledModels(modelsDir string) ([]InstalledModel, error) {
+	if modelsDir == "" {
+		modelsDir = "./models"
+	}
+	byPath := map[string]*InstalledModel{}
+	entries, err := os.ReadDir(modelsDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, e := range entries {
+		ext := strings.ToLower(filepath.Ext(e.Name()))
+		if e.IsDir() || (ext != ".bin" && ext != ".gguf") {
+			continue
+		}
+		fi, err := e.Info()
+		if err != nil {
+			continue
+		}
+		p := filepath.Join(modelsDir, e.Name())
+		byPath[p] = &InstalledModel{Name: e.Name(), Path: p, Size: fi.Size()}
+	}
+	for _, entry := range DefaultModelRegistry().List() {
+		p := entry.LocalPath(modelsDir)
+		fi, err := os.Stat(p)
+		if err != nil {
+			continue
+		}
+		byPath[p] = &InstalledModel{Name: entry.Name, Path: p, Size: fi.Size(), Registered: true}
+	}
+
+	out := make([]InstalledModel, 0, len(byPath))
+	for _, m := range byPath {
+		out = append(out, *m)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+// RemoveModel 删除模型文件；注册表中的模型同时移除登记
+func RemoveModel(modelsDir, spec string) (string, error) {
+	localPath := ModelLocalPath(modelsDir, spec)
+	err := os.Remove(localPath)
+	if err != nil && !os.IsNotExist(err) {
+		return localPath, err
+	}
+	removedFile := err == nil
+
+	reg := DefaultModelRegistry()
+	if reg != nil && reg.Remove(spec) {
+		if err := reg.Save(); err != nil {
+			return localPath, err
+		}
+	} else if !removedFile {
+		return localPath, fmt.Errorf("model %s not found at %s", spec, localPath)
+	}
+	return localPath, nil
+}
@@ -9,7 +9,12 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
+
+	"go-whisper-mcp/pkg/metrics"
+	"go-whisper-mcp/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // EnsureModelInDir: 静默下载（老接口，兼容）
@@ -17,47 +22,74 @@ func EnsureModelInDir(ctx context.Context, modelsDir, spec string) (localPath st
 	return EnsureModelInDirWithProgress(ctx, modelsDir, spec, nil)
 }
 
-// Progress 控制台进度条选项（传 nil 表示不显示）
-type Progress struct {
-	Enabled        bool          // 开/关
-	Out            io.Writer     // 默认 os.Stderr
-	BarWidth       int           // 进度条宽度，默认 40
-	UpdateInterval time.Duration // 刷新间隔，默认 200ms
-}
-
-// EnsureModelInDirWithProgress: 带进度条下载
-func EnsureModelInDirWithProgress(ctx context.Context, modelsDir, spec string, prog *Progress) (localPath string, downloaded bool, err error) {
+// EnsureModelInDirWithProgress: 带进度事件下载（prog 可为 nil）
+func EnsureModelInDirWithProgress(ctx context.Context, modelsDir, spec string, prog ProgressReporter) (localPath string, downloaded bool, err error) {
+	// 默认超时
+	if ctx == nil {
+		tctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+		defer cancel()
+		ctx = tctx
+	}
+	ctx, span := tracing.Start(ctx, "ensure_model", attribute.String("whisper.model", spec))
+	defer func() {
+		span.SetAttributes(attribute.Bool("whisper.model.downloaded", downloaded))
+		tracing.End(span, err)
+	}()
 	if modelsDir == "" {
 		modelsDir = "./models"
 	}
-	filename := normalizeSpecToFilename(spec)
-	filename = filepath.Base(filename)
-	localPath = filepath.Join(modelsDir, filename)
+	var urls []string
+	var checksum string
+	entry, registered := LookupModel(spec)
+	if registered {
+		// 注册表中的模型：只认声明的路径与地址
+		localPath = entry.LocalPath(modelsDir)
+		checksum = entry.SHA256
+		if entry.URL != "" {
+			urls = []string{entry.URL}
+		}
+	} else {
+		filename := normalizeSpecToFilename(spec)
+		filename = filepath.Base(filename)
+		localPath = filepath.Join(modelsDir, filename)
+		urls = candidateURLs(filename)
+	}
+	filename := filepath.Base(localPath)
 
-	// 已存在
+	// 已存在：注册表声明了校验和时同样校验，不让条目为未核对过的文件背书
 	if fi, e := os.Stat(localPath); e == nil && fi.Size() > 0 {
+		if checksum != "" {
+			if e := verifyInstalledSHA256(localPath, fi, checksum); e != nil {
+				return "", false, e
+			}
+		}
 		return localPath, false, nil
 	}
-	if err = os.MkdirAll(modelsDir, 0o755); err != nil {
-		return "", false, fmt.Errorf("mkdir %s: %w", modelsDir, err)
+	if IsOffline() {
+		return "", false, fmt.Errorf("%w: %s (%s)", ErrOffline, spec, localPath)
+	}
+	if len(urls) == 0 {
+		return "", false, fmt.Errorf("registered model %s not found at %s and has no url", spec, localPath)
+	}
+	if err = os.MkdirAll(filepath.Dir(localPath), 0o755); err != nil {
+		return "", false, fmt.Errorf("mkdir %s: %w", filepath.Dir(localPath), err)
 	}
 
-	urls := candidateURLs(filename)
+	defer func() { metrics.ModelDownloads.WithLabelValues(metrics.Status(err == nil)).Inc() }()
 	tmp := localPath + ".part"
 
-	// 默认超时
-	if ctx == nil {
-		tctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
-		defer cancel()
-		ctx = tctx
-	}
-
 	var lastErr error
 	for _, u := range urls {
 		if e := downloadTo(ctx, u, tmp, prog); e != nil {
 			lastErr = e
 			continue
 		}
+		if checksum != "" {
+			if e := verifyFileSHA256(tmp, checksum); e != nil {
+				_ = os.Remove(tmp)
+				return "", false, e
+			}
+		}
 		if e := os.Rename(tmp, localPath); e != nil {
 			return "", false, fmt.Errorf("rename: %w", e)
 		}
@@ -71,27 +103,66 @@ func EnsureModelInDirWithProgress(ctx context.Context, modelsDir, spec string, p
 
 // ---------- 内部逻辑 ----------
 
+// verifiedModels 已通过校验的模型文件：路径 → modelStamp；文件未变化时不再重复计算大文件的校验和
+var verifiedModels sync.Map
+
+type modelStamp struct {
+	size    int64
+	modTime int64
+	sum     string
+}
+
+// verifyInstalledSHA256 校验已安装的模型文件，结果按大小与修改时间缓存
+func verifyInstalledSHA256(path string, fi os.FileInfo, want string) error {
+	stamp := modelStamp{size: fi.Size(), modTime: fi.ModTime().UnixNano(), sum: strings.ToLower(want)}
+	if v, ok := verifiedModels.Load(path); ok && v.(modelStamp) == stamp {
+		return nil
+	}
+	if err := verifyFileSHA256(path, want); err != nil {
+		verifiedModels.Delete(path)
+		return err
+	}
+	verifiedModels.Store(path, stamp)
+	return nil
+}
+
+// builtinModels 内置别名到官方模型文件名
+var builtinModels = map[string]string{
+	"tiny":           "ggml-tiny.bin",
+	"tiny.en":        "ggml-tiny.en.bin",
+	"base":           "ggml-base.bin",
+	"base.en":        "ggml-base.en.bin",
+	"small":          "ggml-small.bin",
+	"small.en":       "ggml-small.en.bin",
+	"medium":         "ggml-medium.bin",
+	"medium.en":      "ggml-medium.en.bin",
+	"large":          "ggml-large-v2.bin",
+	"large-v2":       "ggml-large-v2.bin",
+	"large-v3":       "ggml-large-v3.bin",
+	"large-v3-turbo": "ggml-large-v3-turbo.bin",
+}
+
+// isBuiltinModel 名称或文件名属于内置别名
+func isBuiltinModel(nameOrFile string) bool {
+	low := strings.ToLower(nameOrFile)
+	if _, ok := builtinModels[low]; ok {
+		return true
+	}
+	for _, f := range builtinModels {
+		if f == low {
+			return true
+		}
+	}
+	return false
+}
+
 func normalizeSpecToFilename(spec string) string {
 	s := strings.TrimSpace(spec)
 	low := strings.ToLower(s)
 	if strings.HasSuffix(low, ".bin") || strings.HasSuffix(low, ".gguf") {
 		return s
 	}
-	aliases := map[string]string{
-		"tiny":           "ggml-tiny.bin",
-		"tiny.en":        "ggml-tiny.en.bin",
-		"base":           "ggml-base.bin",
-		"base.en":        "ggml-base.en.bin",
-		"small":          "ggml-small.bin",
-		"small.en":       "ggml-small.en.bin",
-		"medium":         "ggml-medium.bin",
-		"medium.en":      "ggml-medium.en.bin",
-		"large":          "ggml-large-v2.bin",
-		"large-v2":       "ggml-large-v2.bin",
-		"large-v3":       "ggml-large-v3.bin",
-		"large-v3-turbo": "ggml-large-v3-turbo.bin",
-	}
-	if v, ok := aliases[low]; ok {
+	if v, ok := builtinModels[low]; ok {
 		return v
 	}
 	return s + ".bin"
@@ -105,7 +176,7 @@ func candidateURLs(filename string) []string {
 	}
 }
 
-func downloadTo(ctx context.Context, url, dst string, prog *Progress) error {
+func downloadTo(ctx context.Context, url, dst string, prog ProgressReporter) error {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return err
@@ -133,156 +204,11 @@ func downloadTo(ctx context.Context, url, dst string, prog *Progress) error {
 		}
 	}()
 
-	var reader io.Reader = resp.Body
-	var pw *progressWriter
-	if prog != nil && prog.Enabled {
-		pw = newProgressWriter(prog, resp.ContentLength, url)
-		reader = io.TeeReader(resp.Body, pw)
-	}
-
-	_, err = io.Copy(f, reader)
-	if pw != nil {
-		pw.finish(err == nil)
-	}
+	tracker := NewProgressTracker(prog, "model", url, resp.ContentLength)
+	_, err = io.Copy(f, io.TeeReader(resp.Body, tracker))
+	tracker.Finish(err)
 	if err != nil {
 		return fmt.Errorf("write: %w", err)
 	}
 	return nil
 }
-
-// ---------- 进度条 ----------
-
-type progressWriter struct {
-	out      io.Writer
-	total    int64 // -1 表示未知
-	written  int64
-	start    time.Time
-	last     time.Time
-	interval time.Duration
-	width    int
-	spi      int
-	urlName  string
-}
-
-func newProgressWriter(p *Progress, total int64, url string) *progressWriter {
-	out := p.Out
-	if out == nil {
-		out = os.Stderr
-	}
-	width := p.BarWidth
-	if width <= 0 {
-		width = 40
-	}
-	iv := p.UpdateInterval
-	if iv <= 0 {
-		iv = 200 * time.Millisecond
-	}
-	return &progressWriter{
-		out:      out,
-		total:    total,
-		start:    time.Now(),
-		last:     time.Time{},
-		interval: iv,
-		width:    width,
-		urlName:  shortName(url),
-	}
-}
-
-func (w *progressWriter) Write(b []byte) (int, error) {
-	n := len(b)
-	w.written += int64(n)
-	now := time.Now()
-	if w.last.IsZero() || now.Sub(w.last) >= w.interval {
-		w.print(false)
-		w.last = now
-	}
-	return n, nil
-}
-
-func (w *progressWriter) finish(ok bool) {
-	w.print(true)
-}
-
-func (w *progressWriter) print(final bool) {
-	spinners := []rune{'|', '/', '-', '\\'}
-	if final {
-		fmt.Fprint(w.out, "\r")
-	} else {
-		fmt.Fprintf(w.out, "\r")
-	}
-	if w.total > 0 {
-		ratio := float64(w.written) / float64(w.total)
-		if ratio > 1 {
-			ratio = 1
-		}
-		done := int(ratio * float64(w.width))
-		if done > w.width {
-			done = w.width
-		}
-		bar := strings.Repeat("█", done) + strings.Repeat("░", w.width-done)
-		elapsed := time.Since(w.start)
-		speed := humanBytes(float64(w.written) / elapsed.Seconds())
-		eta := "-"
-		if w.written > 0 {
-			remain := time.Duration(float64(w.total-w.written) / float64(w.written) * float64(elapsed))
-			eta = durShort(remain)
-		}
-		fmt.Fprintf(w.out, "[%s] %6.2f%%  %s / %s  %s/s  ETA %s  %s",
-			bar, ratio*100,
-			humanBytes(float64(w.written)), humanBytes(float64(w.total)),
-			speed, eta, w.urlName,
-		)
-		if final {
-			fmt.Fprintln(w.out)
-		}
-	} else {
-		// 未知大小：显示已传/速度/旋转指示
-		elapsed := time.Since(w.start)
-		speed := humanBytes(float64(w.written) / elapsed.Seconds())
-		ch := spinners[w.spi%len(spinners)]
-		w.spi++
-		fmt.Fprintf(w.out, "[%c] %s  %s/s  %s", ch, humanBytes(float64(w.written)), speed, w.urlName)
-		if final {
-			fmt.Fprintln(w.out)
-		}
-	}
-}
-
-func humanBytes(b float64) string {
-	const (
-		KB = 1024
-		MB = 1024 * KB
-		GB = 1024 * MB
-	)
-	switch {
-	case b >= GB:
-		return fmt.Sprintf("%.2f GiB", b/GB)
-	case b >= MB:
-		return fmt.Sprintf("%.2f MiB", b/MB)
-	case b >= KB:
-		return fmt.Sprintf("%.2f KiB", b/KB)
-	default:
-		return fmt.Sprintf("%.0f B", b)
-	}
-}
-
-func durShort(d time.Duration) string {
-	if d < 0 {
-		d = -d
-	}
-	s := int(d.Seconds() + 0.5)
-	h := s / 3600
-	m := (s % 3600) / 60
-	s2 := s % 60
-	if h > 0 {
-		return fmt.Sprintf("%02d:%02d:%02d", h, m, s2)
-	}
-	return fmt.Sprintf("%02d:%02d", m, s2)
-}
-
-func shortName(u string) string {
-	if i := strings.LastIndex(u, "/"); i >= 0 && i+1 < len(u) {
-		return u[i+1:]
-	}
-	return u
-}
@@ -8,7 +8,17 @@ import (
 	"fmt"
 	"io"
 	"math"
+	"os"
 	"os/exec"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go-whisper-mcp/pkg/logging"
+	"go-whisper-mcp/pkg/metrics"
+	"go-whisper-mcp/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // EnsureFFmpeg 检查系统是否安装了 ffmpeg。
@@ -20,14 +30,213 @@ func EnsureFFmpeg() error {
 	return nil
 }
 
-// DecodeF32 一次性内存管道：任意媒体 -> 16kHz/mono float32 PCM（不落盘）
-func DecodeF32(ctx context.Context, in string) ([]float32, error) {
+// processWaitDelay ctx 取消后等待子进程自行退出的时长，超过则强制结束
+const processWaitDelay = 5 * time.Second
+
+// InterruptOnCancel ctx 取消时先向子进程发送 SIGINT，让 ffmpeg / yt-dlp 正常收尾退出；
+// processWaitDelay 后仍未退出再强制结束。须在 Start 之前调用
+func InterruptOnCancel(cmd *exec.Cmd) {
+	cmd.Cancel = func() error { return cmd.Process.Signal(os.Interrupt) }
+	cmd.WaitDelay = processWaitDelay
+}
+
+// ---------- 预处理滤镜 ----------
+
+// 降噪方式
+const (
+	DenoiseFFT = "afftdn" // FFT 频域降噪，无需模型
+	DenoiseRNN = "arnndn" // RNNoise 神经网络降噪，需配置模型文件（ARNNDN_MODEL）
+)
+
+// 预处理参数范围
+const (
+	maxStreamIndex  = 63
+	maxChannelIndex = 63
+	minFilterFreq   = 20    // Hz
+	maxFilterFreq   = 20000 // Hz
+	minSpeed        = 0.5
+	maxSpeed        = 4.0
+)
+
+// ErrInvalidFilter 预处理参数不合法
+var ErrInvalidFilter = errors.New("invalid preprocess option")
+
+// denoiseModel arnndn 使用的 RNNoise 模型文件
+var denoiseModel atomic.Value
+
+// SetDenoiseModel 设置 arnndn 使用的 RNNoise 模型文件（.rnnn），为空时 arnndn 不可用
+func SetDenoiseModel(path string) {
+	denoiseModel.Store(path)
+}
+
+// DenoiseModel 当前 arnndn 模型文件
+func DenoiseModel() string {
+	p, _ := denoiseModel.Load().(string)
+	return p
+}
+
+// AudioFilters 可选的音频预处理。只接受结构化参数，由 FilterArgs 生成 ffmpeg 参数与滤镜图，
+// 不接受任意滤镜字符串。滤镜顺序：声道选择 → 裁剪 → 高通/低通 → 降噪 → 响度归一化 → 变速，最后统一重采样为 16kHz 单声道
+type AudioFilters struct {
+	Stream    *int    `json:"stream,omitempty" jsonschema:"音频流序号（第 N 条音频流，从 0 开始），缺省由 ffmpeg 选择"`
+	Channel   *int    `json:"channel,omitempty" jsonschema:"只取第 N 个声道（从 0 开始），缺省混合所有声道"`
+	TrimStart float64 `json:"trim_start,omitempty" jsonschema:"跳过开头的秒数"`
+	TrimEnd   float64 `json:"trim_end,omitempty" jsonschema:"截止时间（秒，原始音频时间轴），0 表示到结尾"`
+	HighPass  float64 `json:"highpass,omitempty" jsonschema:"高通截止频率（Hz，20~20000），去除低频嗡声"`
+	LowPass   float64 `json:"lowpass,omitempty" jsonschema:"低通截止频率（Hz，20~20000），去除高频嘶声"`
+	Denoise   string  `json:"denoise,omitempty" jsonschema:"降噪：afftdn（FFT）或 arnndn（RNNoise，需服务端配置模型）"`
+	Loudnorm  bool    `json:"loudnorm,omitempty" jsonschema:"EBU R128 响度归一化，适合音量过小的录音"`
+	Speed     float64 `json:"speed,omitempty" jsonschema:"播放速度（0.5~4，1 或 0 表示不变）；时间戳会换算回原始时间轴"`
+}
+
+// IsZero 是否未设置任何预处理
+func (f *AudioFilters) IsZero() bool {
+	return f == nil || *f == AudioFilters{}
+}
+
+// Validate 检查参数范围，错误均满足 errors.Is(err, ErrInvalidFilter)
+func (f *AudioFilters) Validate() error {
+	if f == nil {
+		return nil
+	}
+	var errs []error
+	fail := func(field, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%w: %s: %s", ErrInvalidFilter, field, fmt.Sprintf(format, args...)))
+	}
+	if f.Stream != nil && (*f.Stream < 0 || *f.Stream > maxStreamIndex) {
+		fail("stream", "must be between 0 and %d", maxStreamIndex)
+	}
+	if f.Channel != nil && (*f.Channel < 0 || *f.Channel > maxChannelIndex) {
+		fail("channel", "must be between 0 and %d", maxChannelIndex)
+	}
+	if f.TrimStart < 0 || math.IsNaN(f.TrimStart) || math.IsInf(f.TrimStart, 0) {
+		fail("trim_start", "must be >= 0")
+	}
+	if f.TrimEnd < 0 || math.IsNaN(f.TrimEnd) || math.IsInf(f.TrimEnd, 0) {
+		fail("trim_end", "must be >= 0")
+	} else if f.TrimEnd > 0 && f.TrimEnd <= f.TrimStart {
+		fail("trim_end", "must be greater than trim_start")
+	}
+	if f.HighPass != 0 && !(f.HighPass >= minFilterFreq && f.HighPass <= maxFilterFreq) {
+		fail("highpass", "must be between %d and %d Hz", minFilterFreq, maxFilterFreq)
+	}
+	if f.LowPass != 0 && !(f.LowPass >= minFilterFreq && f.LowPass <= maxFilterFreq) {
+		fail("lowpass", "must be between %d and %d Hz", minFilterFreq, maxFilterFreq)
+	}
+	if f.HighPass > 0 && f.LowPass > 0 && f.LowPass <= f.HighPass {
+		fail("lowpass", "must be greater than highpass")
+	}
+	switch f.Denoise {
+	case "", DenoiseFFT:
+	case DenoiseRNN:
+		if DenoiseModel() == "" {
+			fail("denoise", "arnndn requires a model configured on the server (ARNNDN_MODEL)")
+		}
+	default:
+		fail("denoise", "must be %s or %s, got %q", DenoiseFFT, DenoiseRNN, f.Denoise)
+	}
+	if f.Speed != 0 && !(f.Speed >= minSpeed && f.Speed <= maxSpeed) {
+		fail("speed", "must be between %g and %g", minSpeed, maxSpeed)
+	}
+	return errors.Join(errs...)
+}
+
+// FilterArgs 生成 -map 参数与 -af 滤镜图；未设置任何预处理时均为空
+func (f *AudioFilters) FilterArgs() (mapArgs []string, graph string) {
+	if f.IsZero() {
+		return nil, ""
+	}
+	if f.Stream != nil {
+		mapArgs = []string{"-map", "0:a:" + strconv.Itoa(*f.Stream)}
+	}
+	var chain []string
+	if f.Channel != nil {
+		chain = append(chain, "pan=mono|c0=c"+strconv.Itoa(*f.Channel))
+	}
+	if f.TrimStart > 0 || f.TrimEnd > 0 {
+		trim := "atrim=start=" + formatNum(f.TrimStart)
+		if f.TrimEnd > 0 {
+			trim += ":end=" + formatNum(f.TrimEnd)
+		}
+		chain = append(chain, trim, "asetpts=PTS-STARTPTS")
+	}
+	if f.HighPass > 0 {
+		chain = append(chain, "highpass=f="+formatNum(f.HighPass))
+	}
+	if f.LowPass > 0 {
+		chain = append(chain, "lowpass=f="+formatNum(f.LowPass))
+	}
+	switch f.Denoise {
+	case DenoiseFFT:
+		chain = append(chain, "afftdn")
+	case DenoiseRNN:
+		chain = append(chain, "arnndn=m="+escapeFilterValue(DenoiseModel()))
+	}
+	if f.Loudnorm {
+		chain = append(chain, "loudnorm")
+	}
+	if f.Speed > 0 && f.Speed != 1 {
+		// 单个 atempo 在旧版 ffmpeg 中只支持 0.5~2，超出时串联
+		v := f.Speed
+		for v > 2 {
+			chain = append(chain, "atempo=2")
+			v /= 2
+		}
+		chain = append(chain, "atempo="+formatNum(v))
+	}
+	return mapArgs, strings.Join(chain, ",")
+}
+
+// OriginalMs 把预处理后音频上的时间（毫秒）换算回原始音频时间轴：先乘以速度，再加上裁剪的起点
+func (f *AudioFilters) OriginalMs(ms int64) int64 {
+	if f.IsZero() {
+		return ms
+	}
+	t := float64(ms)
+	if f.Speed > 0 {
+		t *= f.Speed
+	}
+	return int64(math.Round(t + f.TrimStart*1000))
+}
+
+// formatNum 数值参数的十进制表示（不含指数与特殊字符）
+func formatNum(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// escapeFilterValue 转义滤镜图中的参数值：先按选项值转义 \ ' :，再按滤镜图转义 \ ' , ; [ ]
+func escapeFilterValue(v string) string {
+	v = strings.NewReplacer("\\", "\\\\", "'", "\\'", ":", "\\:").Replace(v)
+	return strings.NewReplacer("\\", "\\\\", "'", "\\'", ",", "\\,", ";", "\\;", "[", "\\[", "]", "\\]").Replace(v)
+}
+
+// DecodeF32 一次性内存管道：任意媒体 -> 16kHz/mono float32 PCM（不落盘）；filters 为 nil 时不做预处理
+func DecodeF32(ctx context.Context, in string, filters *AudioFilters) (_ []float32, err error) {
+	ctx, span := tracing.Start(ctx, "ffmpeg.decode", attribute.String("whisper.input", in))
+	defer func() { tracing.End(span, err) }()
+
 	if err := EnsureFFmpeg(); err != nil {
 		return nil, err
 	}
-	args := []string{"-i", in, "-vn", "-ac", "1", "-ar", "16000", "-f", "f32le", "pipe:1"}
+	if err := filters.Validate(); err != nil {
+		return nil, err
+	}
+	args := []string{"-i", in}
+	mapArgs, graph := filters.FilterArgs()
+	args = append(args, mapArgs...)
+	if graph != "" {
+		args = append(args, "-af", graph)
+		span.SetAttributes(attribute.String("ffmpeg.filters", graph))
+	}
+	args = append(args, "-vn", "-ac", "1", "-ar", "16000", "-f", "f32le", "pipe:1")
+	log := logging.FromContext(ctx).WithField("input", in)
+	if graph != "" {
+		log = log.WithField("filters", graph)
+	}
+	log.Debug("ffmpeg decode")
 	var stderr bytes.Buffer
 	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	InterruptOnCancel(cmd)
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
 		return nil, err
@@ -43,9 +252,16 @@ func DecodeF32(ctx context.Context, in string) ([]float32, error) {
 		return nil, err
 	}
 	if err := cmd.Wait(); err != nil {
+		if ctx.Err() == nil {
+			metrics.FFmpegDecodeFailures.Inc()
+			log.WithError(err).Warn("ffmpeg decode failed")
+		} else {
+			log.Debug("ffmpeg decode interrupted")
+		}
 		return nil, fmt.Errorf("ffmpeg: %w: %s", err, stderr.String())
 	}
 	if len(raw)%4 != 0 {
+		metrics.FFmpegDecodeFailures.Inc()
 		return nil, fmt.Errorf("unexpected f32le length %d", len(raw))
 	}
 	n := len(raw) / 4
@@ -70,8 +286,11 @@ func StreamF32(ctx context.Context, in string, chunkSamples int, onChunk func([]
 		return err
 	}
 	args := []string{"-i", in, "-vn", "-ac", "1", "-ar", "16000", "-f", "f32le", "pipe:1"}
+	log := logging.FromContext(ctx).WithField("input", in)
+	log.Debug("ffmpeg decode")
 	var stderr bytes.Buffer
 	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	InterruptOnCancel(cmd)
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
 		return err
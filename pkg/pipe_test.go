@@ -0,0 +1,161 @@
+package pkg
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestAudioFiltersValidate(t *testing.T) {
+	SetDenoiseModel("")
+	t.Cleanup(func() { SetDenoiseModel("") })
+	n := func(v int) *int { return &v }
+
+	tests := []struct {
+		name    string
+		f       *AudioFilters
+		invalid []string // 出错的字段
+	}{
+		{"nil", nil, nil},
+		{"zero", &AudioFilters{}, nil},
+		{"all valid", &AudioFilters{Stream: n(1), Channel: n(0), TrimStart: 1, TrimEnd: 5, HighPass: 80, LowPass: 8000, Denoise: DenoiseFFT, Loudnorm: true, Speed: 1.5}, nil},
+		{"bounds", &AudioFilters{Stream: n(maxStreamIndex), Channel: n(maxChannelIndex), HighPass: minFilterFreq, LowPass: maxFilterFreq, Speed: maxSpeed}, nil},
+		{"min speed", &AudioFilters{Speed: minSpeed}, nil},
+		{"negative stream", &AudioFilters{Stream: n(-1)}, []string{"stream"}},
+		{"stream too large", &AudioFilters{Stream: n(maxStreamIndex + 1)}, []string{"stream"}},
+		{"channel too large", &AudioFilters{Channel: n(maxChannelIndex + 1)}, []string{"channel"}},
+		{"negative trim_start", &AudioFilters{TrimStart: -1}, []string{"trim_start"}},
+		{"NaN trim_start", &AudioFilters{TrimStart: math.NaN()}, []string{"trim_start"}},
+		{"infinite trim_end", &AudioFilters{TrimEnd: math.Inf(1)}, []string{"trim_end"}},
+		{"trim_end before start", &AudioFilters{TrimStart: 10, TrimEnd: 5}, []string{"trim_end"}},
+		{"trim_end equals start", &AudioFilters{TrimStart: 5, TrimEnd: 5}, []string{"trim_end"}},
+		{"highpass too low", &AudioFilters{HighPass: 10}, []string{"highpass"}},
+		{"lowpass too high", &AudioFilters{LowPass: 30000}, []string{"lowpass"}},
+		{"NaN highpass", &AudioFilters{HighPass: math.NaN()}, []string{"highpass"}},
+		{"lowpass below highpass", &AudioFilters{HighPass: 1000, LowPass: 500}, []string{"lowpass"}},
+		{"unknown denoise", &AudioFilters{Denoise: "sox"}, []string{"denoise"}},
+		{"arnndn without model", &AudioFilters{Denoise: DenoiseRNN}, []string{"denoise"}},
+		{"speed too slow", &AudioFilters{Speed: 0.25}, []string{"speed"}},
+		{"speed too fast", &AudioFilters{Speed: 5}, []string{"speed"}},
+		{"negative speed", &AudioFilters{Speed: -1}, []string{"speed"}},
+		{"several errors", &AudioFilters{TrimStart: -1, Speed: 10, Denoise: "x"}, []string{"trim_start", "denoise", "speed"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.f.Validate()
+			if len(tt.invalid) == 0 {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalidFilter) {
+				t.Fatalf("Validate() = %v, want ErrInvalidFilter", err)
+			}
+			for _, field := range tt.invalid {
+				if !strings.Contains(err.Error(), field+":") {
+					t.Errorf("error %q does not mention %s", err, field)
+				}
+			}
+		})
+	}
+
+	SetDenoiseModel("/models/rnnoise.rnnn")
+	if err := (&AudioFilters{Denoise: DenoiseRNN}).Validate(); err != nil {
+		t.Errorf("arnndn with model: %v", err)
+	}
+}
+
+func TestAudioFiltersFilterArgs(t *testing.T) {
+	SetDenoiseModel("/models/std:v1.rnnn")
+	t.Cleanup(func() { SetDenoiseModel("") })
+	n := func(v int) *int { return &v }
+
+	tests := []struct {
+		name  string
+		f     *AudioFilters
+		maps  []string
+		graph string
+	}{
+		{"nil", nil, nil, ""},
+		{"zero", &AudioFilters{}, nil, ""},
+		{"stream only", &AudioFilters{Stream: n(2)}, []string{"-map", "0:a:2"}, ""},
+		{"channel", &AudioFilters{Channel: n(1)}, nil, "pan=mono|c0=c1"},
+		{"trim start", &AudioFilters{TrimStart: 1.5}, nil, "atrim=start=1.5,asetpts=PTS-STARTPTS"},
+		{"trim end only", &AudioFilters{TrimEnd: 30}, nil, "atrim=start=0:end=30,asetpts=PTS-STARTPTS"},
+		{"band pass", &AudioFilters{HighPass: 80, LowPass: 8000.5}, nil, "highpass=f=80,lowpass=f=8000.5"},
+		{"afftdn", &AudioFilters{Denoise: DenoiseFFT}, nil, "afftdn"},
+		{"arnndn escapes model path", &AudioFilters{Denoise: DenoiseRNN}, nil, `arnndn=m=/models/std\\:v1.rnnn`},
+		{"loudnorm", &AudioFilters{Loudnorm: true}, nil, "loudnorm"},
+		{"speed 1 is a no-op", &AudioFilters{Speed: 1}, nil, ""},
+		{"slow down", &AudioFilters{Speed: 0.5}, nil, "atempo=0.5"},
+		{"speed 2", &AudioFilters{Speed: 2}, nil, "atempo=2"},
+		{"speed 3 chains atempo", &AudioFilters{Speed: 3}, nil, "atempo=2,atempo=1.5"},
+		{"speed 4 chains atempo", &AudioFilters{Speed: 4}, nil, "atempo=2,atempo=2"},
+		{
+			"full chain in order",
+			&AudioFilters{Stream: n(0), Channel: n(0), TrimStart: 2, TrimEnd: 62, HighPass: 100, LowPass: 7000, Denoise: DenoiseFFT, Loudnorm: true, Speed: 2.5},
+			[]string{"-map", "0:a:0"},
+			"pan=mono|c0=c0,atrim=start=2:end=62,asetpts=PTS-STARTPTS,highpass=f=100,lowpass=f=7000,afftdn,loudnorm,atempo=2,atempo=1.25",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			maps, graph := tt.f.FilterArgs()
+			if !slices.Equal(maps, tt.maps) {
+				t.Errorf("map args = %q, want %q", maps, tt.maps)
+			}
+			if graph != tt.graph {
+				t.Errorf("graph = %q, want %q", graph, tt.graph)
+			}
+		})
+	}
+}
+
+func TestAudioFiltersOriginalMs(t *testing.T) {
+	tests := []struct {
+		name string
+		f    *AudioFilters
+		ms   int64
+		want int64
+	}{
+		{"nil", nil, 1234, 1234},
+		{"no preprocessing", &AudioFilters{}, 1234, 1234},
+		{"filters without timing change", &AudioFilters{Loudnorm: true, HighPass: 80}, 1234, 1234},
+		{"trim", &AudioFilters{TrimStart: 1.5}, 100, 1600},
+		{"trim end does not shift", &AudioFilters{TrimEnd: 10}, 100, 100},
+		{"speed", &AudioFilters{Speed: 2}, 1500, 3000},
+		{"slow down", &AudioFilters{Speed: 0.5}, 1500, 750},
+		{"trim and speed", &AudioFilters{TrimStart: 10, Speed: 2}, 1500, 13000},
+		{"rounds to nearest ms", &AudioFilters{Speed: 1.5}, 1001, 1502},
+		{"zero offset", &AudioFilters{TrimStart: 60, Speed: 3}, 0, 60000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.f.OriginalMs(tt.ms); got != tt.want {
+				t.Errorf("OriginalMs(%d) = %d, want %d", tt.ms, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEscapeFilterValue(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"/models/rnnoise.rnnn", "/models/rnnoise.rnnn"},
+		{"a:b", `a\\:b`},
+		{"it's", `it\\\'s`},
+		{`C:\m`, `C\\:\\\\m`},
+		{"a,b;c", `a\,b\;c`},
+		{"[in]", `\[in\]`},
+		{"x,y:z", `x\,y\\:z`},
+	}
+	for _, tt := range tests {
+		if got := escapeFilterValue(tt.in); got != tt.want {
+			t.Errorf("escapeFilterValue(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
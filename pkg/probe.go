@@ -0,0 +1,203 @@
+package pkg
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+
+	"go-whisper-mcp/pkg/logging"
+	"go-whisper-mcp/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// 音频流选择：缺省由 ffmpeg 选择；StreamAll 逐条转录全部音频流
+const StreamAll = "all"
+
+// ErrInvalidStream 音频流选择不合法或文件中没有匹配的音频流
+var ErrInvalidStream = errors.New("invalid audio stream")
+
+// AudioStream 媒体文件中的一条音频流
+type AudioStream struct {
+	Index      int    `json:"index" jsonschema:"音频流序号（第 N 条音频流，从 0 开始），即 audio_stream / preprocess.stream 使用的值"`
+	StreamID   int    `json:"stream_id" jsonschema:"容器内的流编号（含视频、字幕流）"`
+	Codec      string `json:"codec" jsonschema:"编码，如 aac、ac3、opus"`
+	Language   string `json:"language,omitempty" jsonschema:"语言标签（通常为 ISO 639-2，如 eng、chi）"`
+	Title      string `json:"title,omitempty" jsonschema:"音轨标题，如 Commentary"`
+	Channels   int    `json:"channels,omitempty"`
+	SampleRate int    `json:"sample_rate,omitempty"`
+	Default    bool   `json:"default,omitempty" jsonschema:"是否为容器标记的默认音轨"`
+}
+
+// MediaInfo ffprobe 检查结果
+type MediaInfo struct {
+	Format       string        `json:"format,omitempty"`
+	Duration     float64       `json:"duration,omitempty"` // 秒
+	AudioStreams []AudioStream `json:"audio_streams"`
+}
+
+// ffprobeOutput ffprobe -of json 的相关字段
+type ffprobeOutput struct {
+	Format struct {
+		FormatName string `json:"format_name"`
+		Duration   string `json:"duration"`
+	} `json:"format"`
+	Streams []struct {
+		Index       int               `json:"index"`
+		CodecName   string            `json:"codec_name"`
+		Channels    int               `json:"channels"`
+		SampleRate  string            `json:"sample_rate"`
+		Tags        map[string]string `json:"tags"`
+		Disposition map[string]int    `json:"disposition"`
+	} `json:"streams"`
+}
+
+// EnsureFFprobe 检查 ffprobe 是否可用（随 ffmpeg 一同安装）
+func EnsureFFprobe() error {
+	if _, err := exec.LookPath("ffprobe"); err != nil {
+		return fmt.Errorf("ffprobe not found in PATH: %w", err)
+	}
+	return nil
+}
+
+// ProbeMedia 用 ffprobe 列出媒体文件的音频流（语言标签、编码、声道数）
+func ProbeMedia(ctx context.Context, in string) (_ *MediaInfo, err error) {
+	ctx, span := tracing.Start(ctx, "ffprobe", attribute.String("whisper.input", in))
+	defer func() { tracing.End(span, err) }()
+
+	if err := EnsureFFprobe(); err != nil {
+		return nil, err
+	}
+	args := []string{"-v", "error", "-select_streams", "a", "-of", "json",
+		"-show_entries", "format=format_name,duration:stream=index,codec_name,channels,sample_rate:stream_tags=language,title:stream_disposition=default",
+		"-i", in}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "ffprobe", args...)
+	InterruptOnCancel(cmd)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		logging.FromContext(ctx).WithField("input", in).Warnf("ffprobe failed: %s", strings.TrimSpace(stderr.String()))
+		return nil, fmt.Errorf("ffprobe: %w; stderr=%s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	var out ffprobeOutput
+	if err := json.Unmarshal(stdout.Bytes(), &out); err != nil {
+		return nil, fmt.Errorf("ffprobe: parse output: %w", err)
+	}
+	info := &MediaInfo{Format: out.Format.FormatName, AudioStreams: make([]AudioStream, 0, len(out.Streams))}
+	info.Duration, _ = strconv.ParseFloat(out.Format.Duration, 64)
+	for i, st := range out.Streams {
+		rate, _ := strconv.Atoi(st.SampleRate)
+		lang := strings.ToLower(st.Tags["language"])
+		if lang == "und" {
+			lang = ""
+		}
+		info.AudioStreams = append(info.AudioStreams, AudioStream{
+			Index:      i,
+			StreamID:   st.Index,
+			Codec:      st.CodecName,
+			Language:   lang,
+			Title:      st.Tags["title"],
+			Channels:   st.Channels,
+			SampleRate: rate,
+			Default:    st.Disposition["default"] == 1,
+		})
+	}
+	span.SetAttributes(attribute.Int("ffprobe.audio_streams", len(info.AudioStreams)))
+	return info, nil
+}
+
+// ValidateStreamSelector 检查音频流选择的格式（不访问文件）：空、all、非负序号或语言代码
+func ValidateStreamSelector(sel string) error {
+	sel = strings.TrimSpace(sel)
+	if sel == "" || strings.EqualFold(sel, StreamAll) {
+		return nil
+	}
+	if n, err := strconv.Atoi(sel); err == nil {
+		if n < 0 || n > maxStreamIndex {
+			return fmt.Errorf("%w: index must be between 0 and %d", ErrInvalidStream, maxStreamIndex)
+		}
+		return nil
+	}
+	if len(sel) < 2 || len(sel) > 3 || strings.IndexFunc(sel, func(r rune) bool {
+		return (r < 'a' || r > 'z') && (r < 'A' || r > 'Z')
+	}) >= 0 {
+		return fmt.Errorf("%w: %q must be all, a stream index or a language code", ErrInvalidStream, sel)
+	}
+	return nil
+}
+
+// SelectAudioStreams 按选择返回要转录的音频流：all 返回全部，数字按音频流序号，其余按语言标签匹配
+// （ISO 639-1 与 639-2 互通，如 en 匹配 eng）；同一语言有多条时取默认音轨，否则取第一条
+func SelectAudioStreams(streams []AudioStream, sel string) ([]AudioStream, error) {
+	if err := ValidateStreamSelector(sel); err != nil {
+		return nil, err
+	}
+	sel = strings.ToLower(strings.TrimSpace(sel))
+	if len(streams) == 0 {
+		return nil, fmt.Errorf("%w: no audio streams in media", ErrInvalidStream)
+	}
+	if sel == StreamAll {
+		return streams, nil
+	}
+	if n, err := strconv.Atoi(sel); err == nil {
+		if n >= len(streams) {
+			return nil, fmt.Errorf("%w: index %d out of range (media has %d audio streams)", ErrInvalidStream, n, len(streams))
+		}
+		return streams[n : n+1], nil
+	}
+	var out []AudioStream
+	for _, st := range streams {
+		if !sameLanguage(st.Language, sel) {
+			continue
+		}
+		if st.Default {
+			return []AudioStream{st}, nil
+		}
+		if out == nil {
+			out = []AudioStream{st}
+		}
+	}
+	if len(out) == 0 {
+		langs := make([]string, 0, len(streams))
+		for _, st := range streams {
+			langs = append(langs, cmp.Or(st.Language, "und"))
+		}
+		return nil, fmt.Errorf("%w: no audio stream with language %q (available: %s)", ErrInvalidStream, sel, strings.Join(langs, ", "))
+	}
+	return out, nil
+}
+
+// iso639 常见语言的 ISO 639-1 代码到 ISO 639-2 代码（B/T 两种写法）
+var iso639 = map[string][]string{
+	"ar": {"ara"}, "de": {"ger", "deu"}, "en": {"eng"}, "es": {"spa"}, "fr": {"fre", "fra"},
+	"hi": {"hin"}, "id": {"ind"}, "it": {"ita"}, "ja": {"jpn"}, "ko": {"kor"},
+	"nl": {"dut", "nld"}, "pl": {"pol"}, "pt": {"por"}, "ru": {"rus"}, "sv": {"swe"},
+	"th": {"tha"}, "tr": {"tur"}, "uk": {"ukr"}, "vi": {"vie"}, "zh": {"chi", "zho", "cmn", "yue"},
+}
+
+// sameLanguage 语言标签是否表示同一语言
+func sameLanguage(tag, sel string) bool {
+	if tag == "" {
+		return false
+	}
+	if tag == sel {
+		return true
+	}
+	for short, long := range iso639 {
+		all := append([]string{short}, long...)
+		if slices.Contains(all, tag) && slices.Contains(all, sel) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,378 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go-whisper-mcp/pkg/logging"
+	"go-whisper-mcp/pkg/metrics"
+)
+
+// ProgressPhase 下载阶段
+type ProgressPhase string
+
+const (
+	PhaseStart    ProgressPhase = "start"    // 开始传输
+	PhaseTransfer ProgressPhase = "transfer" // 传输中
+	PhaseDone     ProgressPhase = "done"     // 完成
+	PhaseFailed   ProgressPhase = "failed"   // 失败
+)
+
+// ProgressEvent 结构化进度事件（模型下载 / 媒体下载通用）
+type ProgressEvent struct {
+	Kind       string        `json:"kind"`            // model / media
+	Name       string        `json:"name"`            // 文件名（URL 末段）
+	Phase      ProgressPhase `json:"phase"`           // 阶段
+	BytesDone  int64         `json:"bytes_done"`      // 已传字节
+	BytesTotal int64         `json:"bytes_total"`     // 总字节，-1 表示未知
+	Speed      float64       `json:"speed"`           // 本次传输的字节/秒（不含断点续传前已有的部分）
+	ETAS       float64       `json:"eta_s"`           // 预计剩余秒数，未知为 0
+	Error      string        `json:"error,omitempty"` // 失败原因
+}
+
+// ETA 预计剩余时间，未知为 0
+func (e ProgressEvent) ETA() time.Duration {
+	return time.Duration(e.ETAS * float64(time.Second))
+}
+
+// Percent 完成百分比；总量未知时返回 -1
+func (e ProgressEvent) Percent() float64 {
+	if e.BytesTotal <= 0 {
+		return -1
+	}
+	p := float64(e.BytesDone) / float64(e.BytesTotal) * 100
+	if p > 100 {
+		p = 100
+	}
+	return p
+}
+
+// ProgressReporter 进度事件接收者：终端进度条、结构化日志、SSE/任务状态、MCP 通知等
+type ProgressReporter interface {
+	Report(ProgressEvent)
+}
+
+// ProgressFunc 函数适配器
+type ProgressFunc func(ProgressEvent)
+
+// Report 实现 ProgressReporter
+func (f ProgressFunc) Report(e ProgressEvent) { f(e) }
+
+type multiProgress []ProgressReporter
+
+func (m multiProgress) Report(e ProgressEvent) {
+	for _, r := range m {
+		r.Report(e)
+	}
+}
+
+// MultiProgress 将事件分发给多个接收者（忽略 nil）
+func MultiProgress(rs ...ProgressReporter) ProgressReporter {
+	var out multiProgress
+	for _, r := range rs {
+		if r != nil {
+			out = append(out, r)
+		}
+	}
+	switch len(out) {
+	case 0:
+		return nil
+	case 1:
+		return out[0]
+	}
+	return out
+}
+
+// ---------- context 传递 ----------
+
+type progressCtxKey struct{}
+
+// WithProgress 在 ctx 上附加进度接收者（与已有的合并）
+func WithProgress(ctx context.Context, r ProgressReporter) context.Context {
+	return context.WithValue(ctx, progressCtxKey{}, MultiProgress(ProgressFromContext(ctx), r))
+}
+
+// ProgressFromContext 取出 ctx 上的进度接收者，可能为 nil
+func ProgressFromContext(ctx context.Context) ProgressReporter {
+	if ctx == nil {
+		return nil
+	}
+	r, _ := ctx.Value(progressCtxKey{}).(ProgressReporter)
+	return r
+}
+
+// DefaultProgress 根据 stderr 是否为终端选择进度条或结构化日志
+func DefaultProgress() ProgressReporter {
+	if fi, err := os.Stderr.Stat(); err == nil && fi.Mode()&os.ModeCharDevice != 0 {
+		return &Progress{Enabled: true}
+	}
+	return &LogProgress{}
+}
+
+// ---------- 进度跟踪 ----------
+
+// ProgressTracker 作为 io.Writer 统计字节并按间隔发出事件
+type ProgressTracker struct {
+	rep      ProgressReporter
+	kind     string
+	name     string
+	total    int64
+	done     int64
+	offset   int64 // 断点续传前已有的字节，不计入速度
+	start    time.Time
+	last     time.Time
+	interval time.Duration
+}
+
+// NewProgressTracker 创建跟踪器并发出 start 事件；rep 为 nil 时只计数
+func NewProgressTracker(rep ProgressReporter, kind, url string, total int64) *ProgressTracker {
+	t := &ProgressTracker{
+		rep:      rep,
+		kind:     kind,
+		name:     shortName(url),
+		total:    total,
+		start:    time.Now(),
+		interval: 200 * time.Millisecond,
+	}
+	t.emit(PhaseStart, nil)
+	return t
+}
+
+// SetOffset 断点续传时设置已有字节数；速度与剩余时间只按之后新传的字节计算
+func (t *ProgressTracker) SetOffset(n int64) {
+	t.done = n
+	t.offset = n
+}
+
+// BytesDone 已传字节数
+func (t *ProgressTracker) BytesDone() int64 {
+	return t.done
+}
+
+func (t *ProgressTracker) Write(b []byte) (int, error) {
+	t.Add(int64(len(b)))
+	return len(b), nil
+}
+
+// Add 计入 n 个新传输的字节；外部进程（如 yt-dlp）完成下载时一次性计入
+func (t *ProgressTracker) Add(n int64) {
+	t.done += n
+	metrics.DownloadBytes.WithLabelValues(t.kind).Add(float64(n))
+	now := time.Now()
+	if now.Sub(t.last) >= t.interval {
+		t.emit(PhaseTransfer, nil)
+		t.last = now
+	}
+}
+
+// Finish 发出 done / failed 事件
+func (t *ProgressTracker) Finish(err error) {
+	if err != nil {
+		t.emit(PhaseFailed, err)
+		return
+	}
+	t.emit(PhaseDone, nil)
+}
+
+func (t *ProgressTracker) emit(phase ProgressPhase, err error) {
+	if t.rep == nil {
+		return
+	}
+	ev := ProgressEvent{
+		Kind:       t.kind,
+		Name:       t.name,
+		Phase:      phase,
+		BytesDone:  t.done,
+		BytesTotal: t.total,
+	}
+	if elapsed := time.Since(t.start).Seconds(); elapsed > 0 {
+		ev.Speed = float64(t.done-t.offset) / elapsed
+	}
+	if t.total > 0 && ev.Speed > 0 && t.done < t.total {
+		ev.ETAS = float64(t.total-t.done) / ev.Speed
+	}
+	if err != nil {
+		ev.Error = err.Error()
+	}
+	t.rep.Report(ev)
+}
+
+// ---------- 进度条 ----------
+
+// Progress 控制台进度条（传 nil 或 Enabled=false 表示不显示）
+type Progress struct {
+	Enabled        bool          // 开/关
+	Out            io.Writer     // 默认 os.Stderr
+	BarWidth       int           // 进度条宽度，默认 40
+	UpdateInterval time.Duration // 刷新间隔，默认 200ms
+
+	mu   sync.Mutex
+	last time.Time
+	spi  int
+}
+
+// Report 实现 ProgressReporter：绘制终端进度条
+func (p *Progress) Report(e ProgressEvent) {
+	if p == nil || !p.Enabled {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	out := p.Out
+	if out == nil {
+		out = os.Stderr
+	}
+	width := p.BarWidth
+	if width <= 0 {
+		width = 40
+	}
+	iv := p.UpdateInterval
+	if iv <= 0 {
+		iv = 200 * time.Millisecond
+	}
+	final := e.Phase == PhaseDone || e.Phase == PhaseFailed
+	now := time.Now()
+	if !final && !p.last.IsZero() && now.Sub(p.last) < iv {
+		return
+	}
+	p.last = now
+
+	spinners := []rune{'|', '/', '-', '\\'}
+	fmt.Fprint(out, "\r")
+	if e.BytesTotal > 0 {
+		ratio := e.Percent() / 100
+		done := int(ratio * float64(width))
+		if done > width {
+			done = width
+		}
+		bar := strings.Repeat("█", done) + strings.Repeat("░", width-done)
+		eta := "-"
+		if e.ETAS > 0 || e.BytesDone >= e.BytesTotal {
+			eta = durShort(e.ETA())
+		}
+		fmt.Fprintf(out, "[%s] %6.2f%%  %s / %s  %s/s  ETA %s  %s",
+			bar, ratio*100,
+			HumanBytes(float64(e.BytesDone)), HumanBytes(float64(e.BytesTotal)),
+			HumanBytes(e.Speed), eta, e.Name,
+		)
+	} else {
+		// 未知大小：显示已传/速度/旋转指示
+		ch := spinners[p.spi%len(spinners)]
+		p.spi++
+		fmt.Fprintf(out, "[%c] %s  %s/s  %s", ch, HumanBytes(float64(e.BytesDone)), HumanBytes(e.Speed), e.Name)
+	}
+	if final {
+		fmt.Fprintln(out)
+	}
+}
+
+// ---------- 结构化日志 ----------
+
+// LogProgress 以 logrus 结构化字段输出进度（适合容器日志）
+type LogProgress struct {
+	Interval time.Duration // 两条 transfer 日志的最小间隔，默认 5s
+
+	mu   sync.Mutex
+	last map[string]time.Time
+}
+
+// Report 实现 ProgressReporter
+func (l *LogProgress) Report(e ProgressEvent) {
+	l.report(logrus.NewEntry(logrus.StandardLogger()), e)
+}
+
+// WithContext 绑定请求的日志字段（request_id / trace_id），与 l 共用节流状态
+func (l *LogProgress) WithContext(ctx context.Context) ProgressReporter {
+	entry := logging.FromContext(ctx)
+	return ProgressFunc(func(e ProgressEvent) { l.report(entry, e) })
+}
+
+func (l *LogProgress) report(base *logrus.Entry, e ProgressEvent) {
+	iv := l.Interval
+	if iv <= 0 {
+		iv = 5 * time.Second
+	}
+	key := e.Kind + "/" + e.Name
+	l.mu.Lock()
+	if l.last == nil {
+		l.last = map[string]time.Time{}
+	}
+	if e.Phase == PhaseTransfer && time.Since(l.last[key]) < iv {
+		l.mu.Unlock()
+		return
+	}
+	l.last[key] = time.Now()
+	if e.Phase == PhaseDone || e.Phase == PhaseFailed {
+		delete(l.last, key)
+	}
+	l.mu.Unlock()
+
+	entry := base.WithFields(logrus.Fields{
+		"kind":        e.Kind,
+		"name":        e.Name,
+		"phase":       e.Phase,
+		"bytes_done":  e.BytesDone,
+		"bytes_total": e.BytesTotal,
+		"speed":       HumanBytes(e.Speed) + "/s",
+	})
+	if p := e.Percent(); p >= 0 {
+		entry = entry.WithField("percent", fmt.Sprintf("%.1f", p))
+	}
+	if e.ETAS > 0 {
+		entry = entry.WithField("eta", durShort(e.ETA()))
+	}
+	if e.Phase == PhaseFailed {
+		entry.WithField("error", e.Error).Warn("download failed")
+		return
+	}
+	entry.Info("download progress")
+}
+
+// ---------- 格式化 ----------
+
+// HumanBytes 人类可读的字节数
+func HumanBytes(b float64) string {
+	const (
+		KB = 1024
+		MB = 1024 * KB
+		GB = 1024 * MB
+	)
+	switch {
+	case b >= GB:
+		return fmt.Sprintf("%.2f GiB", b/GB)
+	case b >= MB:
+		return fmt.Sprintf("%.2f MiB", b/MB)
+	case b >= KB:
+		return fmt.Sprintf("%.2f KiB", b/KB)
+	default:
+		return fmt.Sprintf("%.0f B", b)
+	}
+}
+
+func durShort(d time.Duration) string {
+	if d < 0 {
+		d = -d
+	}
+	s := int(d.Seconds() + 0.5)
+	h := s / 3600
+	m := (s % 3600) / 60
+	s2 := s % 60
+	if h > 0 {
+		return fmt.Sprintf("%02d:%02d:%02d", h, m, s2)
+	}
+	return fmt.Sprintf("%02d:%02d", m, s2)
+}
+
+func shortName(u string) string {
+	if i := strings.LastIndex(u, "/"); i >= 0 && i+1 < len(u) {
+		return u[i+1:]
+	}
+	return u
+}
@@ -0,0 +1,53 @@
+package pkg
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestProgressTrackerResumeSpeed(t *testing.T) {
+	var events []ProgressEvent
+	rep := ProgressFunc(func(e ProgressEvent) { events = append(events, e) })
+
+	tr := NewProgressTracker(rep, "media", "https://example.com/talk.mp3", 10000)
+	tr.SetOffset(8000)
+	tr.start = time.Now().Add(-2 * time.Second)
+	if _, err := tr.Write(make([]byte, 1000)); err != nil {
+		t.Fatal(err)
+	}
+	tr.Finish(nil)
+
+	e := events[len(events)-1]
+	if e.BytesDone != 9000 {
+		t.Errorf("BytesDone = %d, want 9000", e.BytesDone)
+	}
+	// 续传前已有的 8000 字节不计入速度：1000 B / 2s
+	if math.Abs(e.Speed-500) > 25 {
+		t.Errorf("Speed = %.1f, want ~500", e.Speed)
+	}
+	if math.Abs(e.ETAS-2) > 0.1 {
+		t.Errorf("ETAS = %.2f, want ~2", e.ETAS)
+	}
+	if d := e.ETA(); d < 1900*time.Millisecond || d > 2100*time.Millisecond {
+		t.Errorf("ETA() = %v, want ~2s", d)
+	}
+}
+
+func TestProgressEventJSON(t *testing.T) {
+	data, err := json.Marshal(ProgressEvent{Kind: "model", Name: "ggml-small.bin", Phase: PhaseTransfer, BytesDone: 1, BytesTotal: 4, Speed: 1, ETAS: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m map[string]any
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatal(err)
+	}
+	if m["eta_s"] != 3.0 {
+		t.Errorf("eta_s = %v, want 3 (seconds)", m["eta_s"])
+	}
+	if _, ok := m["eta"]; ok {
+		t.Error("unexpected nanosecond eta field")
+	}
+}
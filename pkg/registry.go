@@ -0,0 +1,211 @@
+package pkg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// ModelDefaults 模型的默认解码参数（请求未指定时生效）
+type ModelDefaults struct {
+	Language      string  `json:"language,omitempty"`
+	Threads       int     `json:"threads,omitempty"`
+	BeamSize      int     `json:"beam_size,omitempty"`
+	Temperature   float32 `json:"temperature,omitempty"`
+	InitialPrompt string  `json:"initial_prompt,omitempty"`
+}
+
+// ModelEntry 本地注册表中的模型条目
+type ModelEntry struct {
+	Name      string        `json:"name"`                // 别名（大小写不敏感）
+	Path      string        `json:"path"`                // 模型文件；相对路径基于 MODELS_DIR
+	URL       string        `json:"url,omitempty"`       // 可选下载地址；离线模式下忽略
+	Languages []string      `json:"languages,omitempty"` // 支持的语言，空表示不限制
+	SHA256    string        `json:"sha256,omitempty"`    // 文件校验和（十六进制）
+	Defaults  ModelDefaults `json:"defaults,omitempty"`
+}
+
+// LocalPath 返回条目在 modelsDir 下的实际路径
+func (e *ModelEntry) LocalPath(modelsDir string) string {
+	if filepath.IsAbs(e.Path) {
+		return e.Path
+	}
+	return filepath.Join(modelsDir, e.Path)
+}
+
+// SupportsLanguage 判断模型是否声明支持该语言（auto/空总是允许）
+func (e *ModelEntry) SupportsLanguage(lang string) bool {
+	if len(e.Languages) == 0 || lang == "" || strings.EqualFold(lang, "auto") {
+		return true
+	}
+	for _, l := range e.Languages {
+		if strings.EqualFold(l, lang) {
+			return true
+		}
+	}
+	return false
+}
+
+// ModelRegistry 本地模型注册表（JSON 文件），用于登记离线/自定义模型
+type ModelRegistry struct {
+	mu      sync.RWMutex
+	path    string
+	entries map[string]*ModelEntry
+}
+
+type registryFile struct {
+	Models []*ModelEntry `json:"models"`
+}
+
+// LoadModelRegistry 从文件加载注册表；文件不存在时返回空注册表
+func LoadModelRegistry(path string) (*ModelRegistry, error) {
+	r := &ModelRegistry{path: path, entries: map[string]*ModelEntry{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read registry %s: %w", path, err)
+	}
+	var rf registryFile
+	if err := json.Unmarshal(data, &rf); err != nil {
+		return nil, fmt.Errorf("parse registry %s: %w", path, err)
+	}
+	var errs []error
+	for i, e := range rf.Models {
+		if e == nil || strings.TrimSpace(e.Name) == "" || strings.TrimSpace(e.Path) == "" {
+			errs = append(errs, fmt.Errorf("registry %s: entry %d requires name and path", path, i))
+			continue
+		}
+		key := strings.ToLower(e.Name)
+		if _, dup := r.entries[key]; dup {
+			errs = append(errs, fmt.Errorf("registry %s: entry %d: duplicate name %q", path, i, e.Name))
+			continue
+		}
+		r.entries[key] = e
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Path 注册表文件路径
+func (r *ModelRegistry) Path() string {
+	return r.path
+}
+
+// Lookup 按别名查找
+func (r *ModelRegistry) Lookup(name string) (*ModelEntry, bool) {
+	if r == nil {
+		return nil, false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	e, ok := r.entries[strings.ToLower(strings.TrimSpace(name))]
+	return e, ok
+}
+
+// List 返回按名称排序的全部条目
+func (r *ModelRegistry) List() []*ModelEntry {
+	if r == nil {
+		return nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]*ModelEntry, 0, len(r.entries))
+	for _, e := range r.entries {
+		out = append(out, e)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Put 新增或覆盖条目（不落盘，需调用 Save）
+func (r *ModelRegistry) Put(e *ModelEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries[strings.ToLower(e.Name)] = e
+}
+
+// Add 新增条目，同名（不区分大小写）已存在时返回 ErrModelExists（不落盘，需调用 Save）
+func (r *ModelRegistry) Add(e *ModelEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := strings.ToLower(e.Name)
+	if _, ok := r.entries[key]; ok {
+		return fmt.Errorf("%w: name %q already registered", ErrModelExists, e.Name)
+	}
+	r.entries[key] = e
+	return nil
+}
+
+// Remove 删除条目（不落盘，需调用 Save）
+func (r *ModelRegistry) Remove(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := strings.ToLower(strings.TrimSpace(name))
+	_, ok := r.entries[key]
+	delete(r.entries, key)
+	return ok
+}
+
+// Save 原子写回注册表文件
+func (r *ModelRegistry) Save() error {
+	if r.path == "" {
+		return errors.New("registry has no file path")
+	}
+	data, err := json.MarshalIndent(registryFile{Models: r.List()}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return err
+	}
+	tmp := r.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, r.path)
+}
+
+// ---------- 全局注册表 / 离线模式 ----------
+
+var (
+	defaultRegistry atomic.Pointer[ModelRegistry]
+	offline         atomic.Bool
+)
+
+// ErrOffline 离线模式下模型不在本地
+var ErrOffline = errors.New("offline mode: model not available locally")
+
+// SetModelRegistry 设置全局注册表（nil 表示清空）
+func SetModelRegistry(r *ModelRegistry) {
+	defaultRegistry.Store(r)
+}
+
+// DefaultModelRegistry 当前全局注册表，可能为 nil
+func DefaultModelRegistry() *ModelRegistry {
+	return defaultRegistry.Load()
+}
+
+// LookupModel 在全局注册表中查找模型别名
+func LookupModel(spec string) (*ModelEntry, bool) {
+	return DefaultModelRegistry().Lookup(spec)
+}
+
+// SetOffline 开关离线模式：开启后缺失的模型直接报错，不访问网络
+func SetOffline(v bool) {
+	offline.Store(v)
+}
+
+// IsOffline 是否处于离线模式
+func IsOffline() bool {
+	return offline.Load()
+}
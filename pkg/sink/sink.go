@@ -0,0 +1,144 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"go-whisper-mcp/configs"
+	"go-whisper-mcp/pkg/downloader"
+)
+
+// Sink 转录结果 / 字幕文件的输出目的地
+type Sink interface {
+	// Put 写入名为 name 的文件，返回可定位的地址（本地路径或 s3:// URI）
+	Put(ctx context.Context, name string, data []byte, contentType string) (string, error)
+}
+
+// ErrDestNotAllowed 目的地不在允许范围内（远程请求的本地目录须位于输出根目录下）
+var ErrDestNotAllowed = errors.New("output destination not allowed")
+
+// New 按目的地创建输出：s3://bucket/prefix 写入对象存储，其余视为本地目录（任意路径，仅供命令行与监听目录使用）
+func New(dest string) (Sink, error) {
+	dest = strings.TrimSpace(dest)
+	if dest == "" {
+		return nil, fmt.Errorf("empty output destination")
+	}
+	if downloader.IsS3URI(dest) {
+		return newS3Sink(dest)
+	}
+	return &DirSink{Dir: dest}, nil
+}
+
+// NewUnder 供远程请求使用：s3:// 目的地同 New；本地目录只能是 root 下的相对路径（或位于 root 内的绝对路径），
+// 写入时经 os.Root 解析，符号链接也不能越出 root。root 为空时不允许本地目录
+func NewUnder(dest, root string) (Sink, error) {
+	dest = strings.TrimSpace(dest)
+	if dest == "" {
+		return nil, fmt.Errorf("empty output destination")
+	}
+	if downloader.IsS3URI(dest) {
+		return newS3Sink(dest)
+	}
+	if root == "" {
+		return nil, fmt.Errorf("%w: local directories are disabled (set media.output_dir / OUTPUT_DIR), use s3://bucket/prefix", ErrDestNotAllowed)
+	}
+	rel := dest
+	if filepath.IsAbs(dest) {
+		absRoot, err := filepath.Abs(root)
+		if err != nil {
+			return nil, err
+		}
+		if rel, err = filepath.Rel(absRoot, filepath.Clean(dest)); err != nil {
+			return nil, fmt.Errorf("%w: %s is outside %s", ErrDestNotAllowed, dest, root)
+		}
+	}
+	if rel = filepath.Clean(rel); !filepath.IsLocal(rel) && rel != "." {
+		return nil, fmt.Errorf("%w: %s is outside %s", ErrDestNotAllowed, dest, root)
+	}
+	return &DirSink{Dir: rel, Root: root}, nil
+}
+
+func newS3Sink(dest string) (Sink, error) {
+	bucket, prefix, err := downloader.ParseS3URI(dest)
+	if err != nil {
+		return nil, err
+	}
+	client, err := downloader.NewS3Client(configs.GetS3())
+	if err != nil {
+		return nil, err
+	}
+	return &S3Sink{client: client, bucket: bucket, prefix: prefix}, nil
+}
+
+// DirSink 写入本地目录；Root 非空时 Dir 是 Root 下的相对路径，所有文件操作限制在 Root 内
+type DirSink struct {
+	Dir  string
+	Root string
+}
+
+// Put 实现 Sink：先写临时文件再重命名，避免留下半截文件
+func (s *DirSink) Put(_ context.Context, name string, data []byte, _ string) (string, error) {
+	if s.Root != "" {
+		return s.putUnderRoot(name, data)
+	}
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return "", err
+	}
+	p := filepath.Join(s.Dir, filepath.Base(name))
+	tmp := p + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp, p); err != nil {
+		return "", err
+	}
+	return p, nil
+}
+
+func (s *DirSink) putUnderRoot(name string, data []byte) (string, error) {
+	if err := os.MkdirAll(s.Root, 0o755); err != nil {
+		return "", err
+	}
+	root, err := os.OpenRoot(s.Root)
+	if err != nil {
+		return "", err
+	}
+	defer root.Close()
+	if err := root.MkdirAll(s.Dir, 0o755); err != nil {
+		return "", err
+	}
+	p := filepath.Join(s.Dir, filepath.Base(name))
+	tmp := p + ".tmp"
+	if err := root.WriteFile(tmp, data, 0o644); err != nil {
+		return "", err
+	}
+	if err := root.Rename(tmp, p); err != nil {
+		return "", err
+	}
+	return filepath.Join(s.Root, p), nil
+}
+
+// S3Sink 写入 S3 兼容存储的 bucket/prefix
+type S3Sink struct {
+	client *minio.Client
+	bucket string
+	prefix string
+}
+
+// Put 实现 Sink
+func (s *S3Sink) Put(ctx context.Context, name string, data []byte, contentType string) (string, error) {
+	key := path.Join(s.prefix, path.Base(name))
+	_, err := s.client.PutObject(ctx, s.bucket, key, bytes.NewReader(data), int64(len(data)),
+		minio.PutObjectOptions{ContentType: contentType})
+	if err != nil {
+		return "", fmt.Errorf("put s3://%s/%s: %w", s.bucket, key, err)
+	}
+	return "s3://" + s.bucket + "/" + key, nil
+}
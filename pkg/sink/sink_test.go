@@ -0,0 +1,147 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/johannesboyne/gofakes3"
+	"github.com/johannesboyne/gofakes3/backend/s3mem"
+	"github.com/minio/minio-go/v7"
+	"go-whisper-mcp/configs"
+	"go-whisper-mcp/pkg/downloader"
+)
+
+func TestS3SinkPut(t *testing.T) {
+	srv := httptest.NewServer(gofakes3.New(s3mem.New()).Server())
+	defer srv.Close()
+
+	cfg := configs.Default()
+	cfg.S3 = configs.S3Config{Endpoint: srv.URL, AccessKey: "test", SecretKey: "test-secret", Region: "us-east-1"}
+	configs.Set(cfg)
+	defer configs.Set(configs.Default())
+
+	ctx := context.Background()
+	client, err := downloader.NewS3Client(configs.GetS3())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.MakeBucket(ctx, "subs", minio.MakeBucketOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct{ dest, name, wantURI, wantKey string }{
+		{"s3://subs/2024/week1", "talk.srt", "s3://subs/2024/week1/talk.srt", "2024/week1/talk.srt"},
+		{"s3://subs", "talk.json", "s3://subs/talk.json", "talk.json"},
+		// 名称中的目录部分被丢弃，不能写到前缀之外
+		{"s3://subs/out/", "../../etc/talk.txt", "s3://subs/out/talk.txt", "out/talk.txt"},
+	} {
+		snk, err := NewUnder(tt.dest, "")
+		if err != nil {
+			t.Fatalf("NewUnder(%q): %v", tt.dest, err)
+		}
+		uri, err := snk.Put(ctx, tt.name, []byte("1\n00:00:00,000 --> 00:00:01,000\nhi\n"), "application/x-subrip")
+		if err != nil {
+			t.Fatalf("Put(%q): %v", tt.name, err)
+		}
+		if uri != tt.wantURI {
+			t.Errorf("Put(%q) = %q, want %q", tt.name, uri, tt.wantURI)
+		}
+		obj, err := client.GetObject(ctx, "subs", tt.wantKey, minio.GetObjectOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(obj)
+		obj.Close()
+		if err != nil || len(data) == 0 {
+			t.Errorf("object %s not written: %v", tt.wantKey, err)
+		}
+	}
+
+	snk, err := NewUnder("s3://missing-bucket/x", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := snk.Put(ctx, "a.json", []byte("{}"), "application/json"); err == nil {
+		t.Error("Put to a missing bucket should fail")
+	}
+}
+
+func TestNewUnderRejectsOutsideRoot(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+
+	for _, dest := range []string{"../escape", "a/../../escape", outside, "/etc"} {
+		if _, err := NewUnder(dest, root); !errors.Is(err, ErrDestNotAllowed) {
+			t.Errorf("NewUnder(%q) err = %v, want ErrDestNotAllowed", dest, err)
+		}
+	}
+	// 未配置输出根目录时只允许 s3://
+	if _, err := NewUnder("subs", ""); !errors.Is(err, ErrDestNotAllowed) {
+		t.Errorf("NewUnder without root err = %v, want ErrDestNotAllowed", err)
+	}
+	if _, err := NewUnder("  ", root); err == nil {
+		t.Error("empty dest should fail")
+	}
+}
+
+func TestNewUnderWritesInsideRoot(t *testing.T) {
+	root := t.TempDir()
+	ctx := context.Background()
+
+	for _, dest := range []string{"subs/2024", filepath.Join(root, "abs"), "."} {
+		snk, err := NewUnder(dest, root)
+		if err != nil {
+			t.Fatalf("NewUnder(%q): %v", dest, err)
+		}
+		p, err := snk.Put(ctx, "../talk.txt", []byte("hello"), "text/plain")
+		if err != nil {
+			t.Fatalf("Put under %q: %v", dest, err)
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil || !filepath.IsLocal(rel) {
+			t.Errorf("Put under %q wrote %q outside root", dest, p)
+		}
+		if data, err := os.ReadFile(p); err != nil || string(data) != "hello" {
+			t.Errorf("read %q: %q, %v", p, data, err)
+		}
+	}
+}
+
+func TestNewUnderRejectsSymlinkEscape(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(root, "link")); err != nil {
+		t.Skip("symlinks not supported:", err)
+	}
+
+	snk, err := NewUnder("link/subs", root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := snk.Put(context.Background(), "talk.txt", []byte("x"), "text/plain"); err == nil {
+		t.Error("Put through a symlink leaving the root should fail")
+	}
+	if entries, _ := os.ReadDir(outside); len(entries) != 0 {
+		t.Errorf("wrote %d entries outside the root", len(entries))
+	}
+}
+
+func TestNewLocalDirIsUnrestricted(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "any", "where")
+	snk, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := snk.Put(context.Background(), "talk.vtt", []byte("WEBVTT"), "text/vtt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p != filepath.Join(dir, "talk.vtt") {
+		t.Errorf("Put = %q", p)
+	}
+}
@@ -0,0 +1,95 @@
+// Package tracing OpenTelemetry 链路追踪：OTLP/HTTP 导出，未启用时所有 span 为 no-op
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "go-whisper-mcp"
+
+// 导出方式（OTEL_TRACES_EXPORTER）
+const (
+	ExporterNone = "none"
+	ExporterOTLP = "otlp"
+)
+
+// Setup 初始化全局 TracerProvider 与 W3C traceparent 传播器，返回的 shutdown 会刷出未发送的 span。
+// exporter 为 otlp 时端点等参数读取标准环境变量（OTEL_EXPORTER_OTLP_ENDPOINT、OTEL_EXPORTER_OTLP_HEADERS 等）
+func Setup(ctx context.Context, exporter string) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	switch exporter {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+	default:
+		return nil, fmt.Errorf("unsupported traces exporter %q (otlp, none)", exporter)
+	}
+
+	exp, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("otlp exporter: %w", err)
+	}
+	// OTEL_SERVICE_NAME / OTEL_RESOURCE_ATTRIBUTES 覆盖默认服务名
+	res, err := resource.New(ctx,
+		resource.WithAttributes(attribute.String("service.name", instrumentationName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+		resource.WithHost(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("otel resource: %w", err)
+	}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown, nil
+}
+
+// Start 开始一个内部 span
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// StartServer 开始一个服务端入口 span
+func StartServer(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(attrs...))
+}
+
+// End 记录错误（如有）并结束 span
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Extract 从载体（HTTP 头或 MCP _meta）提取上游 trace 上下文
+func Extract(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, carrier)
+}
+
+// MetaCarrier 把 MCP 请求的 _meta 中的字符串字段（traceparent / tracestate / baggage）作为传播载体
+func MetaCarrier(meta map[string]any) propagation.MapCarrier {
+	carrier := propagation.MapCarrier{}
+	for k, v := range meta {
+		if s, ok := v.(string); ok {
+			carrier[k] = s
+		}
+	}
+	return carrier
+}
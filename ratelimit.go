@@ -0,0 +1,74 @@
+package main
+
+import (
+	"math"
+	"slices"
+	"sync"
+	"time"
+)
+
+// maxRateBuckets 限流桶数量上限；超出时立即清理，仍超出则淘汰最久未活动的桶
+const maxRateBuckets = 10000
+
+// rateLimiter 按客户端（API Key 名称或 IP）的令牌桶限流；速率与突发数在每次调用时传入，配置变更立即生效
+type rateLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{buckets: map[string]*tokenBucket{}}
+}
+
+// allow 取一个令牌；不足时返回 false 与需要等待的时间
+func (l *rateLimiter) allow(key string, rate float64, burst int, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(rate, burst, now)
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: float64(burst), last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(burst), b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - b.tokens) / rate * float64(time.Second))
+	return false, wait
+}
+
+// sweep 每分钟（桶数超过 maxRateBuckets 时立即）清理已回满的桶，避免客户端 IP 无限累积
+func (l *rateLimiter) sweep(rate float64, burst int, now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute && len(l.buckets) < maxRateBuckets {
+		return
+	}
+	l.lastSweep = now
+	full := time.Duration(float64(burst) / rate * float64(time.Second))
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= full {
+			delete(l.buckets, key)
+		}
+	}
+	if len(l.buckets) < maxRateBuckets {
+		return
+	}
+	// 仍然过多：淘汰最久未活动的桶，保留九成容量
+	keys := make([]string, 0, len(l.buckets))
+	for key := range l.buckets {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(a, b string) int { return l.buckets[a].last.Compare(l.buckets[b].last) })
+	for _, key := range keys[:len(keys)-maxRateBuckets*9/10] {
+		delete(l.buckets, key)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-whisper-mcp/configs"
+)
+
+// 未配置受信代理时，伪造 X-Forwarded-For 不能换出新的限流桶
+func TestRateLimitIgnoresSpoofedForwardedFor(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		proxies []string
+		want    []int
+	}{
+		{"no trusted proxies", nil, []int{http.StatusNotFound, http.StatusTooManyRequests, http.StatusTooManyRequests}},
+		{"trusted proxy", []string{"192.0.2.0/24"}, []int{http.StatusNotFound, http.StatusNotFound, http.StatusNotFound}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := configs.Default()
+			cfg.Limits.RateLimit, cfg.Limits.RateBurst = 0.001, 1
+			cfg.Server.TrustedProxies = tt.proxies
+			configs.Set(cfg)
+			defer configs.Set(configs.Default())
+
+			r := setupRoutes(&AppServer{jobs: NewJobManager(nil, NewWebhookSender(WebhookOptions{}), 1, "")})
+			for i, want := range tt.want {
+				req := httptest.NewRequest(http.MethodGet, "/api/jobs/job_x", nil)
+				req.RemoteAddr = "192.0.2.1:40000"
+				req.Header.Set("X-Forwarded-For", fmt.Sprintf("203.0.113.%d", i+1))
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, req)
+				if w.Code != want {
+					t.Errorf("request %d: status %d, want %d", i, w.Code, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimiterBoundsBuckets(t *testing.T) {
+	l := newRateLimiter()
+	now := time.Now()
+	for i := range maxRateBuckets + 50 {
+		l.allow(fmt.Sprintf("ip:%d", i), 0.001, 1, now.Add(time.Duration(i)*time.Microsecond))
+	}
+	if n := len(l.buckets); n > maxRateBuckets {
+		t.Fatalf("%d buckets, want at most %d", n, maxRateBuckets)
+	}
+	// 淘汰的是最久未活动的桶
+	if _, ok := l.buckets["ip:0"]; ok {
+		t.Error("oldest bucket was kept")
+	}
+	if _, ok := l.buckets[fmt.Sprintf("ip:%d", maxRateBuckets+49)]; !ok {
+		t.Error("newest bucket was evicted")
+	}
+
+	// 已回满的桶在下一次清理时移除
+	l.allow("ip:late", 0.001, 1, now.Add(time.Hour))
+	if n := len(l.buckets); n != 1 {
+		t.Errorf("%d buckets after idle sweep, want 1", n)
+	}
+}
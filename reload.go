@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"go-whisper-mcp/configs"
+	"go-whisper-mcp/pkg"
+	"go-whisper-mcp/pkg/logging"
+	"go-whisper-mcp/pkg/metrics"
+)
+
+// configReloader 重新加载配置文件与模型注册表；启动时显式传入的命令行参数继续覆盖配置文件
+type configReloader struct {
+	mu       sync.Mutex // 串行化并发的重新加载
+	path     string
+	override func(*configs.Config)
+}
+
+// ReloadResult 重新加载结果
+type ReloadResult struct {
+	Trigger         string   `json:"trigger"`                    // signal | api
+	Changed         []string `json:"changed"`                    // 变化的配置项，如 limits.rate_limit
+	RestartRequired []string `json:"restart_required,omitempty"` // 已变化但需重启才生效的项
+	Models          int      `json:"models"`                     // 注册表中登记的模型数
+}
+
+// Reload 读取并校验新配置与注册表，全部成功后才替换；失败时保留当前配置。
+// 新配置只影响之后的请求，进行中的请求继续使用开始时的配置快照与模型
+func (r *configReloader) Reload(trigger string) (*ReloadResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	res, err := r.reload(trigger)
+	metrics.ConfigReloads.WithLabelValues(metrics.Status(err == nil)).Inc()
+	if err != nil {
+		logrus.Errorf("配置重新加载失败（%s），继续使用当前配置: %v", trigger, err)
+		return nil, err
+	}
+	metrics.ConfigLastReloadSuccess.SetToCurrentTime()
+	logrus.Infof("配置已重新加载（%s）: changed=%v models=%d", trigger, res.Changed, res.Models)
+	if len(res.RestartRequired) > 0 {
+		logrus.Warnf("以下配置项需重启后生效: %v", res.RestartRequired)
+	}
+	return res, nil
+}
+
+func (r *configReloader) reload(trigger string) (*ReloadResult, error) {
+	cfg, err := configs.Load(r.path)
+	if err != nil {
+		return nil, err
+	}
+	if r.override != nil {
+		r.override(cfg)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config:\n%w", err)
+	}
+	registry, err := loadModelRegistry(cfg.Models)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &ReloadResult{
+		Trigger: trigger,
+		Changed: configs.Diff(configs.Get(), cfg),
+		Models:  len(registry.List()),
+	}
+	if res.Changed == nil {
+		res.Changed = []string{}
+	}
+	for _, key := range res.Changed {
+		if configs.RequiresRestart(key) {
+			res.RestartRequired = append(res.RestartRequired, key)
+		}
+	}
+
+	pkg.SetModelRegistry(registry)
+	pkg.SetOffline(cfg.Models.Offline)
+	pkg.SetDenoiseModel(cfg.Decode.DenoiseModel)
+	configs.Set(cfg)
+	// 已通过校验；覆盖 PUT /admin/log/level 的临时调整
+	_ = logging.Setup(cfg.Log.Format, cfg.Log.Level)
+	return res, nil
+}
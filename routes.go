@@ -5,19 +5,32 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+	"go-whisper-mcp/configs"
 )
 
 func setupRoutes(a *AppServer) *gin.Engine {
 	// 设置模式
 	gin.SetMode(gin.ReleaseMode)
 	r := gin.New()
-	r.Use(gin.Logger(), gin.Recovery())
+	// 只信任配置的反向代理；否则任何客户端都能伪造 X-Forwarded-For 绕过按 IP 限流（地址已在加载配置时校验）
+	if err := r.SetTrustedProxies(configs.GetTrustedProxies()); err != nil {
+		logrus.Warnf("trusted proxies: %v", err)
+	}
+	r.Use(requestIDMiddleware(), accessLogMiddleware(), gin.Recovery())
+	r.Use(metricsMiddleware(), tracingMiddleware())
 
 	r.Use(errorHandlingMiddleware())
 	r.Use(corsMiddleware())
 
-	// 健康检查
-	r.GET("/health", healthHandler)
+	// 健康检查：/health 与 /health/live 为存活检查，/health/ready 为就绪检查
+	r.GET("/health", handleHealthLive)
+	r.GET("/health/live", handleHealthLive)
+	r.GET("/health/ready", handleHealthReady(a))
+
+	// Prometheus 指标
+	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
 
 	// MCP 端点 - 使用官方 SDK 的 Streamable HTTP Handler
 	mcpHandler := mcp.NewStreamableHTTPHandler(
@@ -28,14 +41,39 @@ func setupRoutes(a *AppServer) *gin.Engine {
 			JSONResponse: true, // 支持 JSON 响应
 		},
 	)
-	r.Any("/mcp", gin.WrapH(mcpHandler))
-	r.Any("/mcp/*path", gin.WrapH(mcpHandler))
+	// 以下接口需要 API Key（配置 auth.api_keys 时）并受限流约束；健康检查与指标不受影响
+	limiter := newRateLimiter()
+	mcpGroup := r.Group("/mcp", authMiddleware(false), rateLimitMiddleware(limiter, false))
+	{
+		mcpGroup.Any("", gin.WrapH(mcpHandler))
+		mcpGroup.Any("/*path", gin.WrapH(mcpHandler))
+	}
+
+	// OpenAI 兼容接口（multipart/form-data，响应格式与 OpenAI 一致）
+	v1 := r.Group("/v1", authMiddleware(true), rateLimitMiddleware(limiter, true))
+	{
+		v1.POST("/audio/transcriptions", handleOpenAIAudio(a, TaskTranscribe))
+		v1.POST("/audio/translations", handleOpenAIAudio(a, TaskTranslate))
+	}
 
 	// REST 组
-	rest := r.Group("/api")
+	rest := r.Group("/api", authMiddleware(false), rateLimitMiddleware(limiter, false))
 	{
 		// 业务 API
 		rest.POST("/transcribe", handleTranscribe(a))
+		rest.POST("/inspect", handleInspect(a))
+		rest.POST("/models/import", handleModelImport(a))
+		rest.GET("/jobs/:id", handleJobGet(a))
+		rest.DELETE("/jobs/:id", handleJobCancel(a))
+	}
+
+	// 管理接口：必须配置 API Key，同样受限流约束
+	admin := r.Group("/admin", adminMiddleware(), authMiddleware(false), rateLimitMiddleware(limiter, false))
+	{
+		admin.GET("/config", handleConfigGet)
+		admin.POST("/reload", handleConfigReload(a))
+		admin.GET("/log/level", handleLogLevelGet)
+		admin.PUT("/log/level", handleLogLevelSet)
 	}
 
 	return r
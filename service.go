@@ -6,17 +6,49 @@ import (
 	"errors"
 	"fmt"
 	"github.com/go-audio/wav"
+	"github.com/sirupsen/logrus"
+	"go-whisper-mcp/configs"
 	"go-whisper-mcp/pkg"
 	"go-whisper-mcp/pkg/downloader"
+	"go-whisper-mcp/pkg/logging"
+	"go-whisper-mcp/pkg/metrics"
+	"go-whisper-mcp/pkg/sink"
+	"go-whisper-mcp/pkg/tracing"
 	"go-whisper-mcp/whisper"
+	"go.opentelemetry.io/otel/attribute"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
+	"unicode"
 )
 
-// WhisperService 小红书业务服务
-type WhisperService struct{}
+// 转录被中止的原因；单项失败不会返回这些错误，整批请求随之结束
+var (
+	ErrCancelled    = errors.New("transcription cancelled") // 调用方取消：HTTP 断开、MCP notifications/cancelled、取消任务
+	ErrTimeout      = errors.New("transcription timed out") // 超过 timeout_s 或 MAX_REQUEST_DURATION
+	ErrShuttingDown = errors.New("server is shutting down") // 关闭中不再接收新的转录；排空超时被中断的转录同时满足 ErrCancelled
+)
+
+// 推理任务
+const (
+	TaskTranscribe = "transcribe"
+	TaskTranslate  = "translate" // 翻译为英文
+)
+
+// WhisperService 转录业务服务
+type WhisperService struct {
+	progress    pkg.ProgressReporter // 默认进度输出（终端进度条或结构化日志）
+	transcripts *TranscriptStore     // 成功的转录结果，按 ID 复用
+	queued      atomic.Int64         // 已接收、尚未完成转录的文件数
+	active      atomic.Int64         // 进行中的 Transcribe 调用数
+	draining    atomic.Bool          // 关闭中：拒绝新的转录
+}
 
 // TranscribeRequest 转换请求
 type TranscribeRequest struct {
@@ -25,14 +57,67 @@ type TranscribeRequest struct {
 	Lang      string   `json:"lang"`
 	Threads   int      `json:"t"` // 并发数量
 	ModelsDir string   `json:"models_dir"`
+
+	// in_paths 中的目录与 glob（支持 **）在服务端展开；以下过滤只作用于展开出的文件
+	Include  []string `json:"include"`   // 文件名或相对路径 glob，为空时目录只收录常见音视频扩展名
+	Exclude  []string `json:"exclude"`   // 命中即排除
+	MaxFiles int      `json:"max_files"` // 展开后的文件数上限，不超过 MAX_INPUT_FILES
+
+	Output      *OutputOptions `json:"output"` // 可选：把转录/字幕文件写到本地目录或对象存储
+	LocalOutput bool           `json:"-"`      // 仅命令行与监听目录设置：output.dest 可为任意本地目录；远程请求只能写 OUTPUT_DIR 下或 s3://
+	NoSidecar   bool           `json:"-"`      // 仅监听目录设置：不读写媒体文件旁的 .json 缓存，收件目录中的同名新文件不会复用旧结果
+
+	TimeoutS int `json:"timeout_s"` // 最长耗时（秒），0 表示只受 MAX_REQUEST_DURATION 限制
+
+	// 解码选项：设置任一项时不读写转录缓存
+	Task           string  `json:"task"`            // transcribe（默认）/ translate
+	Prompt         string  `json:"prompt"`          // 初始提示词，覆盖注册表默认值
+	Temperature    float32 `json:"temperature"`     // 采样温度，覆盖注册表默认值
+	WordTimestamps bool    `json:"word_timestamps"` // 分段附带词级时间戳
+
+	// 音频预处理（降噪、响度归一化、声道/音轨选择、裁剪、变速）：设置时同样不读写转录缓存
+	Preprocess *pkg.AudioFilters `json:"preprocess"`
+
+	// 多音轨媒体：音频流序号、语言代码（如 en / eng）或 all（逐条转录，每条音频流一项结果）；缺省由 ffmpeg 选择
+	AudioStream string `json:"audio_stream"`
+
+	// 异步回调：设置后立即返回任务 ID，结束时向该地址 POST 结果（须在 WEBHOOK_ALLOWED_HOSTS 内）
+	CallbackURL    string `json:"callback_url"`
+	CallbackSecret string `json:"callback_secret"` // 可选，用于 X-Whisper-Signature 的 HMAC-SHA256 签名
+}
+
+// OutputOptions 输出目的地
+type OutputOptions struct {
+	Dest    string   `json:"dest"`    // 本地目录或 s3://bucket/prefix
+	Formats []string `json:"formats"` // json / srt / vtt / txt，缺省 json
+	Suffix  string   `json:"-"`       // 追加在输出文件名之后、扩展名之前；监听目录用来避免覆盖同名文件的结果
 }
 
 // TranscribeResponse 转换返回
 type TranscribeResponse struct {
-	Path      string                          `json:"path"`
+	Path      string                          `json:"path"`                 // 请求中的原始引用（目录/glob 展开后为具体文件）
+	Origin    string                          `json:"origin,omitempty"`     // 展开来源的目录或模式
+	LocalPath string                          `json:"local_path,omitempty"` // 实际转录的本地文件
+	Source    string                          `json:"source,omitempty"`     // 来源类型：local / http / yt-dlp
+	Meta      *downloader.MediaMeta           `json:"meta,omitempty"`       // 来源元数据（标题/作者等）
 	IsSuccess bool                            `json:"is_success"`
+	Error     string                          `json:"error,omitempty"` // 单项失败原因
 	DurationS string                          `json:"duration_s"`
 	Segments  []whisper.TranscribeAudioResult `json:"segments"`
+
+	AudioStream *pkg.AudioStream `json:"audio_stream,omitempty"` // 按 audio_stream 选择时转录的音频流
+
+	Language      string  `json:"language,omitempty"`       // 识别语言（auto 时为检测结果）
+	AudioDuration float64 `json:"audio_duration,omitempty"` // 音频时长（秒）
+
+	// 缓存键的一部分：sidecar 与转录 ID 只对同一模型、同一请求语言复用
+	Model           string `json:"model,omitempty"`            // 模型文件名
+	RequestLanguage string `json:"request_language,omitempty"` // 生效的请求语言（auto 或指定语言）
+
+	Outputs     []string `json:"outputs,omitempty"`      // 已写出的文件（本地路径或 s3:// URI）
+	OutputError string   `json:"output_error,omitempty"` // 写出失败原因（不影响转录结果）
+
+	TranscriptID string `json:"transcript_id,omitempty"` // 成功时的转录 ID，可通过 MCP 资源 transcript://{id} 读取
 }
 
 // TranscribeBatchResponse 批量转换返回
@@ -44,12 +129,51 @@ type TranscribeBatchResponse struct {
 	Results   []*TranscribeResponse `json:"results"`
 }
 
+// Transcripts 转录结果存储
+func (s *WhisperService) Transcripts() *TranscriptStore {
+	return s.transcripts
+}
+
 // NewWhisperService 创建whisper服务实例
 func NewWhisperService() *WhisperService {
-	return &WhisperService{}
+	return &WhisperService{
+		progress:    pkg.DefaultProgress(),
+		transcripts: NewTranscriptStore(configs.GetTranscriptsDir()),
+	}
+}
+
+// QueueDepth 已接收、尚未完成转录的文件数（含正在推理的）
+func (s *WhisperService) QueueDepth() int64 {
+	return s.queued.Load()
+}
+
+// Drain 停止接收新的转录并等待进行中的转录结束；ctx 到期时返回 ctx.Err()，由调用方取消剩余的请求
+func (s *WhisperService) Drain(ctx context.Context) error {
+	s.draining.Store(true)
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for s.active.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
 }
 
-func (s *WhisperService) Transcribe(ctx context.Context, req *TranscribeRequest) (*TranscribeBatchResponse, error) {
+func (s *WhisperService) Transcribe(ctx context.Context, req *TranscribeRequest) (_ *TranscribeBatchResponse, err error) {
+	s.active.Add(1)
+	defer s.active.Add(-1)
+	if s.draining.Load() {
+		return nil, ErrShuttingDown
+	}
+	defer func() {
+		// 排空超时被中断：区别于调用方主动取消，异步任务据此重新排队
+		if errors.Is(err, ErrCancelled) && s.draining.Load() {
+			err = fmt.Errorf("%w: %w", ErrShuttingDown, err)
+		}
+	}()
 
 	inPaths := req.InPaths
 	if len(inPaths) == 0 {
@@ -61,86 +185,367 @@ func (s *WhisperService) Transcribe(ctx context.Context, req *TranscribeRequest)
 			Results:   []*TranscribeResponse{},
 		}, nil
 	}
+	ctx, span := tracing.Start(ctx, "transcribe",
+		attribute.Int("whisper.inputs", len(inPaths)),
+		attribute.String("whisper.model", req.Model),
+		attribute.String("whisper.task", req.Task))
+	defer func() { tracing.End(span, err) }()
+
+	// 超时：请求值与服务端上限取较小者
+	if d := requestTimeout(req.TimeoutS); d > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d)
+		defer cancel()
+	}
+
+	// 配置快照：整个请求使用同一份配置，重新加载只影响之后的请求
+	cfg := configs.Get()
 	modelSpec := req.Model
+	if modelSpec == "" {
+		modelSpec = cfg.Models.Default
+	}
 	lang := req.Lang
 	threads := req.Threads
 	modelsDir := req.ModelsDir
+	if modelsDir == "" {
+		modelsDir = cfg.Models.Dir
+	}
 
-	// 下载资源
-	mediaProcessor := downloader.NewMediaProcessor()
-	inPathFiles, err := mediaProcessor.ProcessMedias(inPaths)
-	if err != nil {
+	// 输出目的地：提前校验，避免转录完成后才发现配置错误
+	var (
+		outSink    sink.Sink
+		outFormats []string
+	)
+	if outSink, outFormats, err = req.outputSink(); err != nil {
 		return nil, err
 	}
 
-	// 1) 模型就绪
-	prog := &pkg.Progress{Enabled: true}
-	modelPath, _, err := pkg.EnsureModelInDirWithProgress(ctx, modelsDir, modelSpec, prog)
+	if req.Task != "" && req.Task != TaskTranscribe && req.Task != TaskTranslate {
+		return nil, fmt.Errorf("invalid task %q (transcribe, translate)", req.Task)
+	}
+	if err := req.Preprocess.Validate(); err != nil {
+		return nil, err
+	}
+	if err := req.validateAudioStream(); err != nil {
+		return nil, err
+	}
+
+	log := logging.FromContext(ctx)
+
+	// 进度：服务默认输出（结构化日志带上请求 ID）+ 调用方（MCP 通知 / 任务状态）通过 ctx 附加的接收者
+	defaultProg := s.progress
+	if lp, ok := defaultProg.(*pkg.LogProgress); ok {
+		defaultProg = lp.WithContext(ctx)
+	}
+	prog := pkg.MultiProgress(defaultProg, pkg.ProgressFromContext(ctx))
+
+	// 展开目录与 glob：组内按路径排序，组间保持 in_paths 顺序
+	maxFiles := cfg.Limits.MaxInputFiles
+	if req.MaxFiles > 0 && req.MaxFiles < maxFiles {
+		maxFiles = req.MaxFiles
+	}
+	refs, err := downloader.ExpandInputs(inPaths, downloader.ExpandOptions{
+		Include:  req.Include,
+		Exclude:  req.Exclude,
+		MaxFiles: maxFiles,
+	})
 	if err != nil {
-		return nil, fmt.Errorf("ensure model: %w", err)
+		return nil, err
 	}
 
-	start := time.Now()
-	results := make([]*TranscribeResponse, 0, len(inPathFiles))
-	for _, path := range inPaths {
-
-		// 判断是否存在json
-		mediaJson := strings.TrimSuffix(path, filepath.Ext(path)) + ".json"
-		// 1. 读取JSON文件
-		if data, err := os.ReadFile(mediaJson); err == nil {
-			// 2. 解析JSON到结构体
-			var rr TranscribeResponse
-			if err := json.Unmarshal(data, &rr); err == nil {
-				results = append(results, &rr)
-				continue
-			}
+	// 解析输入：每个文件一项结果，单项失败不影响其他项
+	mediaProcessor := downloader.NewMediaProcessorWithProgress(prog)
+	rctx, rspan := tracing.Start(ctx, "resolve_inputs", attribute.Int("whisper.files", len(refs)))
+	inputs := mediaProcessor.ResolveExpanded(rctx, refs)
+	rspan.End()
+	if err := contextError(ctx); err != nil {
+		return nil, err
+	}
+	pending := len(inputs)
+	metrics.QueueDepth.Add(float64(pending))
+	s.queued.Add(int64(pending))
+	defer func() {
+		metrics.QueueDepth.Sub(float64(pending))
+		s.queued.Add(-int64(pending))
+	}()
+
+	// 注册表中的自定义模型：补齐默认解码参数并检查语言
+	opts := whisper.DecodeOptions{Language: lang, Threads: threads}
+	if entry, ok := pkg.LookupModel(modelSpec); ok {
+		if opts.Language == "" {
+			opts.Language = entry.Defaults.Language
 		}
+		if opts.Threads <= 0 {
+			opts.Threads = entry.Defaults.Threads
+		}
+		opts.BeamSize = entry.Defaults.BeamSize
+		opts.Temperature = entry.Defaults.Temperature
+		opts.InitialPrompt = entry.Defaults.InitialPrompt
+	}
+	// 其余未指定的参数使用配置中的默认解码参数
+	decode := cfg.Decode
+	if opts.Language == "" {
+		opts.Language = decode.Language
+	}
+	if opts.Threads <= 0 {
+		opts.Threads = decode.Threads
+	}
+	if opts.BeamSize <= 0 {
+		opts.BeamSize = decode.BeamSize
+	}
+	if entry, ok := pkg.LookupModel(modelSpec); ok && !entry.SupportsLanguage(opts.Language) {
+		return nil, fmt.Errorf("model %s does not support language %q (supported: %v)", entry.Name, opts.Language, entry.Languages)
+	}
+	opts.Translate = req.Task == TaskTranslate
+	if req.Prompt != "" {
+		opts.InitialPrompt = req.Prompt
+	}
+	if req.Temperature > 0 {
+		opts.Temperature = req.Temperature
+	}
+	opts.WordTimestamps = req.WordTimestamps
+	// 缓存与转录存储只保存默认解码选项的结果
+	cacheable := !opts.Translate && !opts.WordTimestamps && req.Prompt == "" && req.Temperature == 0 && req.Preprocess.IsZero() && req.AudioStream == ""
+	// sidecar 每个媒体文件只有一份：只由默认模型与默认语言写入，读取时还要核对模型与语言
+	sidecar := cacheable && !req.NoSidecar
+	sidecarWrite := sidecar && (req.Model == "" || req.Model == cfg.Models.Default) && req.Lang == ""
 
-		batch, err := s.transcribeAudioBatch(ctx, modelPath, lang, threads, path)
-		isSuccess := true
+	// 1) 模型就绪（全部输入都解析失败时无需加载模型）
+	var modelPath string
+	if slices.ContainsFunc(inputs, func(in *downloader.ResolvedInput) bool { return in.Err == nil }) {
+		modelPath, _, err = pkg.EnsureModelInDirWithProgress(ctx, modelsDir, modelSpec, prog)
+		if cerr := contextError(ctx); cerr != nil {
+			return nil, cerr
+		}
 		if err != nil {
-			isSuccess = false
-			batch = &TranscribeResponse{}
+			return nil, fmt.Errorf("ensure model: %w", err)
+		}
+	}
+
+	start := time.Now()
+	log.WithFields(logrus.Fields{"files": len(inputs), "model": modelSpec, "task": req.Task}).Info("transcribe start")
+	results := make([]*TranscribeResponse, 0, len(inputs))
+	for _, in := range inputs {
+		var rs []*TranscribeResponse
+		if req.AudioStream != "" && in.Err == nil {
+			rs = s.transcribeStreams(ctx, modelPath, opts, req.Preprocess, in, req.AudioStream)
+		} else {
+			rs = []*TranscribeResponse{s.transcribeInput(ctx, modelPath, opts, req.Preprocess, in, sidecarWrite, sidecar && cfg.Cache.Enabled)}
 		}
-		bb := &TranscribeResponse{
-			Path:      path,
-			IsSuccess: isSuccess,
-			DurationS: batch.DurationS,
-			Segments:  batch.Segments,
+		pending--
+		metrics.QueueDepth.Dec()
+		s.queued.Add(-1)
+		if err := contextError(ctx); err != nil {
+			// 已完成的文件保留在缓存中，重试时直接复用
+			return nil, err
 		}
-		results = append(results, bb)
-		jdata, _ := json.MarshalIndent(bb, "", "  ")
-		_ = os.WriteFile(mediaJson, jdata, 0755)
+		for _, r := range rs {
+			fields := logrus.Fields{"input": r.Path}
+			if r.AudioStream != nil {
+				fields["audio_stream"] = r.AudioStream.Index
+			}
+			if r.IsSuccess {
+				log.WithFields(fields).WithField("duration", r.DurationS).Debug("transcribe file done")
+			} else {
+				log.WithFields(fields).WithField("error", r.Error).Warn("transcribe file failed")
+			}
+			metrics.Transcriptions.WithLabelValues(metrics.Status(r.IsSuccess)).Inc()
+			if cacheable {
+				if err := s.transcripts.Put(r); err != nil {
+					log.Warnf("save transcript %s: %v", r.Path, err)
+				}
+			}
+		}
+		results = append(results, rs...)
+	}
+	if outSink != nil {
+		writeOutputs(ctx, outSink, outFormats, req.Output.Suffix, results)
 	}
+	log.WithFields(logrus.Fields{"files": len(results), "elapsed": time.Since(start).String()}).Info("transcribe done")
 
 	return &TranscribeBatchResponse{
 		ModelPath: modelPath,
-		Language:  lang,
-		Threads:   threads,
+		Language:  opts.Language,
+		Threads:   opts.Threads,
 		DurationS: time.Since(start).String(),
 		Results:   results,
 	}, nil
 
 }
 
-func (s *WhisperService) transcribeAudioBatch(ctx context.Context, modelPath string, lang string, threads int, inPath string) (*TranscribeResponse, error) {
+// transcribeInput 转录单个已解析输入；结果的 Path 始终是请求中的原始引用。
+// cacheable 为 false 时不写 sidecar 缓存，reuse 为 false 时不读取已有的 sidecar；模型或请求语言不同的 sidecar 不复用
+func (s *WhisperService) transcribeInput(ctx context.Context, modelPath string, opts whisper.DecodeOptions, filters *pkg.AudioFilters, in *downloader.ResolvedInput, cacheable, reuse bool) *TranscribeResponse {
+	if in.Err != nil {
+		return &TranscribeResponse{
+			Path:   in.Ref,
+			Origin: in.Origin,
+			Source: string(in.Source),
+			Error:  in.Err.Error(),
+		}
+	}
+
+	// 判断是否存在json（sidecar 与本地文件同目录，下载的文件位于 MEDIA_DIR）
+	mediaJson := strings.TrimSuffix(in.LocalPath, filepath.Ext(in.LocalPath)) + ".json"
+	model := filepath.Base(modelPath)
+	// 1. 读取JSON文件
+	if data, err := os.ReadFile(mediaJson); err == nil && reuse {
+		// 2. 解析JSON到结构体
+		var rr TranscribeResponse
+		if err := json.Unmarshal(data, &rr); err == nil && rr.IsSuccess && rr.Model == model && rr.RequestLanguage == opts.Language {
+			metrics.CacheLookups.WithLabelValues("hit").Inc()
+			rr.Path = in.Ref
+			rr.Origin = in.Origin
+			rr.LocalPath = in.LocalPath
+			rr.Source = string(in.Source)
+			if in.Meta != nil {
+				rr.Meta = in.Meta
+			}
+			return &rr
+		}
+	}
 
-	// 2) 解码到 16k/mono/float32
+	if reuse {
+		metrics.CacheLookups.WithLabelValues("miss").Inc()
+	}
+
+	bb := &TranscribeResponse{
+		Path:            in.Ref,
+		Origin:          in.Origin,
+		LocalPath:       in.LocalPath,
+		Source:          string(in.Source),
+		Meta:            in.Meta,
+		Model:           model,
+		RequestLanguage: opts.Language,
+	}
+	batch, err := s.transcribeAudioBatch(ctx, modelPath, opts, filters, in.LocalPath)
+	if err != nil {
+		bb.Error = err.Error()
+		return bb
+	}
+	bb.IsSuccess = true
+	bb.DurationS = batch.DurationS
+	bb.Segments = batch.Segments
+	bb.Language = batch.Language
+	bb.AudioDuration = batch.AudioDuration
+
+	// 只缓存成功结果，失败的下次请求重新转录
+	if !cacheable {
+		return bb
+	}
+	// 先写临时文件再重命名，中断时不会留下半截的 sidecar
+	jdata, _ := json.MarshalIndent(bb, "", "  ")
+	if err := os.WriteFile(mediaJson+".tmp", jdata, 0644); err == nil {
+		_ = os.Rename(mediaJson+".tmp", mediaJson)
+	}
+	return bb
+}
+
+// Inspect 解析单个输入（本地文件、URL 等，与转录相同）并用 ffprobe 列出其音频流
+func (s *WhisperService) Inspect(ctx context.Context, ref string) (*pkg.MediaInfo, error) {
+	inputs := downloader.NewMediaProcessorWithProgress(s.progress).ResolveInputs(ctx, []string{ref})
+	if len(inputs) == 0 {
+		return nil, fmt.Errorf("no input resolved for %q", ref)
+	}
+	if inputs[0].Err != nil {
+		return nil, inputs[0].Err
+	}
+	return pkg.ProbeMedia(ctx, inputs[0].LocalPath)
+}
+
+// outputSink 解析输出目的地与格式；未设置时返回 nil
+func (req *TranscribeRequest) outputSink() (sink.Sink, []string, error) {
+	if req.Output == nil || req.Output.Dest == "" {
+		return nil, nil, nil
+	}
+	formats, err := ParseFormats(req.Output.Formats)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(formats) == 0 {
+		formats = []string{FormatJSON}
+	}
+	var snk sink.Sink
+	if req.LocalOutput {
+		snk, err = sink.New(req.Output.Dest)
+	} else {
+		snk, err = sink.NewUnder(req.Output.Dest, configs.GetOutputDir())
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("output: %w", err)
+	}
+	return snk, formats, nil
+}
+
+// validateAudioStream 检查 audio_stream；与 preprocess.stream 不能同时设置
+func (req *TranscribeRequest) validateAudioStream() error {
+	if err := pkg.ValidateStreamSelector(req.AudioStream); err != nil {
+		return err
+	}
+	if req.AudioStream != "" && req.Preprocess != nil && req.Preprocess.Stream != nil {
+		return fmt.Errorf("%w: set either audio_stream or preprocess.stream", pkg.ErrInvalidStream)
+	}
+	return nil
+}
+
+// transcribeStreams 用 ffprobe 列出输入的音频流，按 sel 选择后逐条转录；每条音频流一项结果，均不读写缓存。
+// 检查或选择失败时返回一项失败结果
+func (s *WhisperService) transcribeStreams(ctx context.Context, modelPath string, opts whisper.DecodeOptions, filters *pkg.AudioFilters, in *downloader.ResolvedInput, sel string) []*TranscribeResponse {
+	fail := func(err error) []*TranscribeResponse {
+		return []*TranscribeResponse{{
+			Path:      in.Ref,
+			Origin:    in.Origin,
+			LocalPath: in.LocalPath,
+			Source:    string(in.Source),
+			Meta:      in.Meta,
+			Error:     err.Error(),
+		}}
+	}
+	info, err := pkg.ProbeMedia(ctx, in.LocalPath)
+	if err != nil {
+		return fail(err)
+	}
+	streams, err := pkg.SelectAudioStreams(info.AudioStreams, sel)
+	if err != nil {
+		return fail(err)
+	}
+
+	results := make([]*TranscribeResponse, 0, len(streams))
+	for _, st := range streams {
+		f := pkg.AudioFilters{}
+		if filters != nil {
+			f = *filters
+		}
+		f.Stream = &st.Index
+		r := s.transcribeInput(ctx, modelPath, opts, &f, in, false, false)
+		r.AudioStream = &st
+		results = append(results, r)
+		if contextError(ctx) != nil {
+			break
+		}
+	}
+	return results
+}
+
+func (s *WhisperService) transcribeAudioBatch(ctx context.Context, modelPath string, opts whisper.DecodeOptions, filters *pkg.AudioFilters, inPath string) (_ *TranscribeResponse, err error) {
+	ctx, span := tracing.Start(ctx, "transcribe_file", attribute.String("whisper.input", inPath))
+	defer func() { tracing.End(span, err) }()
+
+	// 2) 解码到 16k/mono/float32；需要预处理时一律经过 ffmpeg
 	var data []float32
-	var err error
 	ext := strings.ToLower(filepath.Ext(inPath))
-	if ext == ".wav" {
+	if ext == ".wav" && filters.IsZero() {
 		if s, e := readWavMono16ToF32(inPath); e == nil {
 			data = s
 		} else {
 			var e2 error
-			data, e2 = pkg.DecodeF32(ctx, inPath)
+			data, e2 = pkg.DecodeF32(ctx, inPath, nil)
 			if e2 != nil {
 				return nil, fmt.Errorf("decode wav: %v; ffmpeg fallback: %w", e, e2)
 			}
 		}
 	} else {
-		data, err = pkg.DecodeF32(ctx, inPath)
+		data, err = pkg.DecodeF32(ctx, inPath, filters)
 		if err != nil {
 			return nil, fmt.Errorf("ffmpeg decode: %w", err)
 		}
@@ -148,17 +553,143 @@ func (s *WhisperService) transcribeAudioBatch(ctx context.Context, modelPath str
 
 	start := time.Now()
 	transcribeAudio := whisper.NewTranscribeAudio()
-	result, err := transcribeAudio.Transcribe(modelPath, lang, threads, data)
+	result, err := transcribeAudio.TranscribeDetailed(ctx, modelPath, opts, data)
 	if err != nil {
 		return nil, err
 	}
+	model := filepath.Base(modelPath)
+	metrics.ModelLoadDuration.WithLabelValues(model).Observe(result.LoadDuration.Seconds())
+	if result.Duration > 0 {
+		inference := time.Since(start) - result.LoadDuration
+		metrics.AudioSeconds.WithLabelValues(model).Add(result.Duration)
+		metrics.RealTimeFactor.WithLabelValues(model).Observe(inference.Seconds() / result.Duration)
+	}
+
+	// 裁剪与变速后的时间戳换算回原始音频时间轴
+	if !filters.IsZero() {
+		for i := range result.Segments {
+			sg := &result.Segments[i]
+			sg.StartMs, sg.EndMs = filters.OriginalMs(sg.StartMs), filters.OriginalMs(sg.EndMs)
+			sg.Start = (time.Duration(sg.StartMs) * time.Millisecond).String()
+			sg.End = (time.Duration(sg.EndMs) * time.Millisecond).String()
+			for j := range sg.Words {
+				sg.Words[j].StartMs, sg.Words[j].EndMs = filters.OriginalMs(sg.Words[j].StartMs), filters.OriginalMs(sg.Words[j].EndMs)
+			}
+		}
+	}
 
 	return &TranscribeResponse{
-		DurationS: time.Since(start).String(),
-		Segments:  result,
+		DurationS:     time.Since(start).String(),
+		Segments:      result.Segments,
+		Language:      result.Language,
+		AudioDuration: result.Duration,
 	}, nil
 }
 
+// requestTimeout 计算请求的最长耗时：timeoutS 与 MAX_REQUEST_DURATION 取较小的非零值
+func requestTimeout(timeoutS int) time.Duration {
+	limit := configs.GetMaxRequestDuration()
+	if timeoutS <= 0 {
+		return limit
+	}
+	if d := time.Duration(timeoutS) * time.Second; limit == 0 || d < limit {
+		return d
+	}
+	return limit
+}
+
+// contextError 把 ctx 的结束原因映射为 ErrTimeout / ErrCancelled，未结束时返回 nil
+func contextError(ctx context.Context) error {
+	switch err := ctx.Err(); {
+	case err == nil:
+		return nil
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrTimeout
+	default:
+		return ErrCancelled
+	}
+}
+
+// transcribeErrorCode 关闭 / 取消 / 超时对应的错误码，其他错误返回空串
+func transcribeErrorCode(err error) string {
+	switch {
+	case errors.Is(err, ErrShuttingDown):
+		return "SHUTTING_DOWN"
+	case errors.Is(err, ErrCancelled):
+		return "CANCELLED"
+	case errors.Is(err, ErrTimeout):
+		return "TIMEOUT"
+	}
+	return ""
+}
+
+// writeOutputs 将成功的结果按格式写到输出目的地，文件名取自原始引用加 suffix（批内重名自动加序号）
+func writeOutputs(ctx context.Context, snk sink.Sink, formats []string, suffix string, results []*TranscribeResponse) {
+	ctx, span := tracing.Start(ctx, "write_outputs", attribute.StringSlice("whisper.formats", formats))
+	defer span.End()
+
+	used := map[string]int{}
+	for _, r := range results {
+		if !r.IsSuccess {
+			continue
+		}
+		name := outputBaseName(r) + suffix
+		if n := used[name]; n > 0 {
+			used[name] = n + 1
+			name = fmt.Sprintf("%s-%d", name, n+1)
+		} else {
+			used[name] = 1
+		}
+		var errs []error
+		for _, f := range formats {
+			data, ct, err := FormatTranscript(r, f)
+			if err == nil {
+				var loc string
+				if loc, err = snk.Put(ctx, name+"."+f, data, ct); err == nil {
+					r.Outputs = append(r.Outputs, loc)
+				}
+			}
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if err := errors.Join(errs...); err != nil {
+			r.OutputError = err.Error()
+		}
+	}
+}
+
+// outputBaseName 由原始引用生成输出文件名（不含扩展名）
+func outputBaseName(r *TranscribeResponse) string {
+	name := r.Path
+	if r.Meta != nil && r.Meta.Title != "" {
+		name = r.Meta.Title
+	} else {
+		if u, err := url.Parse(r.Path); err == nil && u.Scheme != "" && len(u.Scheme) > 1 {
+			name = u.Host + u.Path
+		}
+		name = path.Base(filepath.ToSlash(name))
+		name = strings.TrimSuffix(name, path.Ext(name))
+	}
+	// 多音轨逐条转录：同一输入的各条音频流分别命名
+	if st := r.AudioStream; st != nil {
+		name += ".a" + strconv.Itoa(st.Index)
+		if st.Language != "" {
+			name += "." + st.Language
+		}
+	}
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_' || r == '.' {
+			return r
+		}
+		return '_'
+	}, strings.TrimSpace(name))
+	if name == "" || name == "." || name == ".." {
+		name = "transcript"
+	}
+	return name
+}
+
 // WAV 专用路径（必须 16k/mono/16-bit）
 func readWavMono16ToF32(path string) ([]float32, error) {
 	f, err := os.Open(path)
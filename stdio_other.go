@@ -0,0 +1,10 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// reserveStdout 非 unix 平台无法重定向文件描述符，直接使用标准输出
+func reserveStdout() (*os.File, error) {
+	return os.Stdout, nil
+}
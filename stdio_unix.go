@@ -0,0 +1,24 @@
+//go:build unix
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// reserveStdout 为 JSON-RPC 保留真正的标准输出，并把文件描述符 1 指向 stderr，
+// 防止 native 库或子进程的 printf 混入 stdio 协议流
+func reserveStdout() (*os.File, error) {
+	fd, err := unix.Dup(int(os.Stdout.Fd()))
+	if err != nil {
+		return nil, fmt.Errorf("dup stdout: %w", err)
+	}
+	if err := unix.Dup2(int(os.Stderr.Fd()), int(os.Stdout.Fd())); err != nil {
+		_ = unix.Close(fd)
+		return nil, fmt.Errorf("redirect stdout: %w", err)
+	}
+	return os.NewFile(uintptr(fd), "/dev/stdout"), nil
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go-whisper-mcp/configs"
+)
+
+// ErrTranscriptNotFound 转录记录不存在
+var ErrTranscriptNotFound = errors.New("transcript not found")
+
+// TranscriptInfo 转录记录摘要（用于资源列表）
+type TranscriptInfo struct {
+	ID        string    `json:"id"`
+	Path      string    `json:"path"`
+	Title     string    `json:"title,omitempty"`
+	Model     string    `json:"model,omitempty"`
+	Segments  int       `json:"segments"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TranscriptStore 按 ID 持久化成功的转录结果（<dir>/<id>.json），供 MCP 资源与提示词复用，无需重新推理
+type TranscriptStore struct {
+	dir string
+
+	mu        sync.RWMutex
+	listeners []func(*TranscriptInfo)
+}
+
+// NewTranscriptStore 创建转录存储
+func NewTranscriptStore(dir string) *TranscriptStore {
+	return &TranscriptStore{dir: dir}
+}
+
+// TranscriptID 由本地文件路径、大小、修改时间、模型文件与请求语言生成稳定 ID；
+// 同一文件以相同模型和语言重复转录得到同一 ID，换模型或语言不会覆盖之前的结果
+func TranscriptID(localPath, model, lang string) string {
+	key := localPath
+	if abs, err := filepath.Abs(localPath); err == nil {
+		key = abs
+	}
+	if fi, err := os.Stat(localPath); err == nil {
+		key = fmt.Sprintf("%s\x00%d\x00%d", key, fi.Size(), fi.ModTime().UnixNano())
+	}
+	key = fmt.Sprintf("%s\x00%s\x00%s", key, model, lang)
+	sum := sha256.Sum256([]byte(key))
+	return fmt.Sprintf("tr_%x", sum[:8])
+}
+
+// OnPut 注册新转录写入后的回调
+func (s *TranscriptStore) OnPut(fn func(*TranscriptInfo)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, fn)
+}
+
+// Put 保存成功的转录结果并填充 r.TranscriptID；
+// 内容与已保存的一致时（如重复请求命中缓存）只刷新使用时间，不重写也不通知
+func (s *TranscriptStore) Put(r *TranscribeResponse) error {
+	if !r.IsSuccess || r.LocalPath == "" {
+		return nil
+	}
+	r.TranscriptID = TranscriptID(r.LocalPath, r.Model, r.RequestLanguage)
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	p := s.path(r.TranscriptID)
+	if old, err := os.ReadFile(p); err == nil && bytes.Equal(old, data) {
+		now := time.Now()
+		_ = os.Chtimes(p, now, now)
+		return nil
+	}
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return err
+	}
+	tmp := p + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, p); err != nil {
+		return err
+	}
+	s.prune(configs.GetTranscriptsMax())
+
+	info := transcriptInfo(r, time.Now())
+	s.mu.RLock()
+	listeners := slices.Clone(s.listeners)
+	s.mu.RUnlock()
+	for _, fn := range listeners {
+		fn(info)
+	}
+	return nil
+}
+
+// prune 保存数量超过 max 时按修改时间删除最久未使用的结果；max <= 0 不限制
+func (s *TranscriptStore) prune(max int) {
+	if max <= 0 {
+		return
+	}
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return
+	}
+	type stored struct {
+		path string
+		mod  time.Time
+	}
+	var files []stored
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), ".json")
+		if !ok || !validTranscriptID(id) {
+			continue
+		}
+		if fi, err := e.Info(); err == nil {
+			files = append(files, stored{s.path(id), fi.ModTime()})
+		}
+	}
+	if len(files) <= max {
+		return
+	}
+	slices.SortFunc(files, func(a, b stored) int { return a.mod.Compare(b.mod) })
+	for _, f := range files[:len(files)-max] {
+		if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+			logrus.Warnf("prune transcript %s: %v", f.path, err)
+		}
+	}
+}
+
+// Get 按 ID 读取转录结果
+func (s *TranscriptStore) Get(id string) (*TranscribeResponse, error) {
+	if !validTranscriptID(id) {
+		return nil, fmt.Errorf("%w: %s", ErrTranscriptNotFound, id)
+	}
+	data, err := os.ReadFile(s.path(id))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %s", ErrTranscriptNotFound, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var r TranscribeResponse
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("parse transcript %s: %w", id, err)
+	}
+	return &r, nil
+}
+
+// List 列出全部转录记录，最新的在前
+func (s *TranscriptStore) List() ([]*TranscriptInfo, error) {
+	entries, err := os.ReadDir(s.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var out []*TranscriptInfo
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), ".json")
+		if !ok || !validTranscriptID(id) {
+			continue
+		}
+		r, err := s.Get(id)
+		if err != nil {
+			continue
+		}
+		var mod time.Time
+		if fi, err := e.Info(); err == nil {
+			mod = fi.ModTime()
+		}
+		out = append(out, transcriptInfo(r, mod))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].UpdatedAt.After(out[j].UpdatedAt) })
+	return out, nil
+}
+
+func (s *TranscriptStore) path(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
+
+func transcriptInfo(r *TranscribeResponse, updated time.Time) *TranscriptInfo {
+	info := &TranscriptInfo{
+		ID:        r.TranscriptID,
+		Path:      r.Path,
+		Model:     r.Model,
+		Segments:  len(r.Segments),
+		UpdatedAt: updated,
+	}
+	if r.Meta != nil {
+		info.Title = r.Meta.Title
+	}
+	return info
+}
+
+// validTranscriptID 防止通过 ID 访问存储目录以外的文件
+func validTranscriptID(id string) bool {
+	if !strings.HasPrefix(id, "tr_") || len(id) > 64 {
+		return false
+	}
+	for _, c := range id[3:] {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
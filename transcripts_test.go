@@ -0,0 +1,146 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go-whisper-mcp/configs"
+	"go-whisper-mcp/whisper"
+)
+
+func TestTranscriptIDIncludesModelAndLanguage(t *testing.T) {
+	media := filepath.Join(t.TempDir(), "talk.wav")
+	if err := os.WriteFile(media, []byte("RIFF"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	base := TranscriptID(media, "ggml-tiny.bin", "zh")
+	if again := TranscriptID(media, "ggml-tiny.bin", "zh"); again != base {
+		t.Errorf("ID not stable: %s != %s", again, base)
+	}
+	for _, other := range []string{
+		TranscriptID(media, "ggml-large-v3.bin", "zh"),
+		TranscriptID(media, "ggml-tiny.bin", "auto"),
+		TranscriptID(media, "ggml-tiny.bin", ""),
+	} {
+		if other == base {
+			t.Errorf("different model/language produced the same ID %s", base)
+		}
+	}
+}
+
+func TestTranscriptStoreKeepsResultsPerModel(t *testing.T) {
+	store := NewTranscriptStore(t.TempDir())
+	media := filepath.Join(t.TempDir(), "talk.wav")
+	if err := os.WriteFile(media, []byte("RIFF"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	put := func(model, lang, text string) *TranscribeResponse {
+		r := &TranscribeResponse{
+			Path:            media,
+			LocalPath:       media,
+			IsSuccess:       true,
+			Segments:        []whisper.TranscribeAudioResult{{Text: text}},
+			Model:           model,
+			RequestLanguage: lang,
+		}
+		if err := store.Put(r); err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+	tiny := put("ggml-tiny.bin", "zh", "tiny")
+	large := put("ggml-large-v3.bin", "auto", "large")
+	if tiny.TranscriptID == large.TranscriptID {
+		t.Fatal("results for different models share an ID")
+	}
+
+	for _, want := range []*TranscribeResponse{tiny, large} {
+		got, err := store.Get(want.TranscriptID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Model != want.Model || got.Segments[0].Text != want.Segments[0].Text {
+			t.Errorf("Get(%s) = model %q text %q, want %q %q", want.TranscriptID, got.Model, got.Segments[0].Text, want.Model, want.Segments[0].Text)
+		}
+	}
+	if list, err := store.List(); err != nil || len(list) != 2 {
+		t.Errorf("List = %d entries, %v; want 2", len(list), err)
+	}
+}
+
+func TestTranscriptStoreSkipsUnchangedPut(t *testing.T) {
+	store := NewTranscriptStore(t.TempDir())
+	var notified int
+	store.OnPut(func(*TranscriptInfo) { notified++ })
+	media := filepath.Join(t.TempDir(), "talk.wav")
+	if err := os.WriteFile(media, []byte("RIFF"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	put := func(text string) {
+		t.Helper()
+		r := &TranscribeResponse{Path: media, LocalPath: media, IsSuccess: true, Model: "ggml-tiny.bin", Segments: []whisper.TranscribeAudioResult{{Text: text}}}
+		if err := store.Put(r); err != nil {
+			t.Fatal(err)
+		}
+		if r.TranscriptID == "" {
+			t.Error("TranscriptID not set")
+		}
+	}
+	put("hello")
+	put("hello") // 命中缓存的重复请求
+	if notified != 1 {
+		t.Errorf("notified %d times for an unchanged transcript, want 1", notified)
+	}
+	put("hello again")
+	if notified != 2 {
+		t.Errorf("notified %d times after the transcript changed, want 2", notified)
+	}
+}
+
+func TestTranscriptStorePrunesLeastRecentlyUsed(t *testing.T) {
+	cfg := configs.Default()
+	cfg.Cache.TranscriptsMax = 2
+	configs.Set(cfg)
+	defer configs.Set(configs.Default())
+
+	store := NewTranscriptStore(t.TempDir())
+	media := filepath.Join(t.TempDir(), "talk.wav")
+	if err := os.WriteFile(media, []byte("RIFF"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	base := time.Now().Add(-time.Hour)
+	put := func(model string, age int) *TranscribeResponse {
+		t.Helper()
+		r := &TranscribeResponse{Path: media, LocalPath: media, IsSuccess: true, Model: model, Segments: []whisper.TranscribeAudioResult{{Text: model}}}
+		if err := store.Put(r); err != nil {
+			t.Fatal(err)
+		}
+		mod := base.Add(time.Duration(age) * time.Minute)
+		if err := os.Chtimes(store.path(r.TranscriptID), mod, mod); err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+	a := put("a.bin", 0)
+	b := put("b.bin", 1)
+	// 重复保存 a：刷新使用时间，b 成为最久未使用的
+	if err := store.Put(a); err != nil {
+		t.Fatal(err)
+	}
+	c := put("c.bin", 2)
+
+	for _, tt := range []struct {
+		r    *TranscribeResponse
+		kept bool
+	}{{a, true}, {b, false}, {c, true}} {
+		_, err := store.Get(tt.r.TranscriptID)
+		if kept := err == nil; kept != tt.kept {
+			t.Errorf("%s kept = %v, want %v (%v)", tt.r.Model, kept, tt.kept, err)
+		}
+	}
+}
@@ -1,5 +1,7 @@
 package main
 
+import "go-whisper-mcp/pkg"
+
 // HTTP API 响应类型
 
 // ErrorResponse 错误响应
@@ -16,17 +18,19 @@ type SuccessResponse struct {
 	Message string `json:"message,omitempty"`
 }
 
-// MCPToolResult MCP 工具结果
-type MCPToolResult struct {
-	Content           []MCPContent `json:"content"`
-	StructuredContent any          `json:"structuredContent,omitempty"`
-	IsError           bool         `json:"isError,omitempty"`
+// InspectRequest 媒体检查请求
+type InspectRequest struct {
+	InPath string `json:"in_path" binding:"required"` // 与 /transcribe 的 in_paths 单项相同：本地文件、URL 等
 }
 
-// MCPContent MCP 内容
-type MCPContent struct {
-	Type     string `json:"type"`
-	Text     string `json:"text"`
-	MimeType string `json:"mimeType"`
-	Data     string `json:"data"`
+// ModelImportRequest 导入自定义模型请求
+type ModelImportRequest struct {
+	SrcPath   string            `json:"src_path" binding:"required"` // MODELS_IMPORT_DIR 下的 ggml/gguf 文件（相对路径或其中的绝对路径）
+	Name      string            `json:"name"`                        // 别名，缺省为文件名
+	Filename  string            `json:"filename"`                    // 保存到 MODELS_DIR 的文件名，缺省同源文件
+	URL       string            `json:"url"`                         // 可选下载地址
+	Languages []string          `json:"languages"`
+	SHA256    string            `json:"sha256"` // 可选，提供时校验
+	Defaults  pkg.ModelDefaults `json:"defaults"`
+	Overwrite bool              `json:"overwrite"` // 替换同名文件与注册表条目
 }
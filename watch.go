@@ -0,0 +1,412 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/sirupsen/logrus"
+	"go-whisper-mcp/pkg/downloader"
+	"go-whisper-mcp/pkg/logging"
+)
+
+// WatchOptions 监听目录模式的配置
+type WatchOptions struct {
+	Dirs      []string      // 监听的目录（不递归）
+	OutDir    string        // 转录结果输出目录
+	Formats   []string      // 输出格式，缺省 json
+	DoneDir   string        // 处理成功后移入的目录，为空则保留原地
+	ErrorDir  string        // 处理失败后移入的目录，为空则保留原地
+	StatePath string        // 处理记录文件，缺省 <OutDir>/.watch-state.json
+	StableFor time.Duration // 文件大小与修改时间保持不变多久视为写入完成，缺省 3s
+
+	Model     string // 缺省使用当前配置的 models.default
+	Lang      string
+	Threads   int
+	ModelsDir string // 缺省使用当前配置的 models.dir
+}
+
+// watchRecord 单个文件的处理记录，用于重启后跳过已处理的文件
+type watchRecord struct {
+	Size     int64     `json:"size"`
+	ModTime  time.Time `json:"mod_time"`
+	Status   string    `json:"status"` // done / error
+	Error    string    `json:"error,omitempty"`
+	Outputs  []string  `json:"outputs,omitempty"`
+	Finished time.Time `json:"finished"`
+}
+
+// pendingFile 等待写入完成的文件
+type pendingFile struct {
+	size    int64
+	modTime time.Time
+	since   time.Time // 最近一次观察到变化的时间
+}
+
+// Watcher 监听目录，把写入完成的媒体文件提交给 WhisperService
+type Watcher struct {
+	svc  *WhisperService
+	opts WatchOptions
+
+	mu      sync.Mutex
+	state   map[string]*watchRecord
+	pending map[string]*pendingFile
+	queued  map[string]bool
+	queue   chan string
+	abort   context.CancelFunc // 中断正在处理的文件，Run 启动后设置
+
+	stop chan struct{} // Drain 时关闭：不再领取新文件
+	done chan struct{} // Run 返回时关闭
+}
+
+// NewWatcher 创建目录监听器，加载历史处理记录
+func NewWatcher(svc *WhisperService, opts WatchOptions) (*Watcher, error) {
+	if len(opts.Dirs) == 0 {
+		return nil, errors.New("watch: no directories")
+	}
+	if opts.OutDir == "" {
+		return nil, errors.New("watch: output directory required")
+	}
+	formats, err := ParseFormats(opts.Formats)
+	if err != nil {
+		return nil, err
+	}
+	if len(formats) == 0 {
+		formats = []string{FormatJSON}
+	}
+	opts.Formats = formats
+	if opts.StatePath == "" {
+		opts.StatePath = filepath.Join(opts.OutDir, ".watch-state.json")
+	}
+	if opts.StableFor <= 0 {
+		opts.StableFor = 3 * time.Second
+	}
+	for i, d := range opts.Dirs {
+		abs, err := filepath.Abs(d)
+		if err != nil {
+			return nil, err
+		}
+		opts.Dirs[i] = abs
+	}
+	for _, d := range []string{opts.OutDir, opts.DoneDir, opts.ErrorDir} {
+		if d == "" {
+			continue
+		}
+		if err := os.MkdirAll(d, 0755); err != nil {
+			return nil, fmt.Errorf("watch: %w", err)
+		}
+	}
+
+	w := &Watcher{
+		svc:     svc,
+		opts:    opts,
+		state:   map[string]*watchRecord{},
+		pending: map[string]*pendingFile{},
+		queued:  map[string]bool{},
+		queue:   make(chan string, 1024),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	if data, err := os.ReadFile(opts.StatePath); err == nil {
+		if err := json.Unmarshal(data, &w.state); err != nil {
+			return nil, fmt.Errorf("watch: parse %s: %w", opts.StatePath, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("watch: %w", err)
+	}
+	return w, nil
+}
+
+// Run 开始监听，直到 ctx 结束或 Drain；启动时会补扫目录中已有的文件
+func (w *Watcher) Run(ctx context.Context) error {
+	defer close(w.done)
+	work, abort := context.WithCancel(ctx)
+	defer abort()
+	w.mu.Lock()
+	w.abort = abort
+	w.mu.Unlock()
+
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("watch: %w", err)
+	}
+	defer fw.Close()
+	for _, d := range w.opts.Dirs {
+		if err := fw.Add(d); err != nil {
+			return fmt.Errorf("watch %s: %w", d, err)
+		}
+		logrus.Infof("监听目录: %s", d)
+	}
+
+	// 重启期间放入的文件
+	for _, d := range w.opts.Dirs {
+		entries, err := os.ReadDir(d)
+		if err != nil {
+			return fmt.Errorf("watch %s: %w", d, err)
+		}
+		for _, e := range entries {
+			w.observe(filepath.Join(d, e.Name()))
+		}
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		w.worker(work)
+	}()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return nil
+		case <-w.stop:
+			wg.Wait()
+			return nil
+		case ev, ok := <-fw.Events:
+			if !ok {
+				wg.Wait()
+				return nil
+			}
+			if ev.Has(fsnotify.Create) || ev.Has(fsnotify.Write) || ev.Has(fsnotify.Rename) || ev.Has(fsnotify.Chmod) {
+				w.observe(ev.Name)
+			}
+		case err, ok := <-fw.Errors:
+			if ok {
+				logrus.Warnf("watch: %v", err)
+			}
+		case <-ticker.C:
+			w.promoteStable()
+		}
+	}
+}
+
+// observe 记录文件的最新状态；只接受常见音视频扩展名，跳过隐藏与临时文件
+func (w *Watcher) observe(p string) {
+	name := filepath.Base(p)
+	if strings.HasPrefix(name, ".") || strings.HasSuffix(name, ".part") {
+		return
+	}
+	if !slices.Contains(downloader.DefaultMediaExts, strings.ToLower(filepath.Ext(name))) {
+		return
+	}
+	fi, err := os.Stat(p)
+	if err != nil || !fi.Mode().IsRegular() {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.queued[p] {
+		return
+	}
+	if rec, ok := w.state[p]; ok && rec.Size == fi.Size() && rec.ModTime.Equal(fi.ModTime()) {
+		return
+	}
+	pf, ok := w.pending[p]
+	if !ok || pf.size != fi.Size() || !pf.modTime.Equal(fi.ModTime()) {
+		w.pending[p] = &pendingFile{size: fi.Size(), modTime: fi.ModTime(), since: time.Now()}
+	}
+}
+
+// promoteStable 大小与修改时间在 StableFor 内不再变化的文件进入处理队列
+func (w *Watcher) promoteStable() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for p, pf := range w.pending {
+		fi, err := os.Stat(p)
+		if err != nil {
+			delete(w.pending, p)
+			continue
+		}
+		if fi.Size() != pf.size || !fi.ModTime().Equal(pf.modTime) {
+			pf.size, pf.modTime, pf.since = fi.Size(), fi.ModTime(), time.Now()
+			continue
+		}
+		if time.Since(pf.since) < w.opts.StableFor {
+			continue
+		}
+		select {
+		case w.queue <- p:
+			delete(w.pending, p)
+			w.queued[p] = true
+		default:
+			// 队列已满，下个周期再试
+		}
+	}
+}
+
+// Drain 停止领取新文件并等待正在处理的文件完成；ctx 到期时中断处理，该文件在重启后重新处理
+func (w *Watcher) Drain(ctx context.Context) {
+	close(w.stop)
+	select {
+	case <-w.done:
+	case <-ctx.Done():
+		w.mu.Lock()
+		abort := w.abort
+		w.mu.Unlock()
+		if abort != nil {
+			abort()
+		}
+		<-w.done
+	}
+}
+
+func (w *Watcher) worker(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-w.stop:
+			return
+		case p := <-w.queue:
+			w.process(ctx, p)
+			w.mu.Lock()
+			delete(w.queued, p)
+			w.mu.Unlock()
+		}
+	}
+}
+
+// process 转录单个文件，写出结果并移动到 done / error 目录
+func (w *Watcher) process(ctx context.Context, p string) {
+	fi, err := os.Stat(p)
+	if err != nil {
+		return
+	}
+	// 每个文件一个请求 ID，串联下载、解码与推理日志
+	ctx = logging.WithRequestID(ctx, logging.NewRequestID())
+	log := logging.FromContext(ctx)
+	log.Infof("watch: 开始处理 %s", p)
+
+	rec := &watchRecord{Size: fi.Size(), ModTime: fi.ModTime()}
+	// 不在收件目录写 sidecar：文件移走后 sidecar 会留下，被之后放入的同名文件误用
+	out, err := w.svc.Transcribe(ctx, &TranscribeRequest{
+		InPaths:     []string{p},
+		Model:       w.opts.Model,
+		Lang:        w.opts.Lang,
+		Threads:     w.opts.Threads,
+		ModelsDir:   w.opts.ModelsDir,
+		Output:      &OutputOptions{Dest: w.opts.OutDir, Formats: w.opts.Formats, Suffix: w.outputSuffix(p)},
+		LocalOutput: true,
+		NoSidecar:   true,
+	})
+	if ctx.Err() != nil || errors.Is(err, ErrShuttingDown) {
+		// 关闭过程中被中断或未开始：不记录，重启后重新处理
+		return
+	}
+	switch {
+	case err != nil:
+		rec.Error = err.Error()
+	case len(out.Results) == 0:
+		rec.Error = "no result"
+	case !out.Results[0].IsSuccess:
+		rec.Error = out.Results[0].Error
+	case out.Results[0].OutputError != "":
+		rec.Error = out.Results[0].OutputError
+	default:
+		rec.Outputs = out.Results[0].Outputs
+	}
+
+	rec.Status = "done"
+	dest := w.opts.DoneDir
+	if rec.Error != "" {
+		rec.Status, dest = "error", w.opts.ErrorDir
+		log.Errorf("watch: 处理失败 %s: %s", p, rec.Error)
+	} else {
+		log.Infof("watch: 处理完成 %s -> %v", p, rec.Outputs)
+	}
+	rec.Finished = time.Now()
+
+	if dest != "" {
+		if err := moveFile(p, filepath.Join(dest, filepath.Base(p))); err != nil {
+			logrus.Warnf("watch: move %s: %v", p, err)
+		}
+	}
+
+	w.mu.Lock()
+	w.state[p] = rec
+	err = w.saveState()
+	w.mu.Unlock()
+	if err != nil {
+		logrus.Warnf("watch: save state: %v", err)
+	}
+}
+
+// outputSuffix 输出目录中已有同名结果时追加时间戳（与 moveFile 一致），不覆盖之前文件的结果
+func (w *Watcher) outputSuffix(p string) string {
+	name := outputBaseName(&TranscribeResponse{Path: p})
+	taken := func(suffix string) bool {
+		for _, f := range w.opts.Formats {
+			if _, err := os.Stat(filepath.Join(w.opts.OutDir, name+suffix+"."+f)); err == nil {
+				return true
+			}
+		}
+		return false
+	}
+	if !taken("") {
+		return ""
+	}
+	ts := time.Now().Unix()
+	suffix := fmt.Sprintf(".%d", ts)
+	for i := 2; taken(suffix); i++ {
+		suffix = fmt.Sprintf(".%d-%d", ts, i)
+	}
+	return suffix
+}
+
+// saveState 原子写入处理记录（调用方持有锁）
+func (w *Watcher) saveState() error {
+	data, err := json.MarshalIndent(w.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := w.opts.StatePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, w.opts.StatePath)
+}
+
+// moveFile 移动文件；跨文件系统时退化为复制后删除，目标已存在时追加时间戳
+func moveFile(src, dst string) error {
+	if _, err := os.Stat(dst); err == nil {
+		ext := filepath.Ext(dst)
+		dst = fmt.Sprintf("%s.%d%s", strings.TrimSuffix(dst, ext), time.Now().Unix(), ext)
+	}
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		_ = os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		_ = os.Remove(dst)
+		return err
+	}
+	return os.Remove(src)
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 回调请求头
+const (
+	WebhookSignatureHeader = "X-Whisper-Signature" // sha256=<hex(HMAC-SHA256(secret, timestamp + "." + body))>
+	WebhookTimestampHeader = "X-Whisper-Timestamp" // Unix 秒
+	WebhookEventHeader     = "X-Whisper-Event"
+	WebhookDeliveryHeader  = "X-Whisper-Delivery" // 任务 ID
+)
+
+// ErrWebhookNotAllowed 回调地址不在允许列表内
+var ErrWebhookNotAllowed = errors.New("callback url not allowed")
+
+// WebhookOptions 回调投递选项
+type WebhookOptions struct {
+	AllowedHosts []string      // 允许的主机（域名后缀匹配，可带端口），为空时拒绝所有回调
+	MaxAttempts  int           // 最大投递次数，默认 5
+	Timeout      time.Duration // 单次投递超时，默认 10s
+	BaseDelay    time.Duration // 首次重试间隔，之后指数增长，默认 1s
+	MaxDelay     time.Duration // 重试间隔上限，默认 1m
+}
+
+// WebhookAttempt 单次投递记录
+type WebhookAttempt struct {
+	At         time.Time `json:"at"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	DurationMs int64     `json:"duration_ms"`
+}
+
+// WebhookDelivery 回调投递状态
+type WebhookDelivery struct {
+	URL       string           `json:"url"`
+	Delivered bool             `json:"delivered"`
+	Attempts  []WebhookAttempt `json:"attempts"`
+}
+
+// WebhookSender 回调投递：HMAC 签名、指数退避重试、主机白名单
+type WebhookSender struct {
+	opts   WebhookOptions
+	client *http.Client
+}
+
+// NewWebhookSender 创建回调投递器
+func NewWebhookSender(opts WebhookOptions) *WebhookSender {
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 5
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	if opts.BaseDelay <= 0 {
+		opts.BaseDelay = time.Second
+	}
+	if opts.MaxDelay <= 0 {
+		opts.MaxDelay = time.Minute
+	}
+	return &WebhookSender{
+		opts: opts,
+		client: &http.Client{
+			Timeout: opts.Timeout,
+			// 不跟随重定向，避免绕过白名单
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+	}
+}
+
+// CheckURL 校验回调地址：http(s) 且主机在允许列表内
+func (s *WebhookSender) CheckURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrWebhookNotAllowed, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%w: scheme %q", ErrWebhookNotAllowed, u.Scheme)
+	}
+	if u.User != nil {
+		return fmt.Errorf("%w: credentials in url", ErrWebhookNotAllowed)
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range s.opts.AllowedHosts {
+		allowed = strings.ToLower(strings.TrimSpace(allowed))
+		h, port, err := net.SplitHostPort(allowed)
+		if err != nil {
+			h, port = allowed, ""
+		}
+		if port != "" && port != u.Port() {
+			continue
+		}
+		if host == h || strings.HasSuffix(host, "."+h) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrWebhookNotAllowed, u.Host)
+}
+
+// SignWebhook 计算签名头的值
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Deliver 投递回调直到成功（2xx）、遇到不可重试的状态码或次数用尽；每次尝试后调用 onAttempt
+func (s *WebhookSender) Deliver(ctx context.Context, rawURL, secret, event, deliveryID string, body []byte, onAttempt func(WebhookAttempt)) error {
+	if err := s.CheckURL(rawURL); err != nil {
+		return err
+	}
+
+	delay := s.opts.BaseDelay
+	var lastErr error
+	for attempt := 1; attempt <= s.opts.MaxAttempts; attempt++ {
+		retry, err := s.post(ctx, rawURL, secret, event, deliveryID, body, onAttempt)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		if !retry || attempt == s.opts.MaxAttempts {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, s.opts.MaxDelay)
+	}
+	return fmt.Errorf("webhook delivery failed: %w", lastErr)
+}
+
+// post 单次投递，返回是否值得重试
+func (s *WebhookSender) post(ctx context.Context, rawURL, secret, event, deliveryID string, body []byte, onAttempt func(WebhookAttempt)) (bool, error) {
+	start := time.Now()
+	rec := WebhookAttempt{At: start}
+	defer func() {
+		rec.DurationMs = time.Since(start).Milliseconds()
+		if onAttempt != nil {
+			onAttempt(rec)
+		}
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rawURL, bytes.NewReader(body))
+	if err != nil {
+		rec.Error = err.Error()
+		return false, err
+	}
+	ts := strconv.FormatInt(start.Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "go-whisper-mcp-webhook")
+	req.Header.Set(WebhookEventHeader, event)
+	req.Header.Set(WebhookDeliveryHeader, deliveryID)
+	req.Header.Set(WebhookTimestampHeader, ts)
+	if secret != "" {
+		req.Header.Set(WebhookSignatureHeader, SignWebhook(secret, ts, body))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		rec.Error = err.Error()
+		return ctx.Err() == nil, err
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+	rec.StatusCode = resp.StatusCode
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	err = fmt.Errorf("unexpected status %s", resp.Status)
+	rec.Error = err.Error()
+	// 5xx、408、429 可重试，其余 4xx/3xx 视为接收方拒绝
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests
+	return retry, err
+}
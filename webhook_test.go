@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testSender(maxAttempts int) *WebhookSender {
+	return NewWebhookSender(WebhookOptions{
+		AllowedHosts: []string{"127.0.0.1"},
+		MaxAttempts:  maxAttempts,
+		Timeout:      2 * time.Second,
+		BaseDelay:    20 * time.Millisecond,
+		MaxDelay:     50 * time.Millisecond,
+	})
+}
+
+func TestWebhookSignature(t *testing.T) {
+	type received struct {
+		header http.Header
+		body   []byte
+	}
+	got := make(chan received, 2)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got <- received{r.Header.Clone(), body}
+	}))
+	defer srv.Close()
+
+	body := []byte(`{"job_id":"job-1","event":"job.completed"}`)
+	if err := testSender(1).Deliver(context.Background(), srv.URL+"/hook", "s3cret", "job.completed", "job-1", body, nil); err != nil {
+		t.Fatal(err)
+	}
+	r := <-got
+	if string(r.body) != string(body) {
+		t.Errorf("body = %s", r.body)
+	}
+	ts := r.header.Get(WebhookTimestampHeader)
+	if sec, err := strconv.ParseInt(ts, 10, 64); err != nil || time.Since(time.Unix(sec, 0)) > time.Minute {
+		t.Errorf("timestamp header = %q", ts)
+	}
+	// 接收方按文档独立验签：HMAC-SHA256(secret, timestamp + "." + body)
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write([]byte(ts + "." + string(body)))
+	if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); r.header.Get(WebhookSignatureHeader) != want {
+		t.Errorf("signature = %q, want %q", r.header.Get(WebhookSignatureHeader), want)
+	}
+	if r.header.Get(WebhookEventHeader) != "job.completed" || r.header.Get(WebhookDeliveryHeader) != "job-1" {
+		t.Errorf("event/delivery headers = %q/%q", r.header.Get(WebhookEventHeader), r.header.Get(WebhookDeliveryHeader))
+	}
+	if ct := r.header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	// 未设置 secret 时不带签名头
+	if err := testSender(1).Deliver(context.Background(), srv.URL, "", "job.completed", "job-2", body, nil); err != nil {
+		t.Fatal(err)
+	}
+	if r := <-got; r.header.Get(WebhookSignatureHeader) != "" {
+		t.Errorf("unexpected signature without secret: %q", r.header.Get(WebhookSignatureHeader))
+	}
+}
+
+func TestWebhookRetry(t *testing.T) {
+	tests := []struct {
+		name      string
+		statuses  []int // 依次返回的状态码，用尽后返回 200
+		wantCodes []int
+		wantErr   bool
+	}{
+		{"5xx then success", []int{500, 503}, []int{500, 503, 200}, false},
+		{"429 retried", []int{429}, []int{429, 200}, false},
+		{"4xx not retried", []int{400}, []int{400}, true},
+		{"attempts exhausted", []int{502, 502, 502, 502}, []int{502, 502, 502}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var n atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if i := int(n.Add(1)) - 1; i < len(tt.statuses) {
+					w.WriteHeader(tt.statuses[i])
+				}
+			}))
+			defer srv.Close()
+
+			var (
+				mu       sync.Mutex
+				attempts []WebhookAttempt
+			)
+			err := testSender(3).Deliver(context.Background(), srv.URL, "k", "job.completed", "job-1", []byte(`{}`), func(a WebhookAttempt) {
+				mu.Lock()
+				attempts = append(attempts, a)
+				mu.Unlock()
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(attempts) != len(tt.wantCodes) {
+				t.Fatalf("%d attempts, want %d", len(attempts), len(tt.wantCodes))
+			}
+			for i, a := range attempts {
+				if a.StatusCode != tt.wantCodes[i] {
+					t.Errorf("attempt %d status = %d, want %d", i, a.StatusCode, tt.wantCodes[i])
+				}
+				if (a.Error == "") != (a.StatusCode == 200) {
+					t.Errorf("attempt %d error = %q", i, a.Error)
+				}
+			}
+			// 指数退避：第二次间隔不短于第一次（20ms → 40ms）
+			if len(attempts) >= 3 {
+				d1 := attempts[1].At.Sub(attempts[0].At)
+				d2 := attempts[2].At.Sub(attempts[1].At)
+				if d1 < 20*time.Millisecond || d2 < 40*time.Millisecond {
+					t.Errorf("backoff intervals %v, %v; want >= 20ms, 40ms", d1, d2)
+				}
+			}
+		})
+	}
+}
+
+func TestWebhookRetryStopsOnCancel(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	s := NewWebhookSender(WebhookOptions{AllowedHosts: []string{"127.0.0.1"}, MaxAttempts: 5, BaseDelay: time.Minute})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := s.Deliver(ctx, srv.URL, "", "job.completed", "job-1", []byte(`{}`), nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want deadline exceeded", err)
+	}
+}
+
+func TestWebhookDoesNotFollowRedirects(t *testing.T) {
+	var targetHits atomic.Int32
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		targetHits.Add(1)
+	}))
+	defer target.Close()
+	redirector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target.URL+"/internal", http.StatusTemporaryRedirect)
+	}))
+	defer redirector.Close()
+
+	var attempts []WebhookAttempt
+	err := testSender(3).Deliver(context.Background(), redirector.URL, "", "job.completed", "job-1", []byte(`{}`), func(a WebhookAttempt) {
+		attempts = append(attempts, a)
+	})
+	if err == nil {
+		t.Fatal("redirect response should not count as delivered")
+	}
+	if targetHits.Load() != 0 {
+		t.Error("redirect was followed")
+	}
+	if len(attempts) != 1 || attempts[0].StatusCode != http.StatusTemporaryRedirect {
+		t.Errorf("attempts = %+v, want a single 307 without retry", attempts)
+	}
+}
+
+func TestWebhookAllowList(t *testing.T) {
+	s := NewWebhookSender(WebhookOptions{AllowedHosts: []string{"hooks.example.com", " Example.org:8443 "}})
+	for raw, ok := range map[string]bool{
+		"https://hooks.example.com/cb":         true,
+		"https://a.hooks.example.com/cb":       true,
+		"https://example.org:8443/cb":          true,
+		"https://example.org/cb":               false, // 端口不符
+		"https://evilhooks.example.com/cb":     false,
+		"https://hooks.example.com.evil.io/cb": false,
+		"https://user:pw@hooks.example.com/cb": false,
+		"ftp://hooks.example.com/cb":           false,
+		"http://127.0.0.1:8080/cb":             false,
+		"::bad":                                false,
+	} {
+		err := s.CheckURL(raw)
+		if ok && err != nil {
+			t.Errorf("CheckURL(%q) = %v, want allowed", raw, err)
+		}
+		if !ok && !errors.Is(err, ErrWebhookNotAllowed) {
+			t.Errorf("CheckURL(%q) = %v, want ErrWebhookNotAllowed", raw, err)
+		}
+	}
+
+	// 允许列表为空时拒绝所有回调，且不发出请求
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { hits.Add(1) }))
+	defer srv.Close()
+	called := false
+	err := NewWebhookSender(WebhookOptions{}).Deliver(context.Background(), srv.URL, "", "job.completed", "job-1", []byte(`{}`), func(WebhookAttempt) { called = true })
+	if !errors.Is(err, ErrWebhookNotAllowed) || hits.Load() != 0 || called {
+		t.Errorf("err = %v, hits = %d, onAttempt called = %v", err, hits.Load(), called)
+	}
+}
@@ -0,0 +1,38 @@
+package whisper
+
+import (
+	"strings"
+	"sync"
+
+	lowlevel "github.com/ggerganov/whisper.cpp/bindings/go"
+)
+
+// SystemInfo whisper.cpp / ggml 的编译特性与后端（AVX、CUDA、Metal 等）
+func SystemInfo() string {
+	return strings.TrimSpace(lowlevel.Whisper_print_system_info())
+}
+
+// loaded 当前加载在内存中的模型（路径 -> 引用数）；每次推理单独加载，推理结束即释放
+var loaded = struct {
+	sync.Mutex
+	models map[string]int
+}{models: map[string]int{}}
+
+func trackLoaded(modelPath string, delta int) {
+	loaded.Lock()
+	defer loaded.Unlock()
+	if loaded.models[modelPath] += delta; loaded.models[modelPath] <= 0 {
+		delete(loaded.models, modelPath)
+	}
+}
+
+// LoadedModels 当前加载在内存中的模型路径及正在使用它的推理数
+func LoadedModels() map[string]int {
+	loaded.Lock()
+	defer loaded.Unlock()
+	out := make(map[string]int, len(loaded.models))
+	for p, n := range loaded.models {
+		out[p] = n
+	}
+	return out
+}
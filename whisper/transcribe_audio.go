@@ -1,11 +1,17 @@
 package whisper
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	wpk "github.com/ggerganov/whisper.cpp/bindings/go/pkg/whisper"
+	"go-whisper-mcp/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
 	"io"
+	"math"
+	"strings"
 	"time"
+	"unicode"
 )
 
 type TranscribeAudio struct{}
@@ -14,25 +20,79 @@ func NewTranscribeAudio() *TranscribeAudio {
 	return &TranscribeAudio{}
 }
 
-func (a *TranscribeAudio) Transcribe(modelPath string, lang string, threads int, data []float32) ([]TranscribeAudioResult, error) {
+func (a *TranscribeAudio) Transcribe(ctx context.Context, modelPath string, lang string, threads int, data []float32) ([]TranscribeAudioResult, error) {
+	return a.TranscribeWithOptions(ctx, modelPath, DecodeOptions{Language: lang, Threads: threads}, data)
+}
+
+// TranscribeWithOptions 带完整解码参数的转录；ctx 取消后在下一个编码窗口（约 30s 音频）开始前中止推理，返回 ctx.Err()
+func (a *TranscribeAudio) TranscribeWithOptions(ctx context.Context, modelPath string, opts DecodeOptions, data []float32) ([]TranscribeAudioResult, error) {
+	t, err := a.TranscribeDetailed(ctx, modelPath, opts, data)
+	if err != nil {
+		return nil, err
+	}
+	return t.Segments, nil
+}
+
+// TranscribeDetailed 同 TranscribeWithOptions，另外返回识别语言与音频时长
+func (a *TranscribeAudio) TranscribeDetailed(ctx context.Context, modelPath string, opts DecodeOptions, data []float32) (*Transcription, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	// whisper 处理
+	loadStart := time.Now()
+	_, loadSpan := tracing.Start(ctx, "whisper.model_load", attribute.String("whisper.model_path", modelPath))
 	model, err := wpk.New(modelPath)
+	tracing.End(loadSpan, err)
 	if err != nil {
 		return nil, fmt.Errorf("load model: %w", err)
 	}
+	trackLoaded(modelPath, 1)
+	defer trackLoaded(modelPath, -1)
 	defer model.Close()
+	loadDuration := time.Since(loadStart)
 
 	wc, err := model.NewContext()
 	if err != nil {
 		return nil, err
 	}
+	lang := opts.Language
 	if lang == "" {
 		lang = "auto"
 	}
 	_ = wc.SetLanguage(lang)
-	wc.SetThreads(uint(threads))
+	if opts.Threads > 0 {
+		wc.SetThreads(uint(opts.Threads))
+	}
+	if opts.BeamSize > 0 {
+		wc.SetBeamSize(opts.BeamSize)
+	}
+	if opts.Temperature > 0 {
+		wc.SetTemperature(opts.Temperature)
+	}
+	if opts.InitialPrompt != "" {
+		wc.SetInitialPrompt(opts.InitialPrompt)
+	}
+	wc.SetTranslate(opts.Translate)
+	if opts.WordTimestamps {
+		wc.SetTokenTimestamps(true)
+	}
 
-	if err := wc.Process(data, nil, nil, nil); err != nil {
+	// 每个编码窗口开始前检查 ctx，返回 false 即中止 whisper_full
+	encoderBegin := func() bool { return ctx.Err() == nil }
+	_, span := tracing.Start(ctx, "whisper.inference",
+		attribute.String("whisper.language", lang),
+		attribute.Bool("whisper.translate", opts.Translate),
+		attribute.Float64("whisper.audio_seconds", float64(len(data))/wpk.SampleRate))
+	err = wc.Process(data, encoderBegin, nil, nil)
+	if err != nil && ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	tracing.End(span, err)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
 		return nil, err
 	}
 
@@ -45,12 +105,75 @@ func (a *TranscribeAudio) Transcribe(modelPath string, lang string, threads int,
 		if err != nil {
 			return nil, err
 		}
-		segs = append(segs, TranscribeAudioResult{
-			Start: sg.Start.Truncate(time.Millisecond).String(),
-			End:   sg.End.Truncate(time.Millisecond).String(),
-			Text:  sg.Text,
-		})
+		r := TranscribeAudioResult{
+			Start:   sg.Start.Truncate(time.Millisecond).String(),
+			End:     sg.End.Truncate(time.Millisecond).String(),
+			StartMs: sg.Start.Milliseconds(),
+			EndMs:   sg.End.Milliseconds(),
+			Text:    sg.Text,
+		}
+		// 只保留文本 token（去掉 [_BEG_]、时间戳等特殊 token）
+		var text []wpk.Token
+		for _, tok := range sg.Tokens {
+			if wc.IsText(tok) {
+				text = append(text, tok)
+			}
+		}
+		var logprob float64
+		for _, tok := range text {
+			r.Tokens = append(r.Tokens, tok.Id)
+			logprob += math.Log(float64(max(tok.P, 1e-10)))
+		}
+		if len(text) > 0 {
+			r.AvgLogprob = logprob / float64(len(text))
+		}
+		if opts.WordTimestamps {
+			r.Words = mergeWords(text)
+		}
+		segs = append(segs, r)
+	}
+
+	language := wc.DetectedLanguage()
+	if language == "" {
+		language = wc.Language()
+	}
+	return &Transcription{
+		Language:     language,
+		Duration:     float64(len(data)) / wpk.SampleRate,
+		LoadDuration: loadDuration,
+		Segments:     segs,
+	}, nil
+}
+
+// mergeWords 把 BPE token 合并为词：以空格开头的 token 开始新词（中日文等无空格语言按 token 切分）
+func mergeWords(tokens []wpk.Token) []Word {
+	var words []Word
+	for _, tok := range tokens {
+		if tok.Text == "" {
+			continue
+		}
+		if len(words) == 0 || strings.HasPrefix(tok.Text, " ") || !isSpaceDelimited(tok.Text) {
+			words = append(words, Word{StartMs: tok.Start.Milliseconds()})
+		}
+		w := &words[len(words)-1]
+		w.Word += tok.Text
+		w.EndMs = tok.End.Milliseconds()
 	}
+	out := words[:0]
+	for _, w := range words {
+		if w.Word = strings.TrimSpace(w.Word); w.Word != "" {
+			out = append(out, w)
+		}
+	}
+	return out
+}
 
-	return segs, nil
+// isSpaceDelimited 文本不含 CJK 等不以空格分词的字符
+func isSpaceDelimited(s string) bool {
+	for _, r := range s {
+		if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul, unicode.Thai) {
+			return false
+		}
+	}
+	return true
 }
@@ -1,7 +1,44 @@
 package whisper
 
+import "time"
+
 type TranscribeAudioResult struct {
-	Start string `json:"start"`
-	End   string `json:"end"`
-	Text  string `json:"text"`
+	Start   string `json:"start"`
+	End     string `json:"end"`
+	StartMs int64  `json:"start_ms"`
+	EndMs   int64  `json:"end_ms"`
+	Text    string `json:"text"`
+
+	Words []Word `json:"words,omitempty"` // 词级时间戳，仅 DecodeOptions.WordTimestamps 时
+
+	// 以下仅保存在内存中（OpenAI verbose_json 使用），不写入缓存
+	Tokens     []int   `json:"-"` // 文本 token ID
+	AvgLogprob float64 `json:"-"` // 文本 token 的平均对数概率
+}
+
+// Word 词级时间戳
+type Word struct {
+	Word    string `json:"word"`
+	StartMs int64  `json:"start_ms"`
+	EndMs   int64  `json:"end_ms"`
+}
+
+// Transcription 一次推理的完整结果
+type Transcription struct {
+	Language     string        // 实际使用的语言；auto 时为检测结果
+	Duration     float64       // 音频时长（秒）
+	LoadDuration time.Duration // 模型加载耗时
+	Segments     []TranscribeAudioResult
+}
+
+// DecodeOptions 解码参数（零值表示使用 whisper 默认）
+type DecodeOptions struct {
+	Language      string
+	Threads       int
+	BeamSize      int
+	Temperature   float32
+	InitialPrompt string
+
+	Translate      bool // 翻译为英文
+	WordTimestamps bool // 计算 token 时间戳并合并为词
 }